@@ -2,11 +2,13 @@ package main
 
 import (
 	"github.com/sgerhardt/chatter/internal/setup"
-	"log"
+	"log/slog"
+	"os"
 )
 
 func main() {
-	if err := setup.NewRootCmd().Execute(); err != nil {
-		log.Fatal(err)
+	if err := setup.Execute(); err != nil {
+		slog.Error("chatter failed", "err", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,129 @@
+package audio
+
+import (
+	"math"
+)
+
+// biquad is a second order IIR filter
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+// apply filters s in place
+func (q biquad) apply(s []float64) {
+	var x1, x2, y1, y2 float64
+	for i, x := range s {
+		y := q.b0*x + q.b1*x1 + q.b2*x2 - q.a1*y1 - q.a2*y2
+		x1, x2, y1, y2 = x, x1, y, y1
+		s[i] = y
+	}
+}
+
+// kWeighting returns the ITU-R BS.1770 pre-filter, a high shelf modelling the head followed by a high pass, for
+// the sample rate
+func kWeighting(sampleRate float64) []biquad {
+	k := math.Tan(math.Pi * 1681.974450955533 / sampleRate)
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	k = math.Tan(math.Pi * 38.13547087602444 / sampleRate)
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	highPass := biquad{b0: 1, b1: -2, b2: 1, a1: 2 * (k*k - 1) / a0, a2: (1 - k/q + k*k) / a0}
+	return []biquad{shelf, highPass}
+}
+
+// Loudness returns the integrated loudness of 16 bit PCM in LUFS, measured as ITU-R BS.1770 describes: K-weighted,
+// in 400ms blocks overlapping by 75%, gated at -70 LUFS and then 10 LU below the loudness of the blocks that pass.
+// Silence measures -Inf.
+func Loudness(f WAVFormat, pcm []byte) (float64, error) {
+	s, err := samples(f, pcm)
+	if err != nil {
+		return 0, err
+	}
+	channels := int(f.Channels)
+	frames := len(s) / channels
+	// the mean square of each channel's K-weighted samples, summed over channels, for every 100ms step
+	step := max(1, int(f.SampleRate)/10)
+	power := make([]float64, (frames+step-1)/step)
+	filters := kWeighting(float64(f.SampleRate))
+	channel := make([]float64, frames)
+	for c := 0; c < channels; c++ {
+		for i := range channel {
+			channel[i] = s[i*channels+c]
+		}
+		for _, q := range filters {
+			q.apply(channel)
+		}
+		for i, v := range channel {
+			power[i/step] += v * v
+		}
+	}
+
+	var blocks []float64
+	window := min(4, len(power))
+	for i := 0; i+window <= len(power); i++ {
+		sum, n := 0.0, 0
+		for j := i; j < i+window; j++ {
+			sum += power[j]
+			n += min(step, frames-j*step)
+		}
+		blocks = append(blocks, sum/float64(n))
+	}
+	gated := func(threshold float64) (float64, int) {
+		sum, n := 0.0, 0
+		for _, z := range blocks {
+			if lufs(z) > threshold {
+				sum += z
+				n++
+			}
+		}
+		if n == 0 {
+			return 0, 0
+		}
+		return sum / float64(n), n
+	}
+	z, n := gated(-70)
+	if n == 0 {
+		return math.Inf(-1), nil
+	}
+	z, _ = gated(lufs(z) - 10)
+	return lufs(z), nil
+}
+
+func lufs(meanSquare float64) float64 {
+	return -0.691 + 10*math.Log10(meanSquare)
+}
+
+// Normalize scales 16 bit PCM to the target integrated loudness in LUFS, but no louder than puts its peak at
+// ceiling dBFS. Silence is returned as it is.
+func Normalize(f WAVFormat, pcm []byte, target, ceiling float64) ([]byte, error) {
+	loudness, err := Loudness(f, pcm)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsInf(loudness, -1) {
+		return pcm, nil
+	}
+	s, _ := samples(f, pcm)
+	peak := 0.0
+	for _, v := range s {
+		peak = max(peak, math.Abs(v))
+	}
+	gain := math.Pow(10, (target-loudness)/20)
+	if limit := math.Pow(10, ceiling/20) / peak; gain > limit {
+		gain = limit
+	}
+	for i := range s {
+		s[i] *= gain
+	}
+	return encodeSamples(s), nil
+}
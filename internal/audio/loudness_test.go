@@ -0,0 +1,70 @@
+package audio_test
+
+import (
+	"encoding/binary"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"time"
+)
+
+// sine returns d of a tone as 16 bit PCM, the same on every channel
+func sine(f audio.WAVFormat, hz, amplitude float64, d time.Duration) []byte {
+	frames := int(time.Duration(f.SampleRate) * d / time.Second)
+	pcm := make([]byte, 0, frames*int(f.Channels)*2)
+	for i := 0; i < frames; i++ {
+		v := int16(math.Round(amplitude * 32767 * math.Sin(2*math.Pi*hz*float64(i)/float64(f.SampleRate))))
+		for c := 0; c < int(f.Channels); c++ {
+			pcm = binary.LittleEndian.AppendUint16(pcm, uint16(v))
+		}
+	}
+	return pcm
+}
+
+func TestLoudness(t *testing.T) {
+	t.Parallel()
+
+	stereo48k := audio.WAVFormat{AudioFormat: 1, Channels: 2, SampleRate: 48000, BitsPerSample: 16}
+	// BS.1770 calibrates a full scale 1 kHz tone on one channel to -3.01 LUFS, so on two it is 0
+	l, err := audio.Loudness(stereo48k, sine(stereo48k, 1000, 1, 2*time.Second))
+	require.NoError(t, err)
+	assert.InDelta(t, 0, l, 0.05)
+
+	l, err = audio.Loudness(mono16k, sine(mono16k, 1000, 0.5, 2*time.Second))
+	require.NoError(t, err)
+	assert.InDelta(t, -9.03, l, 0.1)
+
+	l, err = audio.Loudness(mono16k, make([]byte, 32000))
+	require.NoError(t, err)
+	assert.True(t, math.IsInf(l, -1))
+
+	_, err = audio.Loudness(audio.WAVFormat{AudioFormat: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 8}, []byte{0x80})
+	assert.EqualError(t, err, "cannot process 16000 Hz, 1 channel(s), 8 bit audio, only 16 bit PCM")
+}
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	quiet := sine(mono16k, 1000, 0.05, 2*time.Second)
+	pcm, err := audio.Normalize(mono16k, quiet, -16, -1)
+	require.NoError(t, err)
+	l, err := audio.Loudness(mono16k, pcm)
+	require.NoError(t, err)
+	assert.InDelta(t, -16, l, 0.1)
+
+	// -10 LUFS would put this tone's peak at about -7 dBFS, so it stops at the -9 dBFS ceiling
+	pcm, err = audio.Normalize(mono16k, quiet, -10, -9)
+	require.NoError(t, err)
+	peak := 0
+	for i := 0; i < len(pcm); i += 2 {
+		peak = max(peak, int(math.Abs(float64(int16(binary.LittleEndian.Uint16(pcm[i:]))))))
+	}
+	assert.InDelta(t, 32768*math.Pow(10, -9.0/20), peak, 2)
+
+	silence := make([]byte, 3200)
+	pcm, err = audio.Normalize(mono16k, silence, -16, -1)
+	require.NoError(t, err)
+	assert.Equal(t, silence, pcm)
+}
@@ -0,0 +1,163 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// speechThreshold is the level, in dBFS, above which the bed is ducked under speech
+	speechThreshold = -40
+	// duckAttack and duckRelease are how quickly the bed dips when speech starts and recovers when it stops
+	duckAttack  = 50 * time.Millisecond
+	duckRelease = 400 * time.Millisecond
+	// speechHold keeps the bed ducked through the gaps between words
+	speechHold = 300 * time.Millisecond
+)
+
+// Mix arranges speech into an episode: the intro, then the speech over the bed, then the outro. Every part is PCM in
+// the speech's format and any may be empty.
+type Mix struct {
+	Intro []byte
+	Outro []byte
+	// Bed is looped under the speech, starting Fade before it and ending Fade after it
+	Bed []byte
+	// BedVolume is the bed's gain in dB, and Duck how much further it dips under speech
+	BedVolume float64
+	Duck      float64
+	// Fade is how long the bed takes to fade in and out
+	Fade time.Duration
+}
+
+// Convert changes 16 bit PCM to another sample rate and channel count, mixing down to mono or copying mono to every
+// channel, and resampling linearly
+func Convert(from WAVFormat, pcm []byte, to WAVFormat) ([]byte, error) {
+	if from == to {
+		return pcm, nil
+	}
+	s, err := samples(from, pcm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = samples(to, nil); err != nil {
+		return nil, err
+	}
+	in, out := int(from.Channels), int(to.Channels)
+	frames := len(s) / in
+	at := func(frame, channel int) float64 {
+		frame = min(frame, frames-1)
+		if in == out {
+			return s[frame*in+channel]
+		}
+		if in == 1 {
+			return s[frame]
+		}
+		if out == 1 {
+			sum := 0.0
+			for c := 0; c < in; c++ {
+				sum += s[frame*in+c]
+			}
+			return sum / float64(in)
+		}
+		return s[frame*in+min(channel, in-1)]
+	}
+	n := int(int64(frames) * int64(to.SampleRate) / int64(from.SampleRate))
+	converted := make([]float64, 0, n*out)
+	ratio := float64(from.SampleRate) / float64(to.SampleRate)
+	for i := 0; i < n; i++ {
+		pos := float64(i) * ratio
+		frame, frac := int(pos), pos-math.Floor(pos)
+		for c := 0; c < out; c++ {
+			converted = append(converted, at(frame, c)*(1-frac)+at(frame+1, c)*frac)
+		}
+	}
+	return encodeSamples(converted), nil
+}
+
+// Apply mixes the episode, returning it and how far into it the speech starts
+func (m Mix) Apply(f WAVFormat, speech []byte) ([]byte, time.Duration, error) {
+	s, err := samples(f, speech)
+	if err != nil {
+		return nil, 0, err
+	}
+	intro, err := samples(f, m.Intro)
+	if err != nil {
+		return nil, 0, fmt.Errorf("intro: %w", err)
+	}
+	outro, err := samples(f, m.Outro)
+	if err != nil {
+		return nil, 0, fmt.Errorf("outro: %w", err)
+	}
+	bed, err := samples(f, m.Bed)
+	if err != nil {
+		return nil, 0, fmt.Errorf("bed: %w", err)
+	}
+	channels := int(f.Channels)
+	frames := func(d time.Duration) int {
+		return int(time.Duration(f.SampleRate) * d / time.Second)
+	}
+
+	lead := 0
+	if len(bed) > 0 {
+		lead = frames(m.Fade) * channels
+	}
+	body := make([]float64, lead+len(s)+lead)
+	copy(body[lead:], s)
+	if len(bed) > 0 {
+		gain := duckGains(f, s, math.Pow(10, m.Duck/20))
+		volume := math.Pow(10, m.BedVolume/20)
+		fade := max(1, lead/channels)
+		total := len(body) / channels
+		for i := 0; i < total; i++ {
+			g := volume
+			if i < fade {
+				g *= float64(i) / float64(fade)
+			} else if total-i < fade {
+				g *= float64(total-i) / float64(fade)
+			}
+			if j := i - lead/channels; j >= 0 && j < len(gain) {
+				g *= gain[j]
+			}
+			for c := 0; c < channels; c++ {
+				body[i*channels+c] += g * bed[(i*channels+c)%len(bed)]
+			}
+		}
+	}
+
+	episode := make([]float64, 0, len(intro)+len(body)+len(outro))
+	episode = append(append(append(episode, intro...), body...), outro...)
+	// two bytes to a sample
+	start := 2 * (len(intro) + lead)
+	return encodeSamples(episode), f.Duration(start), nil
+}
+
+// duckGains returns the bed's gain for each frame of speech: duck while the speech is louder than speechThreshold,
+// held through short gaps, and 1 otherwise, moving smoothly between them
+func duckGains(f WAVFormat, s []float64, duck float64) []float64 {
+	channels := int(f.Channels)
+	frames := len(s) / channels
+	rate := float64(f.SampleRate)
+	threshold := math.Pow(10, speechThreshold/20.0)
+	hold := int(rate * speechHold.Seconds())
+	attack := 1 - math.Exp(-1/(rate*duckAttack.Seconds()))
+	release := 1 - math.Exp(-1/(rate*duckRelease.Seconds()))
+
+	gains := make([]float64, frames)
+	g, held := 1.0, hold
+	for i := range gains {
+		for _, v := range s[i*channels : (i+1)*channels] {
+			if math.Abs(v) > threshold {
+				held = 0
+			}
+		}
+		target, coef := 1.0, release
+		if held < hold {
+			target, coef = duck, attack
+		}
+		held++
+		g += (target - g) * coef
+		gains[i] = g
+	}
+	return gains
+}
@@ -0,0 +1,82 @@
+package audio_test
+
+import (
+	"encoding/binary"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"testing"
+	"time"
+)
+
+// constant returns d of 16 bit PCM holding one value on every channel
+func constant(f audio.WAVFormat, v int16, d time.Duration) []byte {
+	frames := int(time.Duration(f.SampleRate) * d / time.Second)
+	var pcm []byte
+	for i := 0; i < frames*int(f.Channels); i++ {
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(v))
+	}
+	return pcm
+}
+
+func sampleAt(f audio.WAVFormat, pcm []byte, at time.Duration) int16 {
+	i := int(time.Duration(f.SampleRate)*at/time.Second) * int(f.Channels) * 2
+	return int16(binary.LittleEndian.Uint16(pcm[i:]))
+}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	stereo8k := audio.WAVFormat{AudioFormat: 1, Channels: 2, SampleRate: 8000, BitsPerSample: 16}
+	pcm, err := audio.Convert(mono16k, constant(mono16k, 1000, 100*time.Millisecond), stereo8k)
+	require.NoError(t, err)
+	assert.Equal(t, 100*time.Millisecond, stereo8k.Duration(len(pcm)))
+	assert.Equal(t, constant(stereo8k, 1000, 100*time.Millisecond), pcm)
+
+	var stereo []byte
+	for i := 0; i < 800; i++ {
+		stereo = binary.LittleEndian.AppendUint16(stereo, uint16(int16(1000)))
+		stereo = binary.LittleEndian.AppendUint16(stereo, uint16(int16(3000)))
+	}
+	pcm, err = audio.Convert(stereo8k, stereo, mono16k)
+	require.NoError(t, err)
+	assert.Equal(t, 100*time.Millisecond, mono16k.Duration(len(pcm)))
+	assert.Equal(t, int16(2000), sampleAt(mono16k, pcm, 50*time.Millisecond))
+
+	_, err = audio.Convert(mono16k, nil, audio.WAVFormat{AudioFormat: 3, Channels: 1, SampleRate: 16000, BitsPerSample: 32})
+	assert.Error(t, err)
+
+	noRate := audio.WAVFormat{AudioFormat: 1, Channels: 1, BitsPerSample: 16}
+	_, err = audio.Convert(noRate, constant(mono16k, 1000, 10*time.Millisecond), mono16k)
+	assert.ErrorContains(t, err, "sample rate of 0")
+	_, err = audio.Convert(mono16k, constant(mono16k, 1000, 10*time.Millisecond), noRate)
+	assert.ErrorContains(t, err, "sample rate of 0")
+}
+
+func TestMix_Apply(t *testing.T) {
+	t.Parallel()
+
+	speech := slices.Concat(make([]byte, 16000), sine(mono16k, 440, 0.5, 500*time.Millisecond))
+	m := audio.Mix{
+		Intro:     constant(mono16k, 100, 100*time.Millisecond),
+		Outro:     constant(mono16k, 200, 300*time.Millisecond),
+		Bed:       constant(mono16k, 8000, 50*time.Millisecond),
+		BedVolume: -6,
+		Duck:      -20,
+		Fade:      200 * time.Millisecond,
+	}
+	episode, start, err := m.Apply(mono16k, speech)
+	require.NoError(t, err)
+	assert.Equal(t, 300*time.Millisecond, start)
+	assert.Equal(t, 1800*time.Millisecond, mono16k.Duration(len(episode)))
+
+	assert.Equal(t, int16(100), sampleAt(mono16k, episode, 50*time.Millisecond), "intro")
+	assert.InDelta(t, 0, sampleAt(mono16k, episode, 100*time.Millisecond), 50, "bed fading in")
+	assert.InDelta(t, 4010, sampleAt(mono16k, episode, 600*time.Millisecond), 50, "bed at -6 dB before the speech")
+	// 250ms into the tone it crosses zero, leaving the bed at -26 dB
+	bed := sampleAt(mono16k, episode, 1050*time.Millisecond)
+	assert.InDelta(t, 401, bed, 60, "bed ducked under the speech")
+	assert.InDelta(t, 0, sampleAt(mono16k, episode, 1499*time.Millisecond), 50, "bed faded out")
+	assert.Equal(t, int16(200), sampleAt(mono16k, episode, 1600*time.Millisecond), "outro")
+}
@@ -0,0 +1,150 @@
+package audio
+
+import (
+	"time"
+)
+
+var (
+	mpeg1Bitrates = [3][16]int{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0}, // layer I
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},    // layer II
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},     // layer III
+	}
+	mpeg2Bitrates = [3][16]int{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0}, // layer I
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},      // layer II
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},      // layer III
+	}
+	sampleRates = map[int][3]int{
+		3: {44100, 48000, 32000}, // MPEG 1
+		2: {22050, 24000, 16000}, // MPEG 2
+		0: {11025, 12000, 8000},  // MPEG 2.5
+	}
+)
+
+// frame describes a single MPEG audio frame header
+type frame struct {
+	length     int
+	samples    int
+	sampleRate int
+}
+
+// parseFrame decodes the 4 byte MPEG audio frame header at the start of b
+func parseFrame(b []byte) (frame, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return frame{}, false
+	}
+	version := int(b[1]>>3) & 0x03
+	layer := 4 - int(b[1]>>1)&0x03 // 1, 2 or 3
+	bitrateIdx := int(b[2] >> 4)
+	rateIdx := int(b[2]>>2) & 0x03
+	padding := int(b[2]>>1) & 0x01
+	rates, ok := sampleRates[version]
+	if !ok || layer == 4 || rateIdx == 3 {
+		return frame{}, false
+	}
+	bitrates := mpeg1Bitrates
+	if version != 3 {
+		bitrates = mpeg2Bitrates
+	}
+	bitrate := bitrates[layer-1][bitrateIdx] * 1000
+	if bitrate == 0 {
+		return frame{}, false
+	}
+	sampleRate := rates[rateIdx]
+
+	f := frame{sampleRate: sampleRate}
+	switch {
+	case layer == 1:
+		f.samples = 384
+		f.length = (12*bitrate/sampleRate + padding) * 4
+	case layer == 3 && version != 3:
+		f.samples = 576
+		f.length = 72*bitrate/sampleRate + padding
+	default:
+		f.samples = 1152
+		f.length = 144*bitrate/sampleRate + padding
+	}
+	return f, f.length > 4
+}
+
+// SkipID3 returns data with any leading ID3v2 tag removed
+func SkipID3(data []byte) []byte {
+	if len(data) < 10 || string(data[:3]) != "ID3" {
+		return data
+	}
+	size := int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9])
+	size += 10
+	if data[5]&0x10 != 0 {
+		size += 10 // footer present
+	}
+	if size > len(data) {
+		return nil
+	}
+	return data[size:]
+}
+
+// IsMP3 reports whether data, after any ID3 tag, starts with an MPEG audio frame that is followed by another frame
+// or the end of the data. Unlike Duration, it doesn't search for a frame, so other binary files aren't mistaken for mp3.
+func IsMP3(data []byte) bool {
+	data = SkipID3(data)
+	f, ok := parseFrame(data)
+	if !ok || f.length > len(data) {
+		return false
+	}
+	if f.length == len(data) {
+		return true
+	}
+	_, ok = parseFrame(data[f.length:])
+	return ok
+}
+
+// Duration walks the MPEG frames in data and returns the playback length.
+// Bytes that are not part of a valid frame are skipped, so non-mp3 input yields zero.
+func Duration(data []byte) time.Duration {
+	data = SkipID3(data)
+	var total time.Duration
+	for i := 0; i+4 <= len(data); {
+		f, ok := parseFrame(data[i:])
+		if !ok {
+			i++
+			continue
+		}
+		total += time.Duration(f.samples) * time.Second / time.Duration(f.sampleRate)
+		i += f.length
+	}
+	return total
+}
+
+// silentHeader is an MPEG 1 layer III, 128kbps, 44.1kHz joint stereo frame header, matching the default Eleven Labs output
+var silentHeader = []byte{0xFF, 0xFB, 0x90, 0x64}
+
+// Silence returns mp3 frames that decode to d of silence. The frame format is copied from
+// the first frame found in like so the silence can be concatenated with it.
+func Silence(d time.Duration, like []byte) []byte {
+	header := append([]byte{}, silentHeader...)
+	like = SkipID3(like)
+	for i := 0; i+4 <= len(like); i++ {
+		if _, ok := parseFrame(like[i:]); ok {
+			header = append([]byte{}, like[i:i+4]...)
+			break
+		}
+	}
+	header[1] |= 0x01  // no CRC
+	header[2] &^= 0x02 // no padding
+	f, ok := parseFrame(header)
+	if !ok || d <= 0 {
+		return nil
+	}
+	frameDuration := time.Duration(f.samples) * time.Second / time.Duration(f.sampleRate)
+	frames := int((d + frameDuration - 1) / frameDuration)
+
+	// A frame with zeroed side information carries no audio data and decodes to silence
+	silent := make([]byte, f.length)
+	copy(silent, header)
+	out := make([]byte, 0, frames*f.length)
+	for i := 0; i < frames; i++ {
+		out = append(out, silent...)
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package audio_test
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/stretchr/testify/assert"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// mpegFrame builds a silent MPEG 1 layer III frame at 128kbps / 44.1kHz
+func mpegFrame() []byte {
+	f := make([]byte, 417)
+	copy(f, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return f
+}
+
+func TestDuration(t *testing.T) {
+	t.Parallel()
+
+	tag := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 4, 'a', 'b', 'c', 'd'}
+	frameDuration := 1152 * time.Second / 44100
+
+	tests := []struct {
+		name string
+		data []byte
+		want time.Duration
+	}{
+		{name: "not an mp3", data: []byte("bytes representing the mp3 file..."), want: 0},
+		{name: "counts every frame", data: bytes.Repeat(mpegFrame(), 10), want: 10 * frameDuration},
+		{name: "skips a leading ID3 tag", data: append(tag, mpegFrame()...), want: frameDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, audio.Duration(tt.data))
+		})
+	}
+}
+
+func TestIsMP3(t *testing.T) {
+	t.Parallel()
+
+	tag := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 4, 'a', 'b', 'c', 'd'}
+	random := make([]byte, 200<<10)
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range random {
+		random[i] = byte(r.Uint32())
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "frames", data: bytes.Repeat(mpegFrame(), 3), want: true},
+		{name: "a single frame", data: mpegFrame(), want: true},
+		{name: "frames after an ID3 tag", data: append(tag, bytes.Repeat(mpegFrame(), 2)...), want: true},
+		{name: "silence", data: audio.Silence(time.Second, nil), want: true},
+		{name: "a frame that isn't followed by another", data: append(mpegFrame(), "trailing bytes"...)},
+		{name: "a truncated frame", data: mpegFrame()[:100]},
+		{name: "a frame after other bytes", data: append([]byte("junk"), bytes.Repeat(mpegFrame(), 2)...)},
+		{name: "a PDF", data: append([]byte("%PDF-1.7\n"), random[:4096]...)},
+		{name: "random bytes", data: random},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, audio.IsMP3(tt.data))
+		})
+	}
+}
+
+func TestSilence(t *testing.T) {
+	t.Parallel()
+
+	frameDuration := 1152 * time.Second / 44100
+	silence := audio.Silence(time.Second, nil)
+	assert.Equal(t, 39*frameDuration, audio.Duration(silence))
+	assert.Nil(t, audio.Silence(0, nil))
+
+	// MPEG 2 layer III, 64kbps, 24kHz mono
+	mono := []byte{0xFF, 0xF3, 0x84, 0xC0}
+	silence = audio.Silence(time.Second, append(mono, make([]byte, 300)...))
+	assert.Equal(t, mono, silence[:4])
+	assert.Equal(t, 42*576*time.Second/24000, audio.Duration(silence))
+}
@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// samples decodes 16 bit PCM into interleaved samples between -1 and 1
+func samples(f WAVFormat, pcm []byte) ([]float64, error) {
+	if f.AudioFormat != 1 || f.BitsPerSample != 16 || f.Channels == 0 {
+		return nil, fmt.Errorf("cannot process %s audio, only 16 bit PCM", f)
+	}
+	if f.SampleRate == 0 {
+		return nil, errors.New("cannot process audio with a sample rate of 0")
+	}
+	out := make([]float64, len(pcm)/2)
+	for i := range out {
+		out[i] = float64(int16(binary.LittleEndian.Uint16(pcm[2*i:]))) / 32768
+	}
+	return out, nil
+}
+
+// encodeSamples encodes samples as 16 bit PCM, clipping any outside -1 to 1
+func encodeSamples(s []float64) []byte {
+	out := make([]byte, 2*len(s))
+	for i, v := range s {
+		v = math.Round(v * 32768)
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(max(-32768, min(32767, v)))))
+	}
+	return out
+}
+
+// TrimSilence removes the frames quieter than threshold, in dBFS, from the start and end of 16 bit PCM, keeping pad
+// of them either side. It returns the trimmed PCM and how much was removed from the start.
+func TrimSilence(f WAVFormat, pcm []byte, threshold float64, pad time.Duration) ([]byte, time.Duration, error) {
+	s, err := samples(f, pcm)
+	if err != nil {
+		return nil, 0, err
+	}
+	level := math.Pow(10, threshold/20)
+	channels := int(f.Channels)
+	frames := len(s) / channels
+	loud := func(frame int) bool {
+		for _, v := range s[frame*channels : (frame+1)*channels] {
+			if math.Abs(v) > level {
+				return true
+			}
+		}
+		return false
+	}
+	first, last := 0, frames-1
+	for first < frames && !loud(first) {
+		first++
+	}
+	if first == frames {
+		return nil, f.Duration(len(pcm)), nil
+	}
+	for !loud(last) {
+		last--
+	}
+	keep := int(time.Duration(f.SampleRate) * pad / time.Second)
+	first = max(0, first-keep)
+	last = min(frames-1, last+keep)
+	align := f.blockAlign()
+	return pcm[first*align : (last+1)*align], f.Duration(first * align), nil
+}
+
+// SplitOnSilence cuts 16 bit PCM into pieces no longer than limit, each cut made at the last of the quietest 10ms in
+// the second half of the piece, which in speech is a pause between words or sentences
+func SplitOnSilence(f WAVFormat, pcm []byte, limit time.Duration) ([][]byte, error) {
+	s, err := samples(f, pcm)
+	if err != nil {
+		return nil, err
+	}
+	channels := int(f.Channels)
+	align := f.blockAlign()
+	block := max(1, int(f.SampleRate)/100)
+	maxFrames := max(2*block, int(time.Duration(f.SampleRate)*limit/time.Second))
+	frames := len(s) / channels
+	energy := func(start int) float64 {
+		sum := 0.0
+		for _, v := range s[start*channels : min(start+block, frames)*channels] {
+			sum += v * v
+		}
+		return sum
+	}
+
+	var pieces [][]byte
+	start := 0
+	for frames-start > maxFrames {
+		cut, quietest := start+maxFrames, math.Inf(1)
+		for b := start + maxFrames/2; b+block <= start+maxFrames; b += block {
+			if e := energy(b); e <= quietest {
+				cut, quietest = b+block/2, e
+			}
+		}
+		pieces = append(pieces, pcm[start*align:cut*align])
+		start = cut
+	}
+	return append(pieces, pcm[start*align:frames*align]), nil
+}
@@ -0,0 +1,50 @@
+package audio_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTrimSilence(t *testing.T) {
+	t.Parallel()
+
+	tone := sine(mono16k, 440, 0.5, 500*time.Millisecond)
+	pcm := slices.Concat(make([]byte, 16000), tone, make([]byte, 32000))
+
+	trimmed, lead, err := audio.TrimSilence(mono16k, pcm, -50, 100*time.Millisecond)
+	require.NoError(t, err)
+	// the tone starts on a zero crossing, so its first sample is trimmed too
+	assert.InDelta(t, 400*time.Millisecond, lead, float64(100*time.Microsecond))
+	assert.InDelta(t, 700*time.Millisecond, mono16k.Duration(len(trimmed)), float64(time.Millisecond))
+
+	trimmed, lead, err = audio.TrimSilence(mono16k, make([]byte, 3200), -50, 0)
+	require.NoError(t, err)
+	assert.Empty(t, trimmed)
+	assert.Equal(t, 100*time.Millisecond, lead)
+}
+
+func TestSplitOnSilence(t *testing.T) {
+	t.Parallel()
+
+	word := sine(mono16k, 440, 0.5, 300*time.Millisecond)
+	gap := make([]byte, 3200)
+	pcm := slices.Concat(word, gap, word, gap, word, gap, word)
+
+	pieces, err := audio.SplitOnSilence(mono16k, pcm, 900*time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, pieces, 2)
+	assert.Equal(t, pcm, slices.Concat(pieces...))
+	for _, p := range pieces {
+		assert.LessOrEqual(t, mono16k.Duration(len(p)), 900*time.Millisecond)
+	}
+	// cut at the end of the second gap, after two words
+	assert.InDelta(t, 795*time.Millisecond, mono16k.Duration(len(pieces[0])), float64(10*time.Millisecond))
+
+	pieces, err = audio.SplitOnSilence(mono16k, word, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{word}, pieces)
+}
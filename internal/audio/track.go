@@ -0,0 +1,105 @@
+package audio
+
+import (
+	"fmt"
+	"time"
+)
+
+// Format is an audio container chatter can join and write
+type Format string
+
+const (
+	MP3 Format = "mp3"
+	WAV Format = "wav"
+)
+
+// Detect returns the format of an audio file, assuming mp3 for anything that isn't a WAV file
+func Detect(data []byte) Format {
+	if IsWAV(data) {
+		return WAV
+	}
+	return MP3
+}
+
+// Track joins pieces of audio and silence into a single file. The first piece appended decides the format.
+type Track struct {
+	format    Format
+	mp3       []byte
+	wavFormat WAVFormat
+	pcm       []byte
+	pending   time.Duration
+}
+
+// Format returns the format of the joined audio, mp3 until something has been appended
+func (t *Track) Format() Format {
+	if t.format == "" {
+		return MP3
+	}
+	return t.format
+}
+
+// Append adds audio to the end of the track and returns how long it plays for
+func (t *Track) Append(data []byte) (time.Duration, error) {
+	format := Detect(data)
+	if t.format == "" {
+		t.format = format
+		if format == WAV {
+			f, _, err := ParseWAV(data)
+			if err != nil {
+				return 0, err
+			}
+			t.wavFormat = f
+		}
+		if t.pending > 0 && format == MP3 {
+			t.mp3 = Silence(t.pending, data)
+		} else if t.pending > 0 {
+			t.AppendSilence(t.pending)
+		}
+		t.pending = 0
+	} else if format != t.format {
+		return 0, fmt.Errorf("cannot join %s audio onto a %s track", format, t.format)
+	}
+
+	if format == MP3 {
+		t.mp3 = append(t.mp3, SkipID3(data)...)
+		return Duration(data), nil
+	}
+	f, pcm, err := ParseWAV(data)
+	if err != nil {
+		return 0, err
+	}
+	if f != t.wavFormat {
+		return 0, fmt.Errorf("cannot join %s audio onto a %s track", f, t.wavFormat)
+	}
+	t.pcm = append(t.pcm, pcm...)
+	return f.Duration(len(pcm)), nil
+}
+
+// AppendSilence adds d of silence and returns the length actually added, which is rounded to whole frames.
+// Silence at the start of a track is held until the format is known.
+func (t *Track) AppendSilence(d time.Duration) time.Duration {
+	switch t.format {
+	case "":
+		t.pending += d
+		return d
+	case WAV:
+		silence := pcmSilence(t.wavFormat, d)
+		t.pcm = append(t.pcm, silence...)
+		return t.wavFormat.Duration(len(silence))
+	default:
+		silence := Silence(d, t.mp3)
+		t.mp3 = append(t.mp3, silence...)
+		return Duration(silence)
+	}
+}
+
+// Bytes returns the joined audio
+func (t *Track) Bytes() []byte {
+	if t.format == "" && t.pending > 0 {
+		return Silence(t.pending, nil)
+	}
+	if t.format == WAV {
+		return EncodeWAV(t.wavFormat, t.pcm)
+	}
+	return t.mp3
+}
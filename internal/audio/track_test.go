@@ -0,0 +1,75 @@
+package audio_test
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var mono16k = audio.WAVFormat{AudioFormat: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16}
+
+func TestParseWAV(t *testing.T) {
+	t.Parallel()
+
+	pcm := bytes.Repeat([]byte{1, 2}, 1600)
+	data := audio.EncodeWAV(mono16k, pcm)
+	assert.True(t, audio.IsWAV(data))
+	assert.Equal(t, audio.WAV, audio.Detect(data))
+
+	f, got, err := audio.ParseWAV(data)
+	require.NoError(t, err)
+	assert.Equal(t, mono16k, f)
+	assert.Equal(t, pcm, got)
+	assert.Equal(t, 100*time.Millisecond, f.Duration(len(got)))
+
+	_, _, err = audio.ParseWAV([]byte("RIFF...."))
+	assert.Error(t, err)
+
+	for _, f := range []audio.WAVFormat{{AudioFormat: 1, SampleRate: 16000, BitsPerSample: 16}, {AudioFormat: 1, Channels: 1, BitsPerSample: 16}} {
+		_, _, err = audio.ParseWAV(audio.EncodeWAV(f, pcm))
+		assert.ErrorContains(t, err, "WAV fmt chunk has", "%+v", f)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	t.Parallel()
+
+	t.Run("joins wav with silence", func(t *testing.T) {
+		t.Parallel()
+		var track audio.Track
+		assert.Equal(t, 50*time.Millisecond, track.AppendSilence(50*time.Millisecond))
+		d, err := track.Append(audio.EncodeWAV(mono16k, bytes.Repeat([]byte{1, 0}, 1600)))
+		require.NoError(t, err)
+		assert.Equal(t, 100*time.Millisecond, d)
+		assert.Equal(t, 200*time.Millisecond, track.AppendSilence(200*time.Millisecond))
+
+		assert.Equal(t, audio.WAV, track.Format())
+		f, pcm, err := audio.ParseWAV(track.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, 350*time.Millisecond, f.Duration(len(pcm)))
+		assert.Equal(t, make([]byte, 1600), pcm[:1600], "leading silence")
+	})
+
+	t.Run("joins mp3", func(t *testing.T) {
+		t.Parallel()
+		var track audio.Track
+		_, err := track.Append(mpegFrame())
+		require.NoError(t, err)
+		track.AppendSilence(time.Second)
+		assert.Equal(t, audio.MP3, track.Format())
+		assert.Equal(t, mpegFrame(), track.Bytes()[:417])
+		assert.InDelta(t, time.Second+1152*time.Second/44100, audio.Duration(track.Bytes()), float64(30*time.Millisecond))
+	})
+
+	t.Run("refuses to mix formats", func(t *testing.T) {
+		t.Parallel()
+		var track audio.Track
+		_, err := track.Append(mpegFrame())
+		require.NoError(t, err)
+		_, err = track.Append(audio.EncodeWAV(mono16k, nil))
+		assert.EqualError(t, err, "cannot join wav audio onto a mp3 track")
+	})
+}
@@ -0,0 +1,117 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WAVFormat is the PCM layout described by a WAV file's fmt chunk
+type WAVFormat struct {
+	AudioFormat   uint16
+	Channels      uint16
+	SampleRate    uint32
+	BitsPerSample uint16
+}
+
+func (f WAVFormat) blockAlign() int {
+	return int(f.Channels) * int(f.BitsPerSample) / 8
+}
+
+func (f WAVFormat) byteRate() int {
+	return int(f.SampleRate) * f.blockAlign()
+}
+
+// Duration returns how long n bytes of PCM data in this format play for
+func (f WAVFormat) Duration(n int) time.Duration {
+	if f.byteRate() == 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second / time.Duration(f.byteRate())
+}
+
+// IsWAV reports whether data starts with a RIFF/WAVE header
+func IsWAV(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+// ParseWAV returns the format and PCM samples of a WAV file. A data chunk whose size runs past the
+// end of the file, as written by tools streaming to stdout, is read to the end.
+func ParseWAV(data []byte) (WAVFormat, []byte, error) {
+	if !IsWAV(data) {
+		return WAVFormat{}, nil, errors.New("not a WAV file")
+	}
+	var format WAVFormat
+	haveFormat := false
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8:]
+		if size < 0 || size > len(body) {
+			size = len(body)
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return WAVFormat{}, nil, errors.New("WAV fmt chunk is too short")
+			}
+			format = WAVFormat{
+				AudioFormat:   binary.LittleEndian.Uint16(body[0:2]),
+				Channels:      binary.LittleEndian.Uint16(body[2:4]),
+				SampleRate:    binary.LittleEndian.Uint32(body[4:8]),
+				BitsPerSample: binary.LittleEndian.Uint16(body[14:16]),
+			}
+			if format.Channels == 0 || format.SampleRate == 0 {
+				return WAVFormat{}, nil, fmt.Errorf("WAV fmt chunk has %d channels at %d Hz", format.Channels, format.SampleRate)
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return WAVFormat{}, nil, errors.New("WAV data chunk before fmt chunk")
+			}
+			return format, body[:size], nil
+		}
+		pos += 8 + size + size%2
+	}
+	return WAVFormat{}, nil, errors.New("WAV file has no data chunk")
+}
+
+// EncodeWAV wraps PCM samples in a WAV header
+func EncodeWAV(format WAVFormat, pcm []byte) []byte {
+	out := make([]byte, 44, 44+len(pcm))
+	copy(out[0:], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(36+len(pcm)))
+	copy(out[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(out[16:], 16)
+	binary.LittleEndian.PutUint16(out[20:], format.AudioFormat)
+	binary.LittleEndian.PutUint16(out[22:], format.Channels)
+	binary.LittleEndian.PutUint32(out[24:], format.SampleRate)
+	binary.LittleEndian.PutUint32(out[28:], uint32(format.byteRate()))
+	binary.LittleEndian.PutUint16(out[32:], uint16(format.blockAlign()))
+	binary.LittleEndian.PutUint16(out[34:], format.BitsPerSample)
+	copy(out[36:], "data")
+	binary.LittleEndian.PutUint32(out[40:], uint32(len(pcm)))
+	return append(out, pcm...)
+}
+
+// pcmSilence returns d of zeroed samples, aligned to whole frames
+func pcmSilence(format WAVFormat, d time.Duration) []byte {
+	align := format.blockAlign()
+	if align == 0 || d <= 0 {
+		return nil
+	}
+	frames := int(time.Duration(format.SampleRate) * d / time.Second)
+	silence := make([]byte, frames*align)
+	if format.BitsPerSample == 8 {
+		// 8 bit PCM is unsigned, silence sits at the midpoint
+		for i := range silence {
+			silence[i] = 0x80
+		}
+	}
+	return silence
+}
+
+func (f WAVFormat) String() string {
+	return fmt.Sprintf("%d Hz, %d channel(s), %d bit", f.SampleRate, f.Channels, f.BitsPerSample)
+}
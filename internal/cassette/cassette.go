@@ -0,0 +1,259 @@
+// Package cassette records HTTP interactions to a file and replays them, so tests can exercise real
+// responses without the network. A Recorder satisfies the client's HTTP interface.
+//
+// Re-record a test's cassettes against the real services, which costs credits, with
+//
+//	CHATTER_CASSETTES=record XI_API_KEY=<key> go test ./internal/client -run TestClient_Replay
+//
+// API keys, cookies and request IDs are redacted, but check the diff for anything else that identifies the
+// account before committing.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+// Mode selects whether a Recorder replays a cassette or records a new one
+type Mode int
+
+const (
+	// Replay answers requests from the cassette and fails on any request it doesn't hold
+	Replay Mode = iota
+	// Record sends requests to the real client and saves the interactions to the cassette
+	Record
+)
+
+// Redacted replaces the value of sensitive headers in recorded requests and responses
+const Redacted = "REDACTED"
+
+// sensitiveHeaders are scrubbed from requests before an interaction is stored
+var sensitiveHeaders = []string{"xi-api-key", "Authorization", "Cookie"}
+
+// sensitiveResponseHeaders are scrubbed from responses, as they identify the account or the request
+var sensitiveResponseHeaders = []string{"Set-Cookie", "Request-Id", "X-Request-Id", "History-Item-Id", "X-Account-Id"}
+
+// Cassette is the file format: every interaction in the order it happened. Note says where a hand written
+// cassette came from, and is dropped when the cassette is recorded again.
+type Cassette struct {
+	Note         string        `json:"note,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response it received
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is stored as text when it is valid UTF-8, and base64 encoded otherwise, so HTML and JSON stay readable
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return marshal(map[string]string{"text": string(b)}, "")
+	}
+	return marshal(map[string][]byte{"base64": b}, "")
+}
+
+// marshal encodes v without escaping HTML, which would make recorded pages unreadable
+func marshal(v any, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Text   *string `json:"text"`
+		Base64 []byte  `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Text != nil {
+		*b = Body(*v.Text)
+	} else {
+		*b = v.Base64
+	}
+	return nil
+}
+
+// HTTP is the client a Recorder sends requests through when recording
+type HTTP interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Recorder records or replays the interactions in a cassette file
+type Recorder struct {
+	path string
+	mode Mode
+	real HTTP
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// New returns a recorder for the cassette at path. Replay loads the cassette, Record starts an empty one
+// that is written by Save, sending requests through real.
+func New(path string, mode Mode, real HTTP) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, real: real}
+	if mode == Record {
+		if real == nil {
+			return nil, errors.New("cassette: recording needs a client")
+		}
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	if err = json.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("cassette: failed to decode %s: %w", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Do records or replays a request
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == Record {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: failed to read request body: %w", err)
+	}
+	if err = req.Body.Close(); err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	res, err := r.real.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  Request{Method: req.Method, URL: req.URL.String(), Header: redact(req.Header, sensitiveHeaders), Body: body},
+		Response: Response{StatusCode: res.StatusCode, Header: redact(res.Header, sensitiveResponseHeaders), Body: resBody},
+	})
+	r.mu.Unlock()
+
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+	return res, nil
+}
+
+// redact returns a copy of header with the values of the named headers replaced
+func redact(header http.Header, names []string) http.Header {
+	header = header.Clone()
+	for _, h := range names {
+		if header.Get(h) != "" {
+			header.Set(h, Redacted)
+		}
+	}
+	return header
+}
+
+// replay answers with the first unused interaction with the same method, URL and body
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || in.Request.Method != req.Method || in.Request.URL != req.URL.String() || !bytes.Equal(in.Request.Body, body) {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Header:        in.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("cassette: %s has no interaction for %s %s", r.path, req.Method, req.URL)
+}
+
+// Unused returns the recorded interactions that were never replayed
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, in := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+// Save writes the recorded interactions to the cassette file. It does nothing when replaying.
+func (r *Recorder) Save() error {
+	if r.mode != Record {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := marshal(r.cassette, "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0644)
+}
@@ -0,0 +1,76 @@
+package cassette_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/cassette"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=cookie-value")
+		w.Header().Set("Request-Id", "request-value")
+		if r.Method == http.MethodPost {
+			_, _ = w.Write(append([]byte{0xFF, 0xFB}, body...))
+			return
+		}
+		_, _ = w.Write([]byte("<html>hello</html>"))
+	}))
+	t.Cleanup(server.Close)
+
+	do := func(t *testing.T, c cassette.HTTP, method, body string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+"/page", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("xi-api-key", "secret")
+		res, err := c.Do(req)
+		require.NoError(t, err)
+		defer func() { _ = res.Body.Close() }()
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, string(data)
+	}
+
+	path := filepath.Join(t.TempDir(), "nested", "cassette.json")
+	recorder, err := cassette.New(path, cassette.Record, http.DefaultClient)
+	require.NoError(t, err)
+	_, page := do(t, recorder, http.MethodGet, "")
+	_, audio := do(t, recorder, http.MethodPost, "tts")
+	require.NoError(t, recorder.Save())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret", "api key is scrubbed")
+	assert.NotContains(t, string(data), "cookie-value", "cookies are scrubbed")
+	assert.NotContains(t, string(data), "request-value", "request ids are scrubbed")
+	assert.Contains(t, string(data), `"text": "<html>hello</html>"`, "text bodies stay readable")
+	assert.Contains(t, string(data), `"base64": "//t0dHM="`, "binary bodies are base64 encoded")
+
+	player, err := cassette.New(path, cassette.Replay, nil)
+	require.NoError(t, err)
+	status, replayed := do(t, player, http.MethodPost, "tts")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, audio, replayed)
+	assert.Len(t, player.Unused(), 1)
+	_, replayed = do(t, player, http.MethodGet, "")
+	assert.Equal(t, page, replayed)
+	assert.Empty(t, player.Unused())
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/page", strings.NewReader("other"))
+	require.NoError(t, err)
+	_, err = player.Do(req)
+	assert.ErrorContains(t, err, "has no interaction for POST "+server.URL+"/page")
+
+	_, err = cassette.New(filepath.Join(t.TempDir(), "missing.json"), cassette.Replay, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package cassette
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ModeEnv is the environment variable that switches ForTest to recording when set to "record"
+const ModeEnv = "CHATTER_CASSETTES"
+
+// Dir is where ForTest keeps cassettes, relative to the test's package
+var Dir = filepath.Join("testdata", "cassettes")
+
+// ForTest returns a recorder for the running test or subtest, using testdata/cassettes/<test name>.json.
+// Cassettes are replayed, and every interaction must be used, unless CHATTER_CASSETTES=record, in which case
+// real requests are made and the cassette is rewritten when the test finishes.
+func ForTest(t testing.TB) *Recorder {
+	t.Helper()
+	path := filepath.Join(Dir, filepath.FromSlash(strings.ReplaceAll(t.Name(), " ", "_"))+".json")
+
+	if os.Getenv(ModeEnv) == "record" {
+		r, err := New(path, Record, http.DefaultClient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if err := r.Save(); err != nil {
+				t.Errorf("failed to save cassette: %v", err)
+			}
+		})
+		return r
+	}
+
+	r, err := New(path, Replay, nil)
+	if err != nil {
+		t.Fatalf("%v (record it with %s=record)", err, ModeEnv)
+	}
+	t.Cleanup(func() {
+		if unused := r.Unused(); len(unused) > 0 && !t.Failed() {
+			t.Errorf("cassette %s has %d unused interactions, starting with %s %s",
+				path, len(unused), unused[0].Request.Method, unused[0].Request.URL)
+		}
+	})
+	return r
+}
+
+// APIKey returns XI_API_KEY when recording, so real requests are authorised, and a placeholder when replaying
+func APIKey() string {
+	if os.Getenv(ModeEnv) == "record" {
+		return os.Getenv("XI_API_KEY")
+	}
+	return "test-key"
+}
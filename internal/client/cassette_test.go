@@ -0,0 +1,66 @@
+package client_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/cassette"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestClient_Replay runs against recorded interactions in testdata/cassettes. Re-record them against the
+// real services with CHATTER_CASSETTES=record XI_API_KEY=<key> go test ./internal/client -run TestClient_Replay
+func TestClient_Replay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		apiKey string
+		run    func(c *client.ElevenLabs) (any, error)
+		want   any
+		error  string
+	}{
+		{
+			name: "website",
+			run: func(c *client.ElevenLabs) (any, error) {
+				return c.FromWebsite("https://example.com/")
+			},
+			want: []string{"Example Domain\nExample Domain\nThis domain is for use in illustrative examples in documents.\n"},
+		},
+		{
+			name: "text",
+			run: func(c *client.ElevenLabs) (any, error) {
+				data, err := c.FromText("Hello world.", "21m00Tcm4TlvDq8ikWAM")
+				return audio.Duration(data).Round(time.Millisecond), err
+			},
+			want: 78 * time.Millisecond,
+		},
+		{
+			name:   "invalid key",
+			apiKey: "invalid",
+			run: func(c *client.ElevenLabs) (any, error) {
+				return c.FromText("Hello world.", "21m00Tcm4TlvDq8ikWAM")
+			},
+			error: "request failed: 401 Unauthorized",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config.AppConfig{CharacterRequestLimit: 1000, APIKey: cassette.APIKey()}
+			if tt.apiKey != "" {
+				cfg.APIKey = tt.apiKey
+			}
+			got, err := tt.run(client.New(cfg, cassette.ForTest(t)))
+			if tt.error != "" {
+				assert.ErrorContains(t, err, tt.error)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -2,15 +2,19 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/markup"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"net/url"
+	"strconv"
 	"strings"
-	"time"
 	"unicode/utf8"
 )
 
@@ -29,8 +33,9 @@ type elevenRequest struct {
 type voiceSettings struct {
 	Stability       float64 `json:"stability"`
 	SimilarityBoost float64 `json:"similarity_boost"`
-	Style           int     `json:"style,omitempty"`
+	Style           float64 `json:"style,omitempty"`
 	UseSpeakerBoost bool    `json:"use_speaker_boost,omitempty"`
+	Speed           float64 `json:"speed,omitempty"`
 }
 
 type pronunciationDictionaryLocators struct {
@@ -38,7 +43,15 @@ type pronunciationDictionaryLocators struct {
 	VersionID                 string `json:"version_id,omitempty"`
 }
 
+// DefaultModelID is used when no model is configured
+const DefaultModelID = "eleven_monolingual_v1"
+
+// DefaultBaseURL is the Eleven Labs API used when no base URL is configured
+const DefaultBaseURL = "https://api.elevenlabs.io"
+
+// ElevenLabs is the Eleven Labs API client and the default Synthesizer
 type ElevenLabs struct {
+	*Pipeline
 	httpClient HTTP
 	Config     *config.AppConfig
 }
@@ -47,62 +60,42 @@ type HTTP interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-func (c *ElevenLabs) fileWithTimestamp() string {
-	currentTime := time.Now()
-	formattedTime := currentTime.Format("20060102_150405")
-	prefix := ""
-	if c.Config.OutputDir == "" {
-		prefix = "output_"
-	} else if !strings.HasSuffix(c.Config.OutputDir, string(os.PathSeparator)) {
-		prefix = c.Config.OutputDir + string(os.PathSeparator)
-	}
-	return prefix + formattedTime + ".mp3"
-}
-
-func (c *ElevenLabs) write(data []byte) (int, error) {
-	err := os.WriteFile(c.fileWithTimestamp(), data, 0644)
-	if err != nil {
-		return 0, err
-	}
-
-	return len(data), nil
-}
-
+// New returns an Eleven Labs client. Its embedded pipeline synthesizes with the client itself.
 func New(cfg *config.AppConfig, httpClient HTTP) *ElevenLabs {
-	return &ElevenLabs{
+	c := &ElevenLabs{
 		Config:     cfg,
 		httpClient: httpClient,
 	}
+	c.Pipeline = NewPipeline(cfg, httpClient, c)
+	return c
 }
 
-func (c *ElevenLabs) ProcessText() error {
-	fromText, err := c.FromText(c.Config.TextInput, c.Config.VoiceID)
-	if err != nil {
-		return err
+// Synthesize converts text to speech, with character timings when requested
+func (c *ElevenLabs) Synthesize(req SynthesisRequest) (*Synthesis, error) {
+	if req.Timestamps {
+		return c.fromTextWithTimestamps(req.context(), req.Text, req.VoiceID, req.Settings)
 	}
-	_, err = c.write(fromText)
-	return err
+	return c.fromText(req.context(), req.Text, req.VoiceID, req.Settings)
+}
+
+// MarkupSupport returns the markup the configured model renders natively
+func (c *ElevenLabs) MarkupSupport() markup.Support {
+	return markup.SupportFor(c.modelID())
 }
 
-func (c *ElevenLabs) ProcessSite() error {
-	texts, err := c.FromWebsite(c.Config.WebsiteURL)
+func (c *ElevenLabs) FromText(text string, voiceID string) ([]byte, error) {
+	s, err := c.fromText(c.context(), text, voiceID, c.Config.VoiceSettings)
 	if err != nil {
-		return err
-	}
-	for _, text := range texts {
-		fromText, tErr := c.FromText(text, c.Config.VoiceID)
-		if tErr != nil {
-			return tErr
-		}
-		_, err = c.write(fromText)
-		if err != nil {
-			return err
-		}
+		return nil, err
 	}
-	return nil
+	return s.Audio, nil
 }
 
-func (c *ElevenLabs) FromText(text string, voiceID string) ([]byte, error) {
+func (c *ElevenLabs) fromText(ctx context.Context, text string, voiceID string, settings config.VoiceSettings) (_ *Synthesis, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "FromText", attribute.String("voice_id", voiceID),
+		attribute.Int("characters", utf8.RuneCountInString(text)))
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	if count := utf8.RuneCountInString(text); count > c.Config.CharacterRequestLimit {
 		return nil, fmt.Errorf("text limit is %d characters, got :%d", c.Config.CharacterRequestLimit, count)
 	}
@@ -110,63 +103,113 @@ func (c *ElevenLabs) FromText(text string, voiceID string) ([]byte, error) {
 		return nil, fmt.Errorf("voice ID is required")
 	}
 
-	payload, err := buildPayload(text)
+	payload, err := c.buildPayload(text, settings)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build payload: %w", err)
 	}
 
-	req, err := buildRequest(c.Config.APIKey, voiceID, payload)
+	req, err := buildRequest(ctx, c.Config.APIKey, c.ttsURL(voiceID, ""), "audio/mpeg", payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
 
-	body, err := c.doRequest(req)
+	body, header, err := sendRequest(c.httpClient, req)
 	if err != nil {
 		return nil, err
 	}
-	return body, nil
+	return c.synthesis(c.decodeAudio(body), header), nil
+}
+
+// synthesis describes the audio of a response, with the model, request ID and characters billed
+func (c *ElevenLabs) synthesis(data []byte, header http.Header) *Synthesis {
+	characters, _ := strconv.Atoi(header.Get("x-character-count"))
+	return &Synthesis{Audio: data, Model: c.modelID(), Characters: characters, RequestID: header.Get("request-id")}
+}
+
+// modelID returns the configured model, or DefaultModelID
+func (c *ElevenLabs) modelID() string {
+	if c.Config.ModelID != "" {
+		return c.Config.ModelID
+	}
+	return DefaultModelID
 }
 
-func buildPayload(text string) ([]byte, error) {
+func (c *ElevenLabs) buildPayload(text string, settings config.VoiceSettings) ([]byte, error) {
 	elvenReq := elevenRequest{
 		Text:    text,
-		ModelID: "eleven_monolingual_v1",
+		ModelID: c.modelID(),
 		VoiceSettings: voiceSettings{
-			Stability:       0,
-			SimilarityBoost: 0,
+			Stability:       settings.Stability,
+			SimilarityBoost: settings.SimilarityBoost,
+			Style:           settings.Style,
+			UseSpeakerBoost: settings.UseSpeakerBoost,
+			Speed:           settings.Speed,
 		},
 	}
+	for _, d := range c.Config.PronunciationDictionaries {
+		elvenReq.PronunciationDictionaryLocators = append(elvenReq.PronunciationDictionaryLocators, pronunciationDictionaryLocators{
+			PronunciationDictionaryID: d.ID,
+			VersionID:                 d.VersionID,
+		})
+	}
 	return json.Marshal(elvenReq)
 }
 
 func (c *ElevenLabs) doRequest(req *http.Request) ([]byte, error) {
-	res, err := c.httpClient.Do(req)
+	return doRequest(c.httpClient, req)
+}
+
+// doRequest sends the request and returns the response body, failing on any status but 200
+func doRequest(httpClient HTTP, req *http.Request) ([]byte, error) {
+	body, _, err := sendRequest(httpClient, req)
+	return body, err
+}
+
+// sendRequest is doRequest, also returning the response headers
+func sendRequest(httpClient HTTP, req *http.Request) ([]byte, http.Header, error) {
+	res, err := httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer func() {
 		if closeErr := res.Body.Close(); closeErr != nil {
-			log.Printf("error closing response body: %v", closeErr)
+			slog.Warn("error closing response body", "err", closeErr)
 		}
 	}()
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed: %s, body:%v", res.Status, string(body))
+		return nil, nil, fmt.Errorf("request failed: %s, body:%v", res.Status, string(body))
+	}
+	return body, res.Header, nil
+}
+
+// baseURL returns the configured API base URL, or DefaultBaseURL
+func (c *ElevenLabs) baseURL() string {
+	if c.Config.BaseURL != "" {
+		return strings.TrimSuffix(c.Config.BaseURL, "/")
+	}
+	return DefaultBaseURL
+}
+
+// ttsURL returns the text-to-speech endpoint for the voice, followed by suffix, asking for the configured output format
+func (c *ElevenLabs) ttsURL(voiceID, suffix string) string {
+	u := fmt.Sprintf("%s/v1/text-to-speech/%s%s", c.baseURL(), voiceID, suffix)
+	if c.Config.OutputFormat != "" {
+		u += "?output_format=" + url.QueryEscape(c.Config.OutputFormat)
 	}
-	return body, nil
+	return u
 }
 
-func buildRequest(apiKey, voiceID string, payload []byte) (*http.Request, error) {
-	url := fmt.Sprintf("https://api.elevenlabs.io/v1/text-to-speech/%s", voiceID)
-	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+func buildRequest(ctx context.Context, apiKey, url, accept string, payload []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Accept", "audio/mpeg")
+	req.Header.Add("Accept", accept)
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("xi-api-key", apiKey)
 	return req, nil
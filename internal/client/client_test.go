@@ -2,10 +2,16 @@ package client_test
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
 	"github.com/sgerhardt/chatter/internal/client"
 	"github.com/sgerhardt/chatter/internal/client/mocks"
 	"github.com/sgerhardt/chatter/internal/config"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"errors"
 	"github.com/stretchr/testify/assert"
@@ -16,6 +22,16 @@ import (
 	"testing"
 )
 
+// lookUpVoice answers the voice lookup made for the artist tag, before any other expectation matches it
+func lookUpVoice(client *mocks.HTTP, name string) {
+	client.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/v1/voices/")
+	})).Return(func(req *http.Request) (*http.Response, error) {
+		body := fmt.Sprintf(`{"voice_id":%q,"name":%q}`, strings.TrimPrefix(req.URL.Path, "/v1/voices/"), name)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}).Once()
+}
+
 func TestClient_ProcessText(t *testing.T) {
 	t.Parallel()
 
@@ -56,6 +72,7 @@ func TestClient_ProcessText(t *testing.T) {
 			},
 
 			mockSetup: func(client *mocks.HTTP) {
+				lookUpVoice(client, "Stephen")
 				mockResp := &http.Response{
 					StatusCode: http.StatusOK,
 					Body:       io.NopCloser(bytes.NewReader([]byte("bytes representing the mp3 file..."))),
@@ -104,7 +121,8 @@ func TestClient_ProcessText(t *testing.T) {
 			// verify the contents of the file
 			file, err := os.ReadFile(tt.fields.outputFilePath + string(os.PathSeparator) + files[0].Name())
 			require.NoError(t, err)
-			assert.Equal(t, "bytes representing the mp3 file...", string(file))
+			assert.True(t, bytes.HasPrefix(file, []byte("ID3")), "expected an ID3 tag at the start of the file")
+			assert.Equal(t, "bytes representing the mp3 file...", string(audio.SkipID3(file)))
 
 			mockClient.AssertExpectations(t)
 		})
@@ -166,6 +184,7 @@ func TestClient_ProcessSite(t *testing.T) {
 			},
 
 			mockSetup: func(client *mocks.HTTP) {
+				lookUpVoice(client, "Stephen")
 				// First fetch the website
 				mockURLResponse := &http.Response{
 					StatusCode: http.StatusOK,
@@ -222,9 +241,88 @@ func TestClient_ProcessSite(t *testing.T) {
 			// verify the contents of the file
 			file, err := os.ReadFile(tt.fields.outputFilePath + string(os.PathSeparator) + files[0].Name())
 			require.NoError(t, err)
-			assert.Equal(t, "bytes representing the mp3 file...", string(file))
+			assert.True(t, bytes.HasPrefix(file, []byte("ID3")), "expected an ID3 tag at the start of the file")
+			assert.Equal(t, "bytes representing the mp3 file...", string(audio.SkipID3(file)))
 
 			mockClient.AssertExpectations(t)
 		})
 	}
 }
+
+func TestClient_ProcessTextWithSubtitles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	mockClient := mocks.NewHTTP(t)
+	lookUpVoice(mockClient, "Stephen")
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.Path == "/v1/text-to-speech/stephen_hawking/with-timestamps"
+	})).Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body: io.NopCloser(strings.NewReader(`{"audio_base64":"` + base64.StdEncoding.EncodeToString([]byte("mp3")) + `",
+			"alignment":{"characters":["H","i","."],"character_start_times_seconds":[0,0.1,0.2],"character_end_times_seconds":[0.1,0.2,0.3]}}`)),
+	}, nil).Once()
+
+	cfg := &config.AppConfig{
+		CharacterRequestLimit: 100,
+		OutputDir:             dir,
+		APIKey:                "123",
+		VoiceID:               "stephen_hawking",
+		TextInput:             "Hi.",
+		Subtitles:             true,
+	}
+	require.NoError(t, client.New(cfg, mockClient).ProcessText())
+
+	srt, err := filepath.Glob(filepath.Join(dir, "*.srt"))
+	require.NoError(t, err)
+	require.Len(t, srt, 1)
+	contents, err := os.ReadFile(srt[0])
+	require.NoError(t, err)
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:00,300\nHi.\n\n", string(contents))
+
+	vtt, err := filepath.Glob(filepath.Join(dir, "*.vtt"))
+	require.NoError(t, err)
+	assert.Len(t, vtt, 1)
+
+	mp3, err := os.ReadFile(strings.TrimSuffix(srt[0], ".srt") + ".mp3")
+	require.NoError(t, err)
+	assert.Equal(t, "mp3", string(audio.SkipID3(mp3)))
+}
+
+func TestClient_ProcessTextWithMarkup(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid markup is rejected before anything is sent", func(t *testing.T) {
+		t.Parallel()
+		cfg := &config.AppConfig{CharacterRequestLimit: 100, OutputDir: t.TempDir(), VoiceID: "narrator", TextInput: `Hi <break time="soon"/>`}
+		err := client.New(cfg, mocks.NewHTTP(t)).ProcessText()
+		assert.EqualError(t, err, `invalid markup: <break> at offset 3: invalid time "soon"`)
+	})
+
+	t.Run("splits at breaks and voice switches the model cannot render", func(t *testing.T) {
+		t.Parallel()
+		var sent []string
+		mockClient := mocks.NewHTTP(t)
+		lookUpVoice(mockClient, "Narrator")
+		mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(func(req *http.Request) (*http.Response, error) {
+			var body struct {
+				Text    string `json:"text"`
+				ModelID string `json:"model_id"`
+			}
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+			assert.Equal(t, "eleven_multilingual_v1", body.ModelID)
+			sent = append(sent, strings.TrimPrefix(req.URL.Path, "/v1/text-to-speech/")+": "+body.Text)
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}).Times(3)
+
+		cfg := &config.AppConfig{
+			CharacterRequestLimit: 100,
+			OutputDir:             t.TempDir(),
+			VoiceID:               "narrator",
+			ModelID:               "eleven_multilingual_v1",
+			TextInput:             `One <break time="1s"/> two <voice name="villain">three</voice>`,
+		}
+		require.NoError(t, client.New(cfg, mockClient).ProcessText())
+		assert.Equal(t, []string{"narrator: One", "narrator: two", "villain: three"}, sent)
+	})
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/config"
+	"os/exec"
+	"strings"
+)
+
+// DefaultCommand is run by the command provider when no command is configured
+const DefaultCommand = "espeak-ng --stdout -v {voice}"
+
+// Command synthesizes speech with a local program such as piper or espeak-ng. The text is written to the
+// program's stdin and the mp3 or WAV audio read from its stdout.
+type Command struct {
+	Config *config.AppConfig
+}
+
+func NewCommand(cfg *config.AppConfig) *Command {
+	return &Command{Config: cfg}
+}
+
+// args splits the configured command into arguments, replacing {voice} with the voice ID
+func (c *Command) args(voiceID string) ([]string, error) {
+	command := c.Config.Command
+	if command == "" {
+		command = DefaultCommand
+	}
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("command is empty")
+	}
+	for i, arg := range args {
+		args[i] = strings.ReplaceAll(arg, "{voice}", voiceID)
+	}
+	return args, nil
+}
+
+// Synthesize runs the command for the text
+func (c *Command) Synthesize(req SynthesisRequest) (*Synthesis, error) {
+	if req.Timestamps {
+		return nil, ErrNoTimestamps
+	}
+	args, err := c.args(req.VoiceID)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(req.context(), args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(req.Text)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", args[0], err)
+	}
+	if stdout.Len() == 0 {
+		return nil, errors.New(args[0] + " produced no audio")
+	}
+	return &Synthesis{Audio: stdout.Bytes()}, nil
+}
@@ -0,0 +1,43 @@
+package client_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os/exec"
+	"testing"
+)
+
+func TestCommand_Synthesize(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("coreutils not available")
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		want    string
+		error   string
+	}{
+		{name: "passes text on stdin", command: "cat", want: "hello"},
+		{name: "replaces the voice placeholder", command: "echo {voice}", want: "en-gb\n"},
+		{name: "reports stderr when the command fails", command: "ls /nonexistent-chatter", error: "ls failed: exit status 2: ls: cannot access"},
+		{name: "fails without audio", command: "true", error: "true produced no audio"},
+		{name: "rejects a blank command", command: "  ", error: "command is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := client.NewCommand(&config.AppConfig{Command: tt.command})
+			res, err := c.Synthesize(client.SynthesisRequest{Text: "hello", VoiceID: "en-gb"})
+			if tt.error != "" {
+				assert.ErrorContains(t, err, tt.error)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(res.Audio))
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/lexicon"
+	"net/http"
+	"net/url"
+)
+
+// PronunciationDictionary describes a dictionary stored in Eleven Labs
+type PronunciationDictionary struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	LatestVersionID  string `json:"latest_version_id"`
+	Description      string `json:"description"`
+	CreatedBy        string `json:"created_by"`
+	CreationTimeUnix int64  `json:"creation_time_unix"`
+}
+
+// DictionaryVersion identifies one version of a pronunciation dictionary
+type DictionaryVersion struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	VersionID string `json:"version_id"`
+}
+
+// CreateDictionary creates a pronunciation dictionary from a set of rules
+func (c *ElevenLabs) CreateDictionary(name, description string, rules []lexicon.Rule) (*DictionaryVersion, error) {
+	body := struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Rules       []lexicon.Rule `json:"rules"`
+	}{name, description, rules}
+	var version DictionaryVersion
+	if err := c.apiJSON(http.MethodPost, "/v1/pronunciation-dictionaries/add-from-rules", body, &version); err != nil {
+		return nil, fmt.Errorf("failed to create dictionary: %w", err)
+	}
+	return &version, nil
+}
+
+// AddDictionaryRules adds rules to a dictionary, replacing rules for the same strings, and returns the new version
+func (c *ElevenLabs) AddDictionaryRules(id string, rules []lexicon.Rule) (*DictionaryVersion, error) {
+	body := struct {
+		Rules []lexicon.Rule `json:"rules"`
+	}{rules}
+	var version DictionaryVersion
+	if err := c.apiJSON(http.MethodPost, "/v1/pronunciation-dictionaries/"+url.PathEscape(id)+"/add-rules", body, &version); err != nil {
+		return nil, fmt.Errorf("failed to add rules: %w", err)
+	}
+	return &version, nil
+}
+
+// ListDictionaries returns every pronunciation dictionary on the account
+func (c *ElevenLabs) ListDictionaries() ([]PronunciationDictionary, error) {
+	var all []PronunciationDictionary
+	cursor := ""
+	for {
+		query := url.Values{"page_size": {"100"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		var page struct {
+			Dictionaries []PronunciationDictionary `json:"pronunciation_dictionaries"`
+			NextCursor   string                    `json:"next_cursor"`
+			HasMore      bool                      `json:"has_more"`
+		}
+		if err := c.apiJSON(http.MethodGet, "/v1/pronunciation-dictionaries?"+query.Encode(), nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list dictionaries: %w", err)
+		}
+		all = append(all, page.Dictionaries...)
+		if !page.HasMore || page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// GetDictionary returns a dictionary's metadata, including its latest version
+func (c *ElevenLabs) GetDictionary(id string) (*PronunciationDictionary, error) {
+	var dict PronunciationDictionary
+	if err := c.apiJSON(http.MethodGet, "/v1/pronunciation-dictionaries/"+url.PathEscape(id), nil, &dict); err != nil {
+		return nil, fmt.Errorf("failed to get dictionary: %w", err)
+	}
+	return &dict, nil
+}
+
+// DownloadDictionary returns a version of a dictionary as a PLS document
+func (c *ElevenLabs) DownloadDictionary(id, versionID string) ([]byte, error) {
+	req, err := c.apiRequest(http.MethodGet, "/v1/pronunciation-dictionaries/"+url.PathEscape(id)+"/"+url.PathEscape(versionID)+"/download", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	body, err := c.doRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download dictionary: %w", err)
+	}
+	return body, nil
+}
+
+// apiRequest builds an authenticated request against the Eleven Labs API, encoding body as JSON when set
+func (c *ElevenLabs) apiRequest(method, path string, body any) (*http.Request, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(c.context(), method, c.baseURL()+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	req.Header.Add("xi-api-key", c.Config.APIKey)
+	return req, nil
+}
+
+// apiJSON sends a JSON request and decodes the JSON response into out
+func (c *ElevenLabs) apiJSON(method, path string, body, out any) error {
+	req, err := c.apiRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+	res, err := c.doRequest(req)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err = json.Unmarshal(res, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package client_test
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/client/mocks"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/lexicon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func jsonResponse(body string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestClient_CreateDictionary(t *testing.T) {
+	t.Parallel()
+
+	mockClient := mocks.NewHTTP(t)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Method == http.MethodPost && req.URL.Path == "/v1/pronunciation-dictionaries/add-from-rules"
+	})).Return(jsonResponse(`{"id":"dict1","name":"products","version_id":"v1"}`), nil).Run(func(args mock.Arguments) {
+		req := args.Get(0).(*http.Request)
+		assert.Equal(t, "123", req.Header.Get("xi-api-key"))
+		body := new(bytes.Buffer)
+		_, err := body.ReadFrom(req.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"name":"products","rules":[{"string_to_replace":"chatter","type":"phoneme","phoneme":"ˈtʃætɚ","alphabet":"ipa"}]}`, body.String())
+	}).Once()
+
+	c := client.New(&config.AppConfig{APIKey: "123"}, mockClient)
+	version, err := c.CreateDictionary("products", "", []lexicon.Rule{
+		{StringToReplace: "chatter", Type: "phoneme", Phoneme: "ˈtʃætɚ", Alphabet: "ipa"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &client.DictionaryVersion{ID: "dict1", Name: "products", VersionID: "v1"}, version)
+}
+
+func TestClient_ListDictionaries(t *testing.T) {
+	t.Parallel()
+
+	mockClient := mocks.NewHTTP(t)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.Query().Get("cursor") == ""
+	})).Return(jsonResponse(`{"pronunciation_dictionaries":[{"id":"a","name":"first","latest_version_id":"v1"}],"has_more":true,"next_cursor":"next"}`), nil).Once()
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.Query().Get("cursor") == "next"
+	})).Return(jsonResponse(`{"pronunciation_dictionaries":[{"id":"b","name":"second","latest_version_id":"v2"}],"has_more":false}`), nil).Once()
+
+	dicts, err := client.New(&config.AppConfig{APIKey: "123"}, mockClient).ListDictionaries()
+	require.NoError(t, err)
+	assert.Equal(t, []client.PronunciationDictionary{
+		{ID: "a", Name: "first", LatestVersionID: "v1"},
+		{ID: "b", Name: "second", LatestVersionID: "v2"},
+	}, dicts)
+}
+
+func TestClient_FromTextWithDictionaries(t *testing.T) {
+	t.Parallel()
+
+	mockClient := mocks.NewHTTP(t)
+	mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(jsonResponse("mp3"), nil).Run(func(args mock.Arguments) {
+		body := new(bytes.Buffer)
+		_, err := body.ReadFrom(args.Get(0).(*http.Request).Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"text":"chatter","model_id":"eleven_monolingual_v1","voice_settings":{"stability":0,"similarity_boost":0},`+
+			`"pronunciation_dictionary_locators":[{"pronunciation_dictionary_id":"dict1","version_id":"v1"},{"pronunciation_dictionary_id":"dict2"}]}`, body.String())
+	}).Once()
+
+	cfg := &config.AppConfig{
+		CharacterRequestLimit:     100,
+		PronunciationDictionaries: []config.DictionaryLocator{{ID: "dict1", VersionID: "v1"}, {ID: "dict2"}},
+	}
+	_, err := client.New(cfg, mockClient).FromText("chatter", "voice")
+	require.NoError(t, err)
+}
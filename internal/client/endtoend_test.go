@@ -0,0 +1,192 @@
+package client_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryLedger keeps the usage it is given, or fails with err when it is set
+type memoryLedger struct {
+	entries []client.Usage
+	err     error
+}
+
+func (l *memoryLedger) Record(usage []client.Usage) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.entries = append(l.entries, usage...)
+	return nil
+}
+
+func TestEndToEnd(t *testing.T) {
+	t.Parallel()
+
+	newConfig := func(s *fakeeleven.Server) *config.AppConfig {
+		return &config.AppConfig{
+			CharacterRequestLimit: 10000,
+			OutputDir:             t.TempDir(),
+			APIKey:                "123",
+			BaseURL:               s.URL,
+			VoiceID:               fakeeleven.DefaultVoices[0].VoiceID,
+		}
+	}
+
+	t.Run("writes text with subtitles", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t, fakeeleven.WithAPIKey("123"))
+		cfg := newConfig(s)
+		cfg.TextInput = "Hello world."
+		cfg.Subtitles = true
+		require.NoError(t, client.New(cfg, s.Client()).ProcessText())
+
+		for _, ext := range []string{"*.mp3", "*.srt", "*.vtt"} {
+			files, err := filepath.Glob(filepath.Join(cfg.OutputDir, ext))
+			require.NoError(t, err)
+			assert.Len(t, files, 1, ext)
+		}
+		requests := s.Requests()
+		require.Len(t, requests, 2)
+		assert.Equal(t, "/v1/text-to-speech/"+cfg.VoiceID+"/with-timestamps", requests[0].Path)
+		assert.Equal(t, "/v1/voices/"+cfg.VoiceID, requests[1].Path)
+		assert.Equal(t, 12, s.CharacterCount())
+	})
+
+	t.Run("tags the artist with the voice name, or its ID when the lookup fails", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		cfg := newConfig(s)
+		cfg.TextInput = "Hello world."
+		require.NoError(t, client.New(cfg, s.Client()).ProcessText())
+		files, err := filepath.Glob(filepath.Join(cfg.OutputDir, "*.mp3"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		data, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(data), fakeeleven.DefaultVoices[0].Name)
+
+		cfg = newConfig(s)
+		cfg.TextInput = "Hello world."
+		s.Inject(fakeeleven.Fault{Path: "/v1/voices", Status: http.StatusInternalServerError})
+		require.NoError(t, client.New(cfg, s.Client()).ProcessText())
+		files, err = filepath.Glob(filepath.Join(cfg.OutputDir, "*.mp3"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		data, err = os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), fakeeleven.DefaultVoices[0].Name)
+		assert.Contains(t, string(data), cfg.VoiceID)
+	})
+
+	t.Run("records usage in the ledger", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		cfg := newConfig(s)
+		cfg.TextInput = "Hello world."
+		cfg.Profile = "work"
+		cfg.Tag = "podcast"
+		ledger := &memoryLedger{}
+		c := client.New(cfg, s.Client())
+		c.Ledger = ledger
+		require.NoError(t, c.ProcessText())
+
+		files, err := filepath.Glob(filepath.Join(cfg.OutputDir, "*.mp3"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Len(t, ledger.entries, 1)
+		u := ledger.entries[0]
+		assert.Equal(t, files[0], u.Output)
+		assert.Equal(t, "request0001", u.RequestID)
+		assert.Equal(t, 12, u.Characters)
+		assert.Equal(t, client.DefaultModelID, u.Model)
+		assert.Equal(t, cfg.VoiceID, u.VoiceID)
+		assert.Equal(t, "text", u.Source)
+		assert.Equal(t, "work", u.Profile)
+		assert.Equal(t, "podcast", u.Tag)
+		assert.WithinDuration(t, time.Now(), u.Time, time.Minute)
+	})
+
+	t.Run("keeps the audio and subtitles when the ledger fails", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		cfg := newConfig(s)
+		cfg.TextInput = "Hello world."
+		cfg.Subtitles = true
+		c := client.New(cfg, s.Client())
+		c.Ledger = &memoryLedger{err: errors.New("disk full")}
+		require.NoError(t, c.ProcessText())
+
+		for _, ext := range []string{"*.mp3", "*.srt", "*.vtt"} {
+			files, err := filepath.Glob(filepath.Join(cfg.OutputDir, ext))
+			require.NoError(t, err)
+			assert.Len(t, files, 1, ext)
+		}
+	})
+
+	t.Run("splits text at the character limit", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		cfg := newConfig(s)
+		cfg.TextInput = "Hello there, world."
+		cfg.CharacterRequestLimit = 10
+		require.NoError(t, client.New(cfg, s.Client()).ProcessText())
+
+		var texts []string
+		for _, r := range s.Requests() {
+			if strings.HasPrefix(r.Path, "/v1/text-to-speech/") {
+				var body struct{ Text string }
+				require.NoError(t, json.Unmarshal(r.Body, &body))
+				texts = append(texts, body.Text)
+			}
+		}
+		assert.Equal(t, []string{"Hello ther", "e, world."}, texts)
+	})
+
+	t.Run("joins a website into one file", func(t *testing.T) {
+		t.Parallel()
+		site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = fmt.Fprint(w, `<html><head><title>Page</title></head><body><h1>One</h1><p>First.</p><h2>Two</h2><p>Second.</p></body></html>`)
+		}))
+		t.Cleanup(site.Close)
+		s := fakeeleven.New(t)
+		cfg := newConfig(s)
+		cfg.WebsiteURL = site.URL
+		cfg.CharacterRequestLimit = 10
+		require.NoError(t, client.New(cfg, http.DefaultClient).ProcessSite())
+
+		files, err := filepath.Glob(filepath.Join(cfg.OutputDir, "*.mp3"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		data, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		requests := len(s.Requests())
+		assert.Greater(t, requests, 1)
+		// each chunk is rounded to whole mp3 frames
+		want := time.Duration(s.CharacterCount()) * fakeeleven.CharDuration
+		assert.InDelta(t, want, audio.Duration(data), float64(time.Duration(requests)*30*time.Millisecond))
+	})
+
+	t.Run("surfaces API errors", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		s.Inject(fakeeleven.Fault{Status: http.StatusTooManyRequests})
+		cfg := newConfig(s)
+		cfg.TextInput = "Hello"
+		err := client.New(cfg, s.Client()).ProcessText()
+		assert.ErrorContains(t, err, "429 Too Many Requests")
+	})
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// outputFormats are the Eleven Labs output formats chatter can save. Raw pcm is wrapped in a WAV file.
+var outputFormats = []string{
+	"mp3_22050_32", "mp3_44100_32", "mp3_44100_64", "mp3_44100_96", "mp3_44100_128", "mp3_44100_192",
+	"pcm_16000", "pcm_22050", "pcm_24000", "pcm_44100",
+}
+
+// OutputFormats lists the supported output formats
+func OutputFormats() []string {
+	return slices.Clone(outputFormats)
+}
+
+// CheckOutputFormat returns an error unless format is empty, meaning the API default, or supported
+func CheckOutputFormat(format string) error {
+	if format == "" || slices.Contains(outputFormats, format) {
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q, expected one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+// decodeAudio wraps raw pcm responses, which are 16 bit mono at the format's sample rate, in a WAV header
+func (c *ElevenLabs) decodeAudio(data []byte) []byte {
+	rate, ok := strings.CutPrefix(c.Config.OutputFormat, "pcm_")
+	if !ok {
+		return data
+	}
+	sampleRate, err := strconv.Atoi(rate)
+	if err != nil {
+		return data
+	}
+	return audio.EncodeWAV(audio.WAVFormat{AudioFormat: 1, Channels: 1, SampleRate: uint32(sampleRate), BitsPerSample: 16}, data)
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// markdown syntax that is dropped rather than read aloud
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?$`)
+	mdListItem = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+`)
+	mdRule     = regexp.MustCompile(`^(?:[-*_=]\s*){3,}$`)
+	mdImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdEmphasis = regexp.MustCompile("\\*\\*|__|\\*|~~|`")
+)
+
+// maxMarkdownLine is the longest line read from a markdown document
+const maxMarkdownLine = 1 << 20
+
+// extractTextFromMarkdown extracts the title, text and headings from a markdown document. The first top level heading
+// is the title, code blocks are skipped and paragraphs are joined onto one line.
+func extractTextFromMarkdown(ctx context.Context, r io.Reader) (_ *document, err error) {
+	_, span := telemetry.StartSpan(ctx, "extract")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	out := &document{}
+	var sb strings.Builder
+	count := 0
+	write := func(text string) {
+		count += utf8.RuneCountInString(text) + 1
+		sb.WriteString(text)
+		sb.WriteString("\n")
+	}
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			write(strings.Join(paragraph, " "))
+			paragraph = nil
+		}
+	}
+
+	fence := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxMarkdownLine)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case fence != "":
+			if strings.HasPrefix(line, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(line, "```"), strings.HasPrefix(line, "~~~"):
+			flush()
+			fence = line[:3]
+		case line == "", mdRule.MatchString(line):
+			flush()
+		case mdHeading.MatchString(line):
+			flush()
+			m := mdHeading.FindStringSubmatch(line)
+			title := markdownInline(m[2])
+			if out.Title == "" && m[1] == "#" {
+				out.Title = title
+			}
+			out.Headings = append(out.Headings, heading{Title: title, Offset: count})
+			write(title)
+		default:
+			line = strings.TrimSpace(strings.TrimLeft(line, "> "))
+			if mdListItem.MatchString(line) {
+				flush()
+				line = mdListItem.ReplaceAllString(line, "")
+			}
+			if line != "" {
+				paragraph = append(paragraph, markdownInline(line))
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read markdown: %w", err)
+	}
+	flush()
+
+	out.Text = sb.String()
+	span.SetAttributes(attribute.Int("headings", len(out.Headings)), attribute.Int("characters", count))
+	return out, nil
+}
+
+// markdownInline drops the link targets, images and emphasis from a line, keeping the words
+func markdownInline(s string) string {
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1")
+	return strings.TrimSpace(mdEmphasis.ReplaceAllString(s, ""))
+}
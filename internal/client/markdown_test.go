@@ -0,0 +1,60 @@
+package client_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/client/mocks"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestPipeline_PlanMarkdown(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		markdown string
+		title    string
+		text     string
+		headings []client.Heading
+	}{
+		{
+			name:     "titles the plan with the first top level heading",
+			markdown: "## Before\n\nIntro.\n\n# Guide ##\n\nBody.\n",
+			title:    "Guide",
+			text:     "Before\nIntro.\nGuide\nBody.\n",
+			headings: []client.Heading{{Title: "Before", Offset: 0}, {Title: "Guide", Offset: 14}},
+		},
+		{
+			name:     "joins paragraphs and drops links, images and emphasis",
+			markdown: "Some **bold** and _kept_words_\nwrapped [text](https://example.com). ![A cat](cat.png)\n",
+			text:     "Some bold and _kept_words_ wrapped text. A cat\n",
+		},
+		{
+			name:     "reads list items and quotes as their own lines",
+			markdown: "> Quoted\n> on.\n\n- one\n- two\n  more\n1. three\n",
+			text:     "Quoted on.\none\ntwo more\nthree\n",
+		},
+		{
+			name:     "skips code blocks and rules",
+			markdown: "Before.\n\n```go\nfmt.Println()\n```\n\n---\n\nAfter.\n",
+			text:     "Before.\nAfter.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config.AppConfig{CharacterRequestLimit: 1000, VoiceID: "en-us", Language: "none"}
+			plan, err := client.NewPipeline(cfg, mocks.NewHTTP(t), &fakeSynthesizer{}).PlanMarkdown(strings.NewReader(tt.markdown))
+			require.NoError(t, err)
+			if tt.title != "" {
+				assert.Equal(t, tt.title, plan.Title)
+			}
+			require.Len(t, plan.Chunks, 1)
+			assert.Equal(t, tt.text, plan.Chunks[0].Text)
+			assert.Equal(t, tt.headings, plan.Headings)
+		})
+	}
+}
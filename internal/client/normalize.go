@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/markup"
+	"github.com/sgerhardt/chatter/internal/normalize"
+	"github.com/sgerhardt/chatter/internal/script"
+	"strings"
+)
+
+// normalizer builds the text normalization pipeline from the config. Markup tags pass through untouched.
+func (c *Pipeline) normalizer() (func(string) string, error) {
+	p, err := normalize.New(normalize.Options{
+		Language:         c.Config.Language,
+		ReplacementsFile: c.Config.Replacements,
+		SpellAcronyms:    c.Config.SpellAcronyms,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build normalizer: %w", err)
+	}
+	return func(text string) string {
+		return markup.MapText(text, p.Normalize)
+	}, nil
+}
+
+// NormalizedText returns the text or website content as it will be spoken
+func (c *Pipeline) NormalizedText() (string, error) {
+	return c.normalizedText(c.context())
+}
+
+func (c *Pipeline) normalizedText(ctx context.Context) (string, error) {
+	n, err := c.normalizer()
+	if err != nil {
+		return "", err
+	}
+	if c.Config.WebsiteURL == "" {
+		return n(c.Config.TextInput), nil
+	}
+	doc, err := c.fetchDocument(ctx, c.Config.WebsiteURL)
+	if err != nil {
+		return "", err
+	}
+	doc.normalize(n)
+	return doc.Text, nil
+}
+
+// NormalizedScript returns a copy of s with every line as it will be spoken
+func (c *Pipeline) NormalizedScript(s *script.Script) (*script.Script, error) {
+	n, err := c.normalizer()
+	if err != nil {
+		return nil, err
+	}
+	out := *s
+	out.Lines = make([]script.Line, len(s.Lines))
+	for i, line := range s.Lines {
+		line.Text = n(line.Text)
+		out.Lines[i] = line
+	}
+	return &out, nil
+}
+
+// normalize rewrites the document text, moving the heading offsets so they still point at their headings
+func (d *document) normalize(fn func(string) string) {
+	runes := []rune(d.Text)
+	var sb strings.Builder
+	prev := 0
+	offset := 0
+	for i, h := range d.Headings {
+		piece := fn(string(runes[prev:h.Offset]))
+		sb.WriteString(piece)
+		offset += len([]rune(piece))
+		prev = h.Offset
+		d.Headings[i].Offset = offset
+	}
+	sb.WriteString(fn(string(runes[prev:])))
+	d.Text = sb.String()
+}
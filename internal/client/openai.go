@@ -0,0 +1,104 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/config"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultOpenAIModel is used by the OpenAI provider when no model is configured
+const DefaultOpenAIModel = "tts-1"
+
+// openAIBase is the default server for the OpenAI provider
+const openAIBase = "https://api.openai.com"
+
+// openAILimit is the most input the speech endpoint accepts in one request
+const openAILimit = 4096
+
+// The speech endpoint accepts speeds between these
+const (
+	openAIMinSpeed = 0.25
+	openAIMaxSpeed = 4.0
+)
+
+type openAIRequest struct {
+	Model          string  `json:"model"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice"`
+	ResponseFormat string  `json:"response_format"`
+	Speed          float64 `json:"speed,omitempty"`
+}
+
+// OpenAI synthesizes speech with an OpenAI-compatible /v1/audio/speech endpoint
+type OpenAI struct {
+	httpClient HTTP
+	Config     *config.AppConfig
+}
+
+func NewOpenAI(cfg *config.AppConfig, httpClient HTTP) *OpenAI {
+	return &OpenAI{
+		Config:     cfg,
+		httpClient: httpClient,
+	}
+}
+
+// CharacterLimit returns the endpoint's input limit
+func (o *OpenAI) CharacterLimit() int {
+	return openAILimit
+}
+
+// Synthesize converts text to mp3 with the voice named by the request's voice ID, e.g. alloy
+func (o *OpenAI) Synthesize(req SynthesisRequest) (*Synthesis, error) {
+	if req.Timestamps {
+		return nil, ErrNoTimestamps
+	}
+	if count := utf8.RuneCountInString(req.Text); count > openAILimit {
+		return nil, fmt.Errorf("text limit is %d characters, got :%d", openAILimit, count)
+	}
+	if req.VoiceID == "" {
+		return nil, fmt.Errorf("voice ID is required")
+	}
+
+	model := o.Config.ModelID
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	speed := req.Settings.Speed
+	if speed != 0 {
+		speed = min(max(speed, openAIMinSpeed), openAIMaxSpeed)
+	}
+	payload, err := json.Marshal(openAIRequest{
+		Model:          model,
+		Input:          req.Text,
+		Voice:          req.VoiceID,
+		ResponseFormat: "mp3",
+		Speed:          speed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build payload: %w", err)
+	}
+
+	base := strings.TrimSuffix(o.Config.ProviderURL, "/")
+	if base == "" {
+		base = openAIBase
+	}
+	httpReq, err := http.NewRequestWithContext(req.context(), "POST", base+"/v1/audio/speech", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	httpReq.Header.Add("Accept", "audio/mpeg")
+	httpReq.Header.Add("Content-Type", "application/json")
+	if o.Config.APIKey != "" {
+		httpReq.Header.Add("Authorization", "Bearer "+o.Config.APIKey)
+	}
+
+	body, header, err := sendRequest(o.httpClient, httpReq)
+	if err != nil {
+		return nil, err
+	}
+	return &Synthesis{Audio: body, Model: model, RequestID: header.Get("x-request-id")}, nil
+}
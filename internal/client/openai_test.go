@@ -0,0 +1,64 @@
+package client_test
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/client/mocks"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestOpenAI_Synthesize(t *testing.T) {
+	t.Parallel()
+
+	t.Run("posts to the speech endpoint", func(t *testing.T) {
+		t.Parallel()
+		mockClient := mocks.NewHTTP(t)
+		mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("mp3"))),
+		}, nil).Run(func(args mock.Arguments) {
+			req := args.Get(0).(*http.Request)
+			assert.Equal(t, "http://localhost:8880/v1/audio/speech", req.URL.String())
+			assert.Equal(t, "Bearer sk-123", req.Header.Get("Authorization"))
+			assert.Empty(t, req.Header.Get("xi-api-key"))
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"model":"tts-1","input":"testing","voice":"alloy","response_format":"mp3"}`, string(body))
+		})
+
+		cfg := &config.AppConfig{APIKey: "sk-123", ProviderURL: "http://localhost:8880/"}
+		res, err := client.NewOpenAI(cfg, mockClient).Synthesize(client.SynthesisRequest{Text: "testing", VoiceID: "alloy"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("mp3"), res.Audio)
+	})
+
+	t.Run("sends the speed, clamped to what the endpoint accepts", func(t *testing.T) {
+		t.Parallel()
+		for speed, want := range map[float64]string{0: "", 1.5: `,"speed":1.5`, 0.1: `,"speed":0.25`, 9: `,"speed":4`} {
+			mockClient := mocks.NewHTTP(t)
+			mockClient.On("Do", mock.AnythingOfType("*http.Request")).Return(&http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte("mp3"))),
+			}, nil).Run(func(args mock.Arguments) {
+				body, err := io.ReadAll(args.Get(0).(*http.Request).Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, `{"model":"tts-1","input":"testing","voice":"alloy","response_format":"mp3"`+want+`}`, string(body), speed)
+			})
+			_, err := client.NewOpenAI(&config.AppConfig{}, mockClient).Synthesize(client.SynthesisRequest{Text: "testing", VoiceID: "alloy",
+				Settings: config.VoiceSettings{Speed: speed}})
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("cannot time characters", func(t *testing.T) {
+		t.Parallel()
+		_, err := client.NewOpenAI(&config.AppConfig{}, mocks.NewHTTP(t)).Synthesize(client.SynthesisRequest{Text: "testing", VoiceID: "alloy", Timestamps: true})
+		assert.ErrorIs(t, err, client.ErrNoTimestamps)
+	})
+}
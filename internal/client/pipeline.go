@@ -0,0 +1,267 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/id3"
+	"github.com/sgerhardt/chatter/internal/markup"
+	"github.com/sgerhardt/chatter/internal/subtitle"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Pipeline turns text, websites and scripts into audio files using a Synthesizer
+type Pipeline struct {
+	httpClient HTTP
+	synth      Synthesizer
+	Config     *config.AppConfig
+	// Ledger, when set, records the usage of every synthesis
+	Ledger UsageLedger
+	// Play, when set, plays each finished file
+	Play func(ctx context.Context, data []byte) error
+	// NoSave keeps finished audio from being written, for when it is only played
+	NoSave bool
+	// SegmentLength is the longest piece of a recording revoiced in one request, DefaultSegmentLength when 0
+	SegmentLength time.Duration
+	ctx           context.Context
+	source        string
+	usage         *usageLog
+}
+
+// NewPipeline returns a pipeline that fetches websites with httpClient and voices text with synth
+func NewPipeline(cfg *config.AppConfig, httpClient HTTP, synth Synthesizer) *Pipeline {
+	return &Pipeline{
+		Config:     cfg,
+		httpClient: httpClient,
+		synth:      synth,
+		usage:      &usageLog{},
+	}
+}
+
+// WithContext returns a copy of the pipeline whose spans and requests belong to ctx
+func (c *Pipeline) WithContext(ctx context.Context) *Pipeline {
+	p := *c
+	p.ctx = ctx
+	return &p
+}
+
+// context returns the pipeline's context, context.Background() when none was given
+func (c *Pipeline) context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+func (c *Pipeline) fileWithTimestamp(format audio.Format) string {
+	currentTime := time.Now()
+	formattedTime := currentTime.Format("20060102_150405")
+	prefix := ""
+	if c.Config.OutputDir == "" {
+		prefix = "output_"
+	} else if !strings.HasSuffix(c.Config.OutputDir, string(os.PathSeparator)) {
+		prefix = c.Config.OutputDir + string(os.PathSeparator)
+	}
+	return prefix + formattedTime + "." + string(format)
+}
+
+// encode post-processes the track and returns the file contents, prefixed with the ID3 tag when it is an mp3. It also
+// returns how far post-processing moved the speech, having moved the tag's chapters to match.
+func (c *Pipeline) encode(ctx context.Context, tag *id3.Tag, track *audio.Track) ([]byte, time.Duration, error) {
+	data, shift, err := c.postProcess(ctx, track.Bytes())
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range tag.Chapters {
+		tag.Chapters[i].Start = max(0, tag.Chapters[i].Start+shift)
+		tag.Chapters[i].End = max(0, tag.Chapters[i].End+shift)
+	}
+	var header []byte
+	if track.Format() == audio.MP3 {
+		if header, err = tag.Bytes(); err != nil {
+			return nil, 0, fmt.Errorf("failed to build tag: %w", err)
+		}
+	}
+	return append(header, data...), shift, nil
+}
+
+// write saves the encoded track
+func (c *Pipeline) write(ctx context.Context, filename string, data []byte) (err error) {
+	_, span := telemetry.StartSpan(ctx, "write", attribute.String("file", filename))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if err = os.WriteFile(filename, data, 0644); err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.Int("bytes", len(data)))
+	return nil
+}
+
+// save writes the track and, when enabled, its subtitles, then plays it when the pipeline has a player
+func (c *Pipeline) save(ctx context.Context, tag *id3.Tag, track *audio.Track, chars []subtitle.Char) error {
+	data, shift, err := c.encode(ctx, tag, track)
+	if err != nil {
+		return err
+	}
+	if c.NoSave {
+		return c.play(ctx, data)
+	}
+	filename := c.fileWithTimestamp(track.Format())
+	if err = c.write(ctx, filename, data); err != nil {
+		return err
+	}
+	if c.Config.Subtitles {
+		if err = writeSubtitles(filename, subtitle.Offset(chars, shift)); err != nil {
+			return err
+		}
+	}
+	c.RecordUsage(filename)
+	return c.play(ctx, data)
+}
+
+// play plays the finished audio, when the pipeline has a player
+func (c *Pipeline) play(ctx context.Context, data []byte) (err error) {
+	if c.Play == nil {
+		return nil
+	}
+	ctx, span := telemetry.StartSpan(ctx, "play", attribute.Int("bytes", len(data)))
+	defer func() { telemetry.EndSpan(span, err) }()
+	return c.Play(ctx, data)
+}
+
+// characterLimit is the most text sent in one request, the lower of the configured and provider limits
+func (c *Pipeline) characterLimit() int {
+	limit := c.Config.CharacterRequestLimit
+	if l, ok := c.synth.(characterLimiter); ok && l.CharacterLimit() < limit {
+		limit = l.CharacterLimit()
+	}
+	return limit
+}
+
+func (c *Pipeline) ProcessText() (err error) {
+	ctx, span := telemetry.StartSpan(c.context(), "ProcessText")
+	defer func() { telemetry.EndSpan(span, err) }()
+	// a failed render is still billed for what it synthesized
+	defer c.RecordUsage("")
+
+	tag, track, chars, err := c.renderText(ctx)
+	if err != nil {
+		return err
+	}
+	return c.save(ctx, tag, track, chars)
+}
+
+// ProcessSite converts a website to a single audio file, with a chapter for each heading on the page
+func (c *Pipeline) ProcessSite() (err error) {
+	ctx, span := telemetry.StartSpan(c.context(), "ProcessSite", attribute.String("url", c.Config.WebsiteURL))
+	defer func() { telemetry.EndSpan(span, err) }()
+	defer c.RecordUsage("")
+
+	tag, track, chars, err := c.renderSite(ctx)
+	if err != nil {
+		return err
+	}
+	return c.save(ctx, tag, track, chars)
+}
+
+// Rendering is finished audio held in memory rather than written to the output directory
+type Rendering struct {
+	Title  string
+	Format audio.Format
+	// Audio is the file contents, ID3 tagged when the format is mp3
+	Audio []byte
+}
+
+// Render voices the configured text or website and returns the audio instead of saving it
+func (c *Pipeline) Render() (_ *Rendering, err error) {
+	ctx, span := telemetry.StartSpan(c.context(), "Render")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	p := c.WithContext(ctx)
+	plan, err := p.Plan()
+	if err != nil {
+		return nil, err
+	}
+	chunkAudio := make([][]byte, len(plan.Chunks))
+	for i, ch := range plan.Chunks {
+		if chunkAudio[i], err = p.SynthesizeChunk(ch); err != nil {
+			return nil, err
+		}
+	}
+	return p.Assemble(plan, chunkAudio)
+}
+
+func (c *Pipeline) renderText(ctx context.Context) (*id3.Tag, *audio.Track, []subtitle.Char, error) {
+	text, err := c.normalizedText(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	track, chars, err := c.render(ctx, c.textParts(text))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tag, err := c.newTag(firstSentence(markup.Strip(c.Config.TextInput)), "", "")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tag, track, chars, nil
+}
+
+func (c *Pipeline) renderSite(ctx context.Context) (*id3.Tag, *audio.Track, []subtitle.Char, error) {
+	n, err := c.normalizer()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	doc, err := c.fetchDocument(ctx, c.Config.WebsiteURL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	doc.normalize(n)
+	tl := newTimeline()
+	for _, ch := range c.documentChunks(ctx, doc) {
+		fromText, timings, tErr := c.synthesize(ctx, ch.Text, ch.VoiceID, ch.Settings)
+		if tErr != nil {
+			return nil, nil, nil, tErr
+		}
+		if tErr = tl.add(ch, fromText, timings); tErr != nil {
+			return nil, nil, nil, tErr
+		}
+	}
+
+	title := doc.Title
+	if title == "" {
+		title = firstSentence(doc.Text)
+	}
+	tag, err := c.newTag(title, domain(c.Config.WebsiteURL), c.Config.WebsiteURL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tag.Chapters = chaptersFor(doc, tl.chunks, tl.elapsed)
+	return tag, tl.track, tl.chars, nil
+}
+
+// synthesize voices text with the pipeline's synthesizer, requesting character timings when subtitles are enabled
+func (c *Pipeline) synthesize(ctx context.Context, text, voiceID string, settings config.VoiceSettings) (_ []byte, _ []subtitle.Char, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "synthesize", attribute.String("voice_id", voiceID),
+		attribute.Int("characters", utf8.RuneCountInString(text)))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	res, err := c.synth.Synthesize(SynthesisRequest{
+		Text:       text,
+		VoiceID:    voiceID,
+		Settings:   settings,
+		Timestamps: c.Config.Subtitles,
+		Context:    ctx,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	c.addUsage(text, voiceID, res)
+	return res.Audio, res.Chars, nil
+}
@@ -0,0 +1,139 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/client/mocks"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/script"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSynthesizer voices each request as 100ms of WAV audio and records what it was asked
+type fakeSynthesizer struct {
+	requests []client.SynthesisRequest
+}
+
+func (f *fakeSynthesizer) Synthesize(req client.SynthesisRequest) (*client.Synthesis, error) {
+	f.requests = append(f.requests, req)
+	format := audio.WAVFormat{AudioFormat: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16}
+	return &client.Synthesis{Audio: audio.EncodeWAV(format, bytes.Repeat([]byte{1, 0}, 1600))}, nil
+}
+
+func TestPipeline(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes wav audio from the synthesizer without a tag", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		synth := &fakeSynthesizer{}
+		cfg := &config.AppConfig{
+			CharacterRequestLimit: 100,
+			OutputDir:             dir,
+			VoiceID:               "en-us",
+			TextInput:             `Hello <break time="1s"/> world`,
+		}
+		require.NoError(t, client.NewPipeline(cfg, mocks.NewHTTP(t), synth).ProcessText())
+
+		require.Len(t, synth.requests, 2)
+		assert.Equal(t, "Hello", synth.requests[0].Text)
+		assert.Equal(t, "world", synth.requests[1].Text)
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.wav"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		data, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		f, pcm, err := audio.ParseWAV(data)
+		require.NoError(t, err)
+		assert.Equal(t, 1200*time.Millisecond, f.Duration(len(pcm)))
+	})
+
+	t.Run("voices each script line with its speaker", func(t *testing.T) {
+		t.Parallel()
+		s, err := script.ParseText(strings.NewReader("ALICE: Hi.\nBOB: Hello."))
+		require.NoError(t, err)
+		s.AddSpeaker("ALICE", script.Speaker{VoiceID: "a"})
+		s.AddSpeaker("BOB", script.Speaker{VoiceID: "b"})
+
+		synth := &fakeSynthesizer{}
+		cfg := &config.AppConfig{CharacterRequestLimit: 100, OutputDir: t.TempDir()}
+		require.NoError(t, client.NewPipeline(cfg, mocks.NewHTTP(t), synth).ProcessScript(s))
+		require.Len(t, synth.requests, 2)
+		assert.Equal(t, "a", synth.requests[0].VoiceID)
+		assert.Equal(t, "b", synth.requests[1].VoiceID)
+	})
+
+	t.Run("renders to memory without writing a file", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		cfg := &config.AppConfig{CharacterRequestLimit: 100, OutputDir: dir, VoiceID: "en-us", TextInput: "Hello world. Again."}
+		r, err := client.NewPipeline(cfg, mocks.NewHTTP(t), &fakeSynthesizer{}).Render()
+		require.NoError(t, err)
+		assert.Equal(t, "Hello world", r.Title)
+		assert.Equal(t, audio.WAV, r.Format)
+		assert.True(t, audio.IsWAV(r.Audio))
+
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+
+		_, err = client.NewPipeline(&config.AppConfig{}, mocks.NewHTTP(t), &fakeSynthesizer{}).Render()
+		assert.EqualError(t, err, "text or site is required")
+	})
+
+	t.Run("plays the finished audio, saving it unless told not to", func(t *testing.T) {
+		t.Parallel()
+		for _, noSave := range []bool{false, true} {
+			dir := t.TempDir()
+			cfg := &config.AppConfig{CharacterRequestLimit: 100, OutputDir: dir, VoiceID: "en-us", TextInput: "Hello world."}
+			p := client.NewPipeline(cfg, mocks.NewHTTP(t), &fakeSynthesizer{})
+			var played [][]byte
+			p.Play = func(_ context.Context, data []byte) error {
+				played = append(played, data)
+				return nil
+			}
+			p.NoSave = noSave
+			require.NoError(t, p.ProcessText())
+			require.Len(t, played, 1)
+			assert.True(t, audio.IsWAV(played[0]))
+
+			files, err := filepath.Glob(filepath.Join(dir, "*.wav"))
+			require.NoError(t, err)
+			if noSave {
+				assert.Empty(t, files)
+				continue
+			}
+			require.Len(t, files, 1)
+			data, err := os.ReadFile(files[0])
+			require.NoError(t, err)
+			assert.Equal(t, data, played[0])
+		}
+	})
+}
+
+func TestNewSynthesizer(t *testing.T) {
+	t.Parallel()
+
+	for provider, want := range map[string]any{
+		"":                        &client.ElevenLabs{},
+		client.ProviderElevenLabs: &client.ElevenLabs{},
+		client.ProviderOpenAI:     &client.OpenAI{},
+		client.ProviderCommand:    &client.Command{},
+	} {
+		synth, err := client.NewSynthesizer(&config.AppConfig{Provider: provider}, mocks.NewHTTP(t))
+		require.NoError(t, err)
+		assert.IsType(t, want, synth, provider)
+	}
+
+	_, err := client.NewSynthesizer(&config.AppConfig{Provider: "polly"}, mocks.NewHTTP(t))
+	assert.EqualError(t, err, `unknown provider "polly", expected one of [elevenlabs openai command]`)
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/markup"
+	"io"
+	"time"
+)
+
+// Plan is a conversion split into the requests that voice it, so a long job can be synthesized, stored and
+// resumed one chunk at a time and assembled at the end
+type Plan struct {
+	Title    string    `json:"title"`
+	Album    string    `json:"album,omitempty"`
+	Source   string    `json:"source,omitempty"`
+	Headings []Heading `json:"headings,omitempty"`
+	Chunks   []Chunk   `json:"chunks"`
+}
+
+// Heading is a section title and the rune offset in the source text where it starts
+type Heading struct {
+	Title  string `json:"title"`
+	Offset int    `json:"offset"`
+}
+
+// Chunk is one synthesis request and the silence that follows it
+type Chunk struct {
+	Text     string               `json:"text"`
+	VoiceID  string               `json:"voice_id"`
+	Settings config.VoiceSettings `json:"voice_settings"`
+	Pause    time.Duration        `json:"pause,omitempty"`
+	// Offset is where the text starts in the source document, placing headings on the timeline
+	Offset int `json:"offset"`
+	// label prefixes errors voicing the chunk, naming the script line it came from
+	label string
+}
+
+// Plan splits the configured text or website into chunks
+func (c *Pipeline) Plan() (*Plan, error) {
+	switch {
+	case c.Config.TextInput != "":
+		return c.PlanText()
+	case c.Config.WebsiteURL != "":
+		return c.PlanSite()
+	}
+	return nil, fmt.Errorf("text or site is required")
+}
+
+// PlanText normalizes the configured text and splits it at markup pauses and voice changes and at the character limit
+func (c *Pipeline) PlanText() (*Plan, error) {
+	text, err := c.NormalizedText()
+	if err != nil {
+		return nil, err
+	}
+	parts, err := c.expandMarkup(c.textParts(text))
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{
+		Title:  firstSentence(markup.Strip(c.Config.TextInput)),
+		Chunks: c.partChunks(c.context(), parts),
+	}, nil
+}
+
+// PlanSite fetches the configured website and splits its text at the character limit
+func (c *Pipeline) PlanSite() (*Plan, error) {
+	doc, err := c.fetchDocument(c.context(), c.Config.WebsiteURL)
+	if err != nil {
+		return nil, err
+	}
+	return c.planDocument(doc, domain(c.Config.WebsiteURL), c.Config.WebsiteURL)
+}
+
+// PlanHTML splits an uploaded HTML document like a website
+func (c *Pipeline) PlanHTML(r io.Reader) (*Plan, error) {
+	doc, err := extractTextFromHTML(c.context(), r)
+	if err != nil {
+		return nil, err
+	}
+	return c.planDocument(doc, "", "")
+}
+
+// PlanMarkdown splits an uploaded markdown document like a website, with a chapter for each heading
+func (c *Pipeline) PlanMarkdown(r io.Reader) (*Plan, error) {
+	doc, err := extractTextFromMarkdown(c.context(), r)
+	if err != nil {
+		return nil, err
+	}
+	return c.planDocument(doc, "", "")
+}
+
+func (c *Pipeline) planDocument(doc *document, album, source string) (*Plan, error) {
+	n, err := c.normalizer()
+	if err != nil {
+		return nil, err
+	}
+	doc.normalize(n)
+	plan := &Plan{Title: doc.Title, Album: album, Source: source}
+	if plan.Title == "" {
+		plan.Title = firstSentence(doc.Text)
+	}
+	for _, h := range doc.Headings {
+		plan.Headings = append(plan.Headings, Heading{Title: h.Title, Offset: h.Offset})
+	}
+	plan.Chunks = c.documentChunks(c.context(), doc)
+	return plan, nil
+}
+
+// SynthesizeChunk voices one chunk, returning no audio for a chunk that is only a pause
+func (c *Pipeline) SynthesizeChunk(ch Chunk) ([]byte, error) {
+	if ch.Text == "" {
+		return nil, nil
+	}
+	data, _, err := c.synthesize(c.context(), ch.Text, ch.VoiceID, ch.Settings)
+	return data, err
+}
+
+// Assemble joins the audio of every chunk in the plan into one tagged file, with a chapter for each heading
+func (c *Pipeline) Assemble(plan *Plan, chunkAudio [][]byte) (*Rendering, error) {
+	if len(chunkAudio) != len(plan.Chunks) {
+		return nil, fmt.Errorf("got audio for %d of %d chunks", len(chunkAudio), len(plan.Chunks))
+	}
+	tl := newTimeline()
+	for i, ch := range plan.Chunks {
+		if err := tl.add(ch, chunkAudio[i], nil); err != nil {
+			return nil, err
+		}
+	}
+
+	tag, err := c.newTag(plan.Title, plan.Album, plan.Source)
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{Title: plan.Title}
+	for _, h := range plan.Headings {
+		doc.Headings = append(doc.Headings, heading{Title: h.Title, Offset: h.Offset})
+	}
+	tag.Chapters = chaptersFor(doc, tl.chunks, tl.elapsed)
+	data, _, err := c.encode(c.context(), tag, tl.track)
+	if err != nil {
+		return nil, err
+	}
+	return &Rendering{Title: plan.Title, Format: tl.track.Format(), Audio: data}, nil
+}
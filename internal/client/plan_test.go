@@ -0,0 +1,73 @@
+package client_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/client/mocks"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipeline_Plan(t *testing.T) {
+	t.Parallel()
+
+	t.Run("splits text at breaks and the character limit", func(t *testing.T) {
+		t.Parallel()
+		cfg := &config.AppConfig{
+			CharacterRequestLimit: 20,
+			VoiceID:               "en-us",
+			TextInput:             `The first sentence. The second sentence. <break time="1s"/> The end.`,
+		}
+		plan, err := client.NewPipeline(cfg, mocks.NewHTTP(t), &fakeSynthesizer{}).Plan()
+		require.NoError(t, err)
+
+		assert.Equal(t, "The first sentence", plan.Title)
+		require.Len(t, plan.Chunks, 3)
+		assert.Equal(t, "The first sentence.", strings.TrimSpace(plan.Chunks[0].Text))
+		assert.Equal(t, "The second sentence.", strings.TrimSpace(plan.Chunks[1].Text))
+		assert.Equal(t, time.Second, plan.Chunks[1].Pause)
+		assert.Equal(t, "The end.", strings.TrimSpace(plan.Chunks[2].Text))
+		for _, ch := range plan.Chunks {
+			assert.Equal(t, "en-us", ch.VoiceID)
+		}
+		assert.Less(t, plan.Chunks[0].Offset, plan.Chunks[1].Offset)
+	})
+
+	t.Run("needs text or a site", func(t *testing.T) {
+		t.Parallel()
+		_, err := client.NewPipeline(&config.AppConfig{}, mocks.NewHTTP(t), &fakeSynthesizer{}).Plan()
+		require.EqualError(t, err, "text or site is required")
+	})
+}
+
+func TestPipeline_Assemble(t *testing.T) {
+	t.Parallel()
+
+	synth := &fakeSynthesizer{}
+	p := client.NewPipeline(&config.AppConfig{CharacterRequestLimit: 100, VoiceID: "en-us", TextInput: "Hello"}, mocks.NewHTTP(t), synth)
+	plan := &client.Plan{Title: "Hello", Chunks: []client.Chunk{
+		{Text: "Hello", VoiceID: "en-us", Pause: 500 * time.Millisecond},
+		{Text: "world", VoiceID: "en-us", Offset: 5},
+	}}
+
+	chunkAudio := make([][]byte, len(plan.Chunks))
+	for i, ch := range plan.Chunks {
+		var err error
+		chunkAudio[i], err = p.SynthesizeChunk(ch)
+		require.NoError(t, err)
+	}
+	rendering, err := p.Assemble(plan, chunkAudio)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", rendering.Title)
+	require.Equal(t, audio.WAV, rendering.Format)
+	f, pcm, err := audio.ParseWAV(rendering.Audio)
+	require.NoError(t, err)
+	assert.Equal(t, 700*time.Millisecond, f.Duration(len(pcm)))
+
+	_, err = p.Assemble(plan, chunkAudio[:1])
+	require.EqualError(t, err, "got audio for 1 of 2 chunks")
+}
@@ -0,0 +1,241 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"github.com/sgerhardt/chatter/internal/transcode"
+	"go.opentelemetry.io/otel/attribute"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// silenceThreshold is the level, in dBFS, below which the ends of the audio count as silence
+	silenceThreshold = -50
+	// silencePad is the silence kept either side when trimming, so speech doesn't start or stop abruptly
+	silencePad = 100 * time.Millisecond
+	// peakCeiling is the highest peak, in dBFS, loudness normalization may raise the audio to
+	peakCeiling = -1
+	// defaultBitrate is the bitrate in kbit/s of re-encoded mp3 when the output format doesn't give one
+	defaultBitrate = 128
+)
+
+// CheckPostProcessing returns an error for a loudness target that isn't negative, a negative pause, a bed turned up or
+// a missing music file
+func CheckPostProcessing(post config.PostProcessing) error {
+	if post.Loudness > 0 || post.Loudness < -70 {
+		return fmt.Errorf("loudness must be between -70 and 0 LUFS, e.g. -16, got %g", post.Loudness)
+	}
+	if post.ChunkPause < 0 || post.ParagraphPause < 0 || post.HeadingPause < 0 || post.Fade < 0 {
+		return errors.New("pauses and fades can't be negative")
+	}
+	if post.BedVolume > 0 || post.Duck > 0 {
+		return errors.New("the bed volume and ducking are cuts in dB, so can't be above 0")
+	}
+	for _, file := range []string{post.Intro, post.Outro, post.Bed} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postProcess trims, mixes and normalizes finished audio as configured, decoding and re-encoding mp3 with ffmpeg. It
+// also returns how far the speech moved: back by the silence trimmed from the start, and on by an intro.
+func (c *Pipeline) postProcess(ctx context.Context, data []byte) (_ []byte, _ time.Duration, err error) {
+	post := c.Config.Post
+	if !post.Mastering() {
+		return data, 0, nil
+	}
+	ctx, span := telemetry.StartSpan(ctx, "postprocess", attribute.Bool("trim_silence", post.TrimSilence),
+		attribute.Float64("loudness", post.Loudness))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	format := audio.Detect(data)
+	wav := data
+	if format == audio.MP3 {
+		if wav, err = transcode.ToWAV(ctx, audio.SkipID3(data)); err != nil {
+			return nil, 0, err
+		}
+	}
+	f, pcm, err := audio.ParseWAV(wav)
+	if err != nil {
+		return nil, 0, err
+	}
+	var shift time.Duration
+	if post.TrimSilence {
+		var lead time.Duration
+		if pcm, lead, err = audio.TrimSilence(f, pcm, silenceThreshold, silencePad); err != nil {
+			return nil, 0, err
+		}
+		shift -= lead
+	}
+	if post.Mixing() {
+		m, mErr := c.mix(ctx, f)
+		if mErr != nil {
+			return nil, 0, mErr
+		}
+		var start time.Duration
+		if pcm, start, err = m.Apply(f, pcm); err != nil {
+			return nil, 0, err
+		}
+		shift += start
+	}
+	if post.Loudness != 0 {
+		if pcm, err = audio.Normalize(f, pcm, post.Loudness, peakCeiling); err != nil {
+			return nil, 0, err
+		}
+	}
+	out := audio.EncodeWAV(f, pcm)
+	if format == audio.MP3 {
+		if out, err = transcode.ToMP3(ctx, out, c.bitrate()); err != nil {
+			return nil, 0, err
+		}
+	}
+	return out, shift, nil
+}
+
+// mix loads the intro, outro and bed, converted to the speech's format
+func (c *Pipeline) mix(ctx context.Context, f audio.WAVFormat) (audio.Mix, error) {
+	post := c.Config.Post
+	m := audio.Mix{BedVolume: post.BedVolume, Duck: post.Duck, Fade: post.Fade}
+	for _, part := range []struct {
+		file string
+		pcm  *[]byte
+	}{{post.Intro, &m.Intro}, {post.Outro, &m.Outro}, {post.Bed, &m.Bed}} {
+		if part.file == "" {
+			continue
+		}
+		pcm, err := loadPCM(ctx, part.file, f)
+		if err != nil {
+			return audio.Mix{}, err
+		}
+		*part.pcm = pcm
+	}
+	return m, nil
+}
+
+// loadPCM reads an mp3 or WAV file as PCM in the format f
+func loadPCM(ctx context.Context, file string, f audio.WAVFormat) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if audio.Detect(data) == audio.MP3 {
+		if data, err = transcode.ToWAV(ctx, audio.SkipID3(data)); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	from, pcm, err := audio.ParseWAV(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	pcm, err = audio.Convert(from, pcm, f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return pcm, nil
+}
+
+// bitrate returns the mp3 bitrate of the output format in kbit/s
+func (c *Pipeline) bitrate() int {
+	if rest, ok := strings.CutPrefix(c.Config.OutputFormat, "mp3_"); ok {
+		_, kbps, _ := strings.Cut(rest, "_")
+		if n, err := strconv.Atoi(kbps); err == nil {
+			return n
+		}
+	}
+	return defaultBitrate
+}
+
+// paragraphBreak separates the paragraphs of text input
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+// textParts returns text as a part to voice, or a part for each paragraph when there is a pause between them
+func (c *Pipeline) textParts(text string) []part {
+	pause := c.Config.Post.ParagraphPause
+	if pause == 0 {
+		return []part{{text: text, voiceID: c.Config.VoiceID, settings: c.Config.VoiceSettings}}
+	}
+	var parts []part
+	for _, p := range paragraphBreak.Split(text, -1) {
+		if strings.TrimSpace(p) != "" {
+			parts = append(parts, part{text: p, voiceID: c.Config.VoiceID, settings: c.Config.VoiceSettings, pause: pause})
+		}
+	}
+	if len(parts) == 0 {
+		return []part{{text: text, voiceID: c.Config.VoiceID, settings: c.Config.VoiceSettings}}
+	}
+	parts[len(parts)-1].pause = 0
+	return parts
+}
+
+// section is a run of a document voiced apart from the rest so that a pause can follow it
+type section struct {
+	text   string
+	offset int
+	pause  time.Duration
+}
+
+// sections splits a document, whose paragraphs and headings are a line each, where a pause is configured: between
+// paragraphs and either side of headings
+func (c *Pipeline) sections(doc *document) []section {
+	post := c.Config.Post
+	if post.ParagraphPause == 0 && post.HeadingPause == 0 {
+		return []section{{text: doc.Text}}
+	}
+	headings := map[int]bool{}
+	for _, h := range doc.Headings {
+		headings[h.Offset] = true
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(doc.Text, "\n"), "\n")
+	var out []section
+	current := section{}
+	offset := 0
+	for i, line := range lines {
+		start := offset
+		current.text += line
+		offset += utf8.RuneCountInString(line)
+		if i == len(lines)-1 {
+			break
+		}
+		pause := post.ParagraphPause
+		if post.HeadingPause > 0 && (headings[start] || headings[offset]) {
+			pause = post.HeadingPause
+		}
+		if pause > 0 {
+			current.pause = pause
+			out = append(out, current)
+			current = section{offset: offset}
+		}
+	}
+	return append(out, current)
+}
+
+// documentChunks splits a document into the requests that voice it, at the character limit and between sections
+func (c *Pipeline) documentChunks(ctx context.Context, doc *document) []Chunk {
+	var chunks []Chunk
+	chunkPause := c.Config.Post.ChunkPause
+	for _, s := range c.sections(doc) {
+		offset := s.offset
+		for _, text := range batchText(ctx, s.text, c.characterLimit()) {
+			chunks = append(chunks, Chunk{Text: text, VoiceID: c.Config.VoiceID, Settings: c.Config.VoiceSettings,
+				Offset: offset, Pause: chunkPause})
+			offset += utf8.RuneCountInString(text)
+		}
+		chunks[len(chunks)-1].Pause = max(s.pause, chunkPause)
+	}
+	chunks[len(chunks)-1].Pause = 0
+	return chunks
+}
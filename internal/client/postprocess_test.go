@@ -0,0 +1,151 @@
+package client_test
+
+import (
+	"encoding/binary"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/client/mocks"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/transcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// toneSynthesizer voices each request as 200ms of silence, 300ms of a quiet tone and 500ms of silence
+type toneSynthesizer struct{}
+
+func (toneSynthesizer) Synthesize(client.SynthesisRequest) (*client.Synthesis, error) {
+	format := audio.WAVFormat{AudioFormat: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16}
+	var tone []byte
+	for i := 0; i < 4800; i++ {
+		tone = binary.LittleEndian.AppendUint16(tone, uint16(int16(1000*math.Sin(2*math.Pi*440*float64(i)/16000))))
+	}
+	return &client.Synthesis{Audio: audio.EncodeWAV(format, slices.Concat(make([]byte, 6400), tone, make([]byte, 16000)))}, nil
+}
+
+// mp3Synthesizer voices each request as a single silent MPEG 1 layer III frame
+type mp3Synthesizer struct{}
+
+func (mp3Synthesizer) Synthesize(client.SynthesisRequest) (*client.Synthesis, error) {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return &client.Synthesis{Audio: frame}, nil
+}
+
+func TestPipeline_PostProcessing(t *testing.T) {
+	t.Parallel()
+
+	t.Run("trims silence and normalizes loudness", func(t *testing.T) {
+		t.Parallel()
+		cfg := &config.AppConfig{CharacterRequestLimit: 100, VoiceID: "en-us", TextInput: "Hello.",
+			Post: config.PostProcessing{TrimSilence: true, Loudness: -16}}
+		r, err := client.NewPipeline(cfg, mocks.NewHTTP(t), toneSynthesizer{}).Render()
+		require.NoError(t, err)
+
+		f, pcm, err := audio.ParseWAV(r.Audio)
+		require.NoError(t, err)
+		// the tone and 100ms of silence either side of it
+		assert.InDelta(t, 500*time.Millisecond, f.Duration(len(pcm)), float64(time.Millisecond))
+		l, err := audio.Loudness(f, pcm)
+		require.NoError(t, err)
+		assert.InDelta(t, -16, l, 0.5)
+	})
+
+	t.Run("pauses between paragraphs and requests", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		synth := &fakeSynthesizer{}
+		cfg := &config.AppConfig{CharacterRequestLimit: 100, OutputDir: dir, VoiceID: "en-us",
+			TextInput: "First paragraph.\n\nSecond <break time=\"1s\"/> paragraph.",
+			Post:      config.PostProcessing{ChunkPause: 200 * time.Millisecond, ParagraphPause: 500 * time.Millisecond}}
+		require.NoError(t, client.NewPipeline(cfg, mocks.NewHTTP(t), synth).ProcessText())
+		require.Len(t, synth.requests, 3)
+		assert.Equal(t, "First paragraph.", synth.requests[0].Text)
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.wav"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		data, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		f, pcm, err := audio.ParseWAV(data)
+		require.NoError(t, err)
+		// three 100ms requests, the paragraph pause and the break, which is longer than the chunk pause
+		assert.Equal(t, 1800*time.Millisecond, f.Duration(len(pcm)))
+
+		plan, err := client.NewPipeline(cfg, mocks.NewHTTP(t), synth).Plan()
+		require.NoError(t, err)
+		var pauses []time.Duration
+		for _, ch := range plan.Chunks {
+			pauses = append(pauses, ch.Pause)
+		}
+		assert.Equal(t, []time.Duration{500 * time.Millisecond, time.Second, 0}, pauses)
+	})
+
+	t.Run("pauses around headings", func(t *testing.T) {
+		t.Parallel()
+		cfg := &config.AppConfig{CharacterRequestLimit: 100, VoiceID: "en-us", Language: "none",
+			Post: config.PostProcessing{HeadingPause: time.Second}}
+		html := "<p>Intro.</p><p>More intro.</p><h2>Part one</h2><p>Body.</p>"
+		plan, err := client.NewPipeline(cfg, mocks.NewHTTP(t), &fakeSynthesizer{}).PlanHTML(strings.NewReader(html))
+		require.NoError(t, err)
+
+		require.Len(t, plan.Chunks, 3)
+		assert.Equal(t, "Intro.\nMore intro.\n", plan.Chunks[0].Text)
+		assert.Equal(t, time.Second, plan.Chunks[0].Pause)
+		assert.Equal(t, "Part one\n", plan.Chunks[1].Text)
+		assert.Equal(t, time.Second, plan.Chunks[1].Pause)
+		assert.Equal(t, "Body.", plan.Chunks[2].Text)
+		assert.Equal(t, time.Duration(0), plan.Chunks[2].Pause)
+		require.Len(t, plan.Headings, 1)
+		assert.Equal(t, plan.Chunks[1].Offset, plan.Headings[0].Offset)
+	})
+
+	t.Run("checks the settings", func(t *testing.T) {
+		t.Parallel()
+		require.NoError(t, client.CheckPostProcessing(config.PostProcessing{Loudness: -16, ChunkPause: time.Second}))
+		assert.EqualError(t, client.CheckPostProcessing(config.PostProcessing{Loudness: 16}),
+			"loudness must be between -70 and 0 LUFS, e.g. -16, got 16")
+		assert.EqualError(t, client.CheckPostProcessing(config.PostProcessing{HeadingPause: -time.Second}),
+			"pauses and fades can't be negative")
+		assert.EqualError(t, client.CheckPostProcessing(config.PostProcessing{Duck: 6}),
+			"the bed volume and ducking are cuts in dB, so can't be above 0")
+		assert.ErrorIs(t, client.CheckPostProcessing(config.PostProcessing{Bed: "missing.wav"}), os.ErrNotExist)
+	})
+
+	t.Run("mixes an intro, outro and bed", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		stereo8k := audio.WAVFormat{AudioFormat: 1, Channels: 2, SampleRate: 8000, BitsPerSample: 16}
+		music := filepath.Join(dir, "music.wav")
+		require.NoError(t, os.WriteFile(music, audio.EncodeWAV(stereo8k, make([]byte, 16000)), 0600))
+
+		cfg := &config.AppConfig{CharacterRequestLimit: 100, VoiceID: "en-us", TextInput: "Hello.",
+			Post: config.PostProcessing{Intro: music, Outro: music, Bed: music, BedVolume: -12, Duck: -12, Fade: time.Second}}
+		r, err := client.NewPipeline(cfg, mocks.NewHTTP(t), toneSynthesizer{}).Render()
+		require.NoError(t, err)
+		f, pcm, err := audio.ParseWAV(r.Audio)
+		require.NoError(t, err)
+		assert.Equal(t, uint16(1), f.Channels, "the episode keeps the speech's format")
+		// a 500ms intro, the bed fading in, 1s of speech, the bed fading out and a 500ms outro
+		assert.Equal(t, 4*time.Second, f.Duration(len(pcm)))
+
+		cfg.Post.Bed = filepath.Join(dir, "missing.mp3")
+		_, err = client.NewPipeline(cfg, mocks.NewHTTP(t), toneSynthesizer{}).Render()
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestPipeline_PostProcessingMP3WithoutFFmpeg(t *testing.T) { // nolint:paralleltest
+	t.Setenv("PATH", t.TempDir())
+	cfg := &config.AppConfig{CharacterRequestLimit: 100, VoiceID: "en-us", TextInput: "Hello.",
+		Post: config.PostProcessing{Loudness: -16}}
+	_, err := client.NewPipeline(cfg, mocks.NewHTTP(t), mp3Synthesizer{}).Render()
+	assert.ErrorIs(t, err, transcode.ErrNoEncoder)
+}
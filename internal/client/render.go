@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/markup"
+	"github.com/sgerhardt/chatter/internal/subtitle"
+	"time"
+	"unicode/utf8"
+)
+
+// part is a piece of text voiced on its own, followed by a pause
+type part struct {
+	label    string
+	text     string
+	voiceID  string
+	settings config.VoiceSettings
+	pause    time.Duration
+}
+
+// expandMarkup validates and compiles any markup in the parts into segments the model accepts,
+// so every part is checked before anything is sent
+func (c *Pipeline) expandMarkup(parts []part) ([]part, error) {
+	support := markup.Support{}
+	if m, ok := c.synth.(markupSupporter); ok {
+		support = m.MarkupSupport()
+	}
+	var out []part
+	for _, p := range parts {
+		if !markup.Contains(p.text) {
+			out = append(out, p)
+			continue
+		}
+		segments, err := markup.Compile(p.text, support)
+		if err != nil {
+			return nil, fmt.Errorf("%sinvalid markup: %w", p.label, err)
+		}
+		for i, s := range segments {
+			sp := part{label: p.label, text: s.Text, voiceID: p.voiceID, settings: p.settings, pause: s.Pause}
+			if s.Voice != "" {
+				sp.voiceID = s.Voice
+			}
+			if i == len(segments)-1 {
+				sp.pause += p.pause
+			}
+			out = append(out, sp)
+		}
+	}
+	return out, nil
+}
+
+// partChunks splits the parts at the character limit into the requests that voice them, pausing between requests
+func (c *Pipeline) partChunks(ctx context.Context, parts []part) []Chunk {
+	var chunks []Chunk
+	offset := 0
+	for j, p := range parts {
+		batches := []string{""}
+		if p.text != "" {
+			batches = batchText(ctx, p.text, c.characterLimit())
+		}
+		for i, b := range batches {
+			ch := Chunk{Text: b, VoiceID: p.voiceID, Settings: p.settings, Offset: offset, label: p.label}
+			if i == len(batches)-1 {
+				ch.Pause = p.pause
+			}
+			if b != "" && (i < len(batches)-1 || j < len(parts)-1) {
+				ch.Pause = max(ch.Pause, c.Config.Post.ChunkPause)
+			}
+			chunks = append(chunks, ch)
+			offset += utf8.RuneCountInString(b)
+		}
+	}
+	return chunks
+}
+
+// render synthesizes the parts in order and joins them into one track, inserting silence for each pause
+func (c *Pipeline) render(ctx context.Context, parts []part) (*audio.Track, []subtitle.Char, error) {
+	parts, err := c.expandMarkup(parts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tl := newTimeline()
+	for _, ch := range c.partChunks(ctx, parts) {
+		var data []byte
+		var timings []subtitle.Char
+		if ch.Text != "" {
+			if data, timings, err = c.synthesize(ctx, ch.Text, ch.VoiceID, ch.Settings); err != nil {
+				return nil, nil, fmt.Errorf("%s%w", ch.label, err)
+			}
+		}
+		if err := tl.add(ch, data, timings); err != nil {
+			return nil, nil, fmt.Errorf("%s%w", ch.label, err)
+		}
+	}
+	return tl.track, tl.chars, nil
+}
+
+// timeline joins voiced chunks into one track, recording where each chunk and character lands in it
+type timeline struct {
+	track   *audio.Track
+	chunks  []chunk
+	chars   []subtitle.Char
+	elapsed time.Duration
+}
+
+func newTimeline() *timeline {
+	return &timeline{track: &audio.Track{}}
+}
+
+// add appends a chunk's audio and the silence after it. When the track can't measure the audio, its length is
+// taken from the character timings.
+func (t *timeline) add(ch Chunk, data []byte, timings []subtitle.Char) error {
+	var duration time.Duration
+	if len(data) > 0 {
+		d, err := t.track.Append(data)
+		if err != nil {
+			return err
+		}
+		duration = d
+	}
+	if duration == 0 && len(timings) > 0 {
+		duration = timings[len(timings)-1].End
+	}
+	t.chars = append(t.chars, subtitle.Offset(timings, t.elapsed)...)
+	t.chunks = append(t.chunks, chunk{offset: ch.Offset, length: utf8.RuneCountInString(ch.Text), start: t.elapsed, duration: duration})
+	t.elapsed += duration
+	if ch.Pause > 0 {
+		if d := t.track.AppendSilence(ch.Pause); d > 0 {
+			t.elapsed += d
+		} else {
+			t.elapsed += ch.Pause
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/markup"
+	"github.com/sgerhardt/chatter/internal/script"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"strings"
+)
+
+// ProcessScript synthesizes each line of a script with its speaker's voice and joins them,
+// separated by silence, into a single file
+func (c *Pipeline) ProcessScript(s *script.Script) (err error) {
+	ctx, span := telemetry.StartSpan(c.context(), "ProcessScript")
+	defer func() { telemetry.EndSpan(span, err) }()
+	p := *c
+	p.source = "script"
+	c = &p
+	defer c.RecordUsage("")
+
+	if err = s.Validate(); err != nil {
+		return err
+	}
+	s, err = c.NormalizedScript(s)
+	if err != nil {
+		return err
+	}
+
+	parts := make([]part, len(s.Lines))
+	for i, line := range s.Lines {
+		speaker, _ := s.Speaker(line.Speaker)
+		parts[i] = part{
+			label:    fmt.Sprintf("line %d (%s): ", i+1, line.Speaker),
+			text:     line.Text,
+			voiceID:  speaker.VoiceID,
+			settings: speaker.VoiceSettings,
+		}
+		if i+1 < len(s.Lines) {
+			parts[i].pause = s.GapAfter(i)
+		}
+	}
+	track, chars, err := c.render(ctx, parts)
+	if err != nil {
+		return err
+	}
+
+	title := s.Title
+	if title == "" {
+		title = firstSentence(markup.Strip(s.Lines[0].Text))
+	}
+	tag, err := c.newArtistTag(title, castNames(s), "", "")
+	if err != nil {
+		return err
+	}
+	return c.save(ctx, tag, track, chars)
+}
+
+// castNames lists the speakers in the order they first appear
+func castNames(s *script.Script) string {
+	seen := map[string]bool{}
+	var names []string
+	for _, l := range s.Lines {
+		if !seen[l.Speaker] {
+			seen[l.Speaker] = true
+			names = append(names, l.Speaker)
+		}
+	}
+	return strings.Join(names, ", ")
+}
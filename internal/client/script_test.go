@@ -0,0 +1,71 @@
+package client_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/client/mocks"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/script"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClient_ProcessScript(t *testing.T) {
+	t.Parallel()
+
+	// a single silent MPEG 1 layer III frame, standing in for each voiced line
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+
+	dir := t.TempDir()
+	mockClient := mocks.NewHTTP(t)
+	for _, voice := range []struct{ id, text string }{{"voice-a", "Hello"}, {"voice-b", "Hi"}} {
+		mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+			return req.URL.Path == "/v1/text-to-speech/"+voice.id
+		})).Return(&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader(frame)),
+		}, nil).Run(func(args mock.Arguments) {
+			var body struct {
+				Text          string               `json:"text"`
+				VoiceSettings config.VoiceSettings `json:"voice_settings"`
+			}
+			require.NoError(t, json.NewDecoder(args.Get(0).(*http.Request).Body).Decode(&body))
+			assert.Equal(t, voice.text, body.Text)
+			if voice.id == "voice-a" {
+				assert.InDelta(t, 0.5, body.VoiceSettings.Stability, 0.001)
+			}
+		}).Once()
+	}
+
+	s := &script.Script{
+		Gap:   time.Second,
+		Lines: []script.Line{{Speaker: "ALICE", Text: "Hello"}, {Speaker: "BOB", Text: "Hi"}},
+	}
+	s.AddSpeaker("alice", script.Speaker{VoiceID: "voice-a", VoiceSettings: config.VoiceSettings{Stability: 0.5}})
+	s.AddSpeaker("bob", script.Speaker{VoiceID: "voice-b"})
+
+	// the cast is the artist, so the configured voice is never looked up
+	cfg := &config.AppConfig{CharacterRequestLimit: 100, OutputDir: dir, APIKey: "123", VoiceID: "narrator"}
+	require.NoError(t, client.New(cfg, mockClient).ProcessScript(s))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.mp3"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	out, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+
+	frameDuration := 1152 * time.Second / 44100
+	// both lines plus a second of silence between them
+	assert.Equal(t, 2*frameDuration+39*frameDuration, audio.Duration(out))
+	assert.Contains(t, string(out), "ALICE, BOB")
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Limits of the sound generation endpoint
+const (
+	MinSoundDuration = 500 * time.Millisecond
+	MaxSoundDuration = 22 * time.Second
+	// DefaultPromptInfluence is how closely a sound follows its prompt when not told otherwise
+	DefaultPromptInfluence = 0.3
+	// SoundModelID is the model sound effects are generated with
+	SoundModelID = "eleven_text_to_sound_v2"
+)
+
+// SoundRequest describes a sound effect to generate
+type SoundRequest struct {
+	Prompt string
+	// Duration is how long the sound lasts, or 0 to let the model decide
+	Duration time.Duration
+	// PromptInfluence, from 0 to 1, is how closely the sound follows the prompt rather than varying on it
+	PromptInfluence float64
+	// Context carries the trace and cancellation of the request, context.Background() when nil
+	Context context.Context
+}
+
+func (r SoundRequest) context() context.Context {
+	if r.Context != nil {
+		return r.Context
+	}
+	return context.Background()
+}
+
+// Check returns an error for an empty prompt, or a duration or prompt influence out of range
+func (r SoundRequest) Check() error {
+	if strings.TrimSpace(r.Prompt) == "" {
+		return errors.New("prompt is required")
+	}
+	if r.Duration != 0 && (r.Duration < MinSoundDuration || r.Duration > MaxSoundDuration) {
+		return fmt.Errorf("duration must be between %s and %s, got %s", MinSoundDuration, MaxSoundDuration, r.Duration)
+	}
+	if r.PromptInfluence < 0 || r.PromptInfluence > 1 {
+		return fmt.Errorf("prompt influence must be between 0 and 1, got %g", r.PromptInfluence)
+	}
+	return nil
+}
+
+type soundRequest struct {
+	Text            string   `json:"text"`
+	DurationSeconds *float64 `json:"duration_seconds,omitempty"`
+	PromptInfluence float64  `json:"prompt_influence"`
+}
+
+// soundGenerator is implemented by synthesizers that can generate sound effects
+type soundGenerator interface {
+	SoundEffect(req SoundRequest) (*Synthesis, error)
+}
+
+// SoundEffect generates a sound effect from a description, in the configured output format
+func (c *ElevenLabs) SoundEffect(req SoundRequest) (_ *Synthesis, err error) {
+	ctx, span := telemetry.StartSpan(req.context(), "SoundEffect", attribute.String("prompt", req.Prompt))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if err = req.Check(); err != nil {
+		return nil, err
+	}
+	body := soundRequest{Text: req.Prompt, PromptInfluence: req.PromptInfluence}
+	if req.Duration != 0 {
+		seconds := req.Duration.Seconds()
+		body.DurationSeconds = &seconds
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build payload: %w", err)
+	}
+	u := c.baseURL() + "/v1/sound-generation"
+	if c.Config.OutputFormat != "" {
+		u += "?output_format=" + url.QueryEscape(c.Config.OutputFormat)
+	}
+	httpReq, err := buildRequest(ctx, c.Config.APIKey, u, "audio/mpeg", payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	data, header, err := sendRequest(c.httpClient, httpReq)
+	if err != nil {
+		return nil, err
+	}
+	s := c.synthesis(c.decodeAudio(data), header)
+	s.Model = SoundModelID
+	return s, nil
+}
+
+// ProcessSound generates a sound effect into the output directory, named after its prompt, and returns the file
+func (c *Pipeline) ProcessSound(req SoundRequest) (_ string, err error) {
+	ctx, span := telemetry.StartSpan(c.context(), "ProcessSound", attribute.String("prompt", req.Prompt))
+	defer func() { telemetry.EndSpan(span, err) }()
+	p := *c
+	p.source = "sfx"
+	c = &p
+	defer c.RecordUsage("")
+
+	g, ok := c.synth.(soundGenerator)
+	if !ok {
+		return "", errors.New("sound effects need the elevenlabs provider")
+	}
+	req.Context = ctx
+	res, err := g.SoundEffect(req)
+	if err != nil {
+		return "", err
+	}
+	c.addUsage(req.Prompt, "", res)
+
+	filename := c.soundFile(req.Prompt, audio.Detect(res.Audio))
+	if c.NoSave {
+		return "", c.play(ctx, res.Audio)
+	}
+	if err = c.write(ctx, filename, res.Audio); err != nil {
+		return "", err
+	}
+	c.RecordUsage(filename)
+	return filename, c.play(ctx, res.Audio)
+}
+
+// soundFile names a sound after its prompt in the output directory, numbering it when the name is taken
+func (c *Pipeline) soundFile(prompt string, format audio.Format) string {
+	base := filepath.Join(c.Config.OutputDir, slug(prompt))
+	name := base + "." + string(format)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+			return name
+		}
+		name = base + "-" + strconv.Itoa(i) + "." + string(format)
+	}
+}
+
+// slugLength is the longest a file name made from a prompt gets, before its extension
+const slugLength = 60
+
+// slug turns a prompt into a file name of lower case words joined by hyphens, cut after the last whole word that fits
+func slug(prompt string) string {
+	words := strings.FieldsFunc(strings.ToLower(prompt), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return "sound"
+	}
+	name := words[0]
+	for _, w := range words[1:] {
+		if len(name)+1+len(w) > slugLength {
+			break
+		}
+		name += "-" + w
+	}
+	if len(name) > slugLength {
+		name = strings.ToValidUTF8(name[:slugLength], "")
+	}
+	return name
+}
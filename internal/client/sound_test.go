@@ -0,0 +1,100 @@
+package client_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/client/mocks"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestElevenLabs_SoundEffect(t *testing.T) {
+	t.Parallel()
+
+	s := fakeeleven.New(t, fakeeleven.WithAPIKey("123"))
+	cfg := &config.AppConfig{APIKey: "123", BaseURL: s.URL}
+	res, err := client.New(cfg, s.Client()).SoundEffect(client.SoundRequest{Prompt: "door creaks open",
+		Duration: 3 * time.Second, PromptInfluence: 0.5})
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, audio.Duration(res.Audio).Round(100*time.Millisecond))
+	assert.Equal(t, client.SoundModelID, res.Model)
+	assert.Equal(t, 120, res.Characters)
+
+	requests := s.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/v1/sound-generation", requests[0].Path)
+	assert.JSONEq(t, `{"text":"door creaks open","duration_seconds":3,"prompt_influence":0.5}`, string(requests[0].Body))
+
+	_, err = client.New(cfg, s.Client()).SoundEffect(client.SoundRequest{Prompt: "thunder"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text":"thunder","prompt_influence":0}`, string(s.Requests()[1].Body))
+
+	for _, req := range []client.SoundRequest{
+		{Prompt: " "},
+		{Prompt: "rain", Duration: 100 * time.Millisecond},
+		{Prompt: "rain", Duration: time.Minute},
+		{Prompt: "rain", PromptInfluence: 1.5},
+	} {
+		_, err = client.New(cfg, s.Client()).SoundEffect(req)
+		assert.Error(t, err, req)
+	}
+	assert.Len(t, s.Requests(), 2)
+}
+
+func TestPipeline_ProcessSound(t *testing.T) {
+	t.Parallel()
+
+	t.Run("names the sound after its prompt", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		ledger := &memoryLedger{}
+		cfg := &config.AppConfig{BaseURL: s.URL, OutputDir: t.TempDir(), Tag: "game"}
+		c := client.New(cfg, s.Client())
+		c.Ledger = ledger
+		req := client.SoundRequest{Prompt: "Door creaks open... slowly!", Duration: time.Second}
+
+		name, err := c.ProcessSound(req)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(cfg.OutputDir, "door-creaks-open-slowly.mp3"), name)
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		assert.Equal(t, time.Second, audio.Duration(data).Round(100*time.Millisecond))
+
+		name, err = c.ProcessSound(req)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(cfg.OutputDir, "door-creaks-open-slowly-2.mp3"), name)
+
+		require.Len(t, ledger.entries, 2)
+		assert.Equal(t, "sfx", ledger.entries[0].Source)
+		assert.Equal(t, "game", ledger.entries[0].Tag)
+		assert.Equal(t, 40, ledger.entries[0].Characters)
+		assert.Equal(t, name, ledger.entries[1].Output)
+	})
+
+	t.Run("shortens long prompts", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		cfg := &config.AppConfig{BaseURL: s.URL, OutputDir: t.TempDir()}
+		name, err := client.New(cfg, s.Client()).ProcessSound(client.SoundRequest{
+			Prompt: "a very long description of rain falling on a tin roof in the middle of a summer night"})
+		require.NoError(t, err)
+		assert.Equal(t, "a-very-long-description-of-rain-falling-on-a-tin-roof-in-the.mp3", filepath.Base(name))
+
+		name, err = client.New(cfg, s.Client()).ProcessSound(client.SoundRequest{Prompt: "!!!"})
+		require.NoError(t, err)
+		assert.Equal(t, "sound.mp3", filepath.Base(name))
+	})
+
+	t.Run("needs a provider that can generate sounds", func(t *testing.T) {
+		t.Parallel()
+		cfg := &config.AppConfig{VoiceID: "en-us", OutputDir: t.TempDir()}
+		_, err := client.NewPipeline(cfg, mocks.NewHTTP(t), &fakeSynthesizer{}).ProcessSound(client.SoundRequest{Prompt: "rain"})
+		assert.EqualError(t, err, "sound effects need the elevenlabs provider")
+	})
+}
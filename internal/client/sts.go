@@ -0,0 +1,178 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"github.com/sgerhardt/chatter/internal/transcode"
+	"go.opentelemetry.io/otel/attribute"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultSTSModelID is the speech-to-speech model used when none is configured
+const DefaultSTSModelID = "eleven_english_sts_v2"
+
+// DefaultSegmentLength is the longest piece of a recording sent in one speech-to-speech request
+const DefaultSegmentLength = 4 * time.Minute
+
+// SpeechToSpeech voices a recording with another voice, keeping its delivery, and returns the audio in the
+// configured output format
+func (c *ElevenLabs) SpeechToSpeech(req SpeechRequest) (_ *Synthesis, err error) {
+	ctx, span := telemetry.StartSpan(req.context(), "SpeechToSpeech", attribute.String("voice_id", req.VoiceID),
+		attribute.Int("bytes", len(req.Audio)))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if req.VoiceID == "" {
+		return nil, fmt.Errorf("voice ID is required")
+	}
+	model := c.Config.ModelID
+	if model == "" {
+		model = DefaultSTSModelID
+	}
+	settings, err := json.Marshal(voiceSettings{
+		Stability:       req.Settings.Stability,
+		SimilarityBoost: req.Settings.SimilarityBoost,
+		Style:           req.Settings.Style,
+		UseSpeakerBoost: req.Settings.UseSpeakerBoost,
+		Speed:           req.Settings.Speed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build payload: %w", err)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	name, contentType := "recording.mp3", "audio/mpeg"
+	if audio.Detect(req.Audio) == audio.WAV {
+		name, contentType = "recording.wav", "audio/wav"
+	}
+	part, err := w.CreatePart(fileHeader("audio", name, contentType))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = part.Write(req.Audio); err != nil {
+		return nil, err
+	}
+	for _, field := range [][2]string{{"model_id", model}, {"voice_settings", string(settings)}} {
+		if err = w.WriteField(field[0], field[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%s/v1/speech-to-speech/%s", c.baseURL(), req.VoiceID)
+	if c.Config.OutputFormat != "" {
+		u += "?output_format=" + url.QueryEscape(c.Config.OutputFormat)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", u, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	httpReq.Header.Add("Accept", "audio/mpeg")
+	httpReq.Header.Add("Content-Type", w.FormDataContentType())
+	httpReq.Header.Add("xi-api-key", c.Config.APIKey)
+
+	data, header, err := sendRequest(c.httpClient, httpReq)
+	if err != nil {
+		return nil, err
+	}
+	s := c.synthesis(c.decodeAudio(data), header)
+	s.Model = model
+	return s, nil
+}
+
+// quoteEscaper escapes a quoted multipart parameter, as mime/multipart does
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// fileHeader is the header of a multipart file upload
+func fileHeader(field, filename, contentType string) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Disposition": {fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(field), quoteEscaper.Replace(filename))},
+		"Content-Type":        {contentType},
+	}
+}
+
+// ProcessRecording voices a recording with the configured voice into a single file. Recordings longer than
+// SegmentLength are cut at pauses, voiced a piece at a time and joined again.
+func (c *Pipeline) ProcessRecording(name string, recording []byte) (err error) {
+	ctx, span := telemetry.StartSpan(c.context(), "ProcessRecording", attribute.String("file", name))
+	defer func() { telemetry.EndSpan(span, err) }()
+	p := *c
+	p.source = "recording"
+	c = &p
+	defer c.RecordUsage("")
+
+	vc, ok := c.synth.(voiceChanger)
+	if !ok {
+		return errors.New("speech to speech needs the elevenlabs provider")
+	}
+	segments, err := c.segments(ctx, recording)
+	if err != nil {
+		return err
+	}
+	track := &audio.Track{}
+	for i, segment := range segments {
+		res, sErr := vc.SpeechToSpeech(SpeechRequest{Audio: segment, VoiceID: c.Config.VoiceID, Settings: c.Config.VoiceSettings,
+			Context: ctx})
+		if sErr != nil {
+			return fmt.Errorf("segment %d of %d: %w", i+1, len(segments), sErr)
+		}
+		c.addUsage("", c.Config.VoiceID, res)
+		if _, sErr = track.Append(res.Audio); sErr != nil {
+			return sErr
+		}
+	}
+
+	tag, err := c.newTag(strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)), "", name)
+	if err != nil {
+		return err
+	}
+	return c.save(ctx, tag, track, nil)
+}
+
+// segments cuts a recording longer than the segment length into WAV pieces at its pauses. Shorter recordings are
+// sent as they are, and longer mp3 recordings are decoded with ffmpeg first.
+func (c *Pipeline) segments(ctx context.Context, recording []byte) ([][]byte, error) {
+	limit := c.SegmentLength
+	if limit <= 0 {
+		limit = DefaultSegmentLength
+	}
+	wav := recording
+	if audio.Detect(recording) == audio.MP3 {
+		if audio.Duration(recording) <= limit {
+			return [][]byte{recording}, nil
+		}
+		var err error
+		if wav, err = transcode.ToWAV(ctx, audio.SkipID3(recording)); err != nil {
+			return nil, fmt.Errorf("recording is longer than %s: %w", limit, err)
+		}
+	}
+	f, pcm, err := audio.ParseWAV(wav)
+	if err != nil {
+		return nil, err
+	}
+	if f.Duration(len(pcm)) <= limit {
+		return [][]byte{recording}, nil
+	}
+	pieces, err := audio.SplitOnSilence(f, pcm, limit)
+	if err != nil {
+		return nil, err
+	}
+	segments := make([][]byte, len(pieces))
+	for i, piece := range pieces {
+		segments[i] = audio.EncodeWAV(f, piece)
+	}
+	return segments, nil
+}
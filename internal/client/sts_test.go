@@ -0,0 +1,109 @@
+package client_test
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/client/mocks"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// recording returns a WAV of words, 400ms of noise each, separated by 200ms of silence
+func recording(words int) []byte {
+	f := audio.WAVFormat{AudioFormat: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16}
+	var pcm []byte
+	for i := 0; i < words; i++ {
+		pcm = append(pcm, bytes.Repeat([]byte{0x00, 0x40, 0x00, 0xC0}, 3200)...)
+		pcm = append(pcm, make([]byte, 6400)...)
+	}
+	return audio.EncodeWAV(f, pcm)
+}
+
+func TestElevenLabs_SpeechToSpeech(t *testing.T) {
+	t.Parallel()
+
+	s := fakeeleven.New(t, fakeeleven.WithAPIKey("123"))
+	cfg := &config.AppConfig{APIKey: "123", BaseURL: s.URL, OutputFormat: "mp3_44100_128"}
+	voiceID := fakeeleven.DefaultVoices[0].VoiceID
+	res, err := client.New(cfg, s.Client()).SpeechToSpeech(client.SpeechRequest{Audio: recording(5), VoiceID: voiceID,
+		Settings: config.VoiceSettings{Stability: 0.4, SimilarityBoost: 0.8}})
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, audio.Duration(res.Audio).Round(100*time.Millisecond))
+	assert.Equal(t, client.DefaultSTSModelID, res.Model)
+	assert.Equal(t, 50, res.Characters)
+
+	requests := s.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/v1/speech-to-speech/"+voiceID, requests[0].Path)
+	_, params, err := mime.ParseMediaType(requests[0].Header.Get("Content-Type"))
+	require.NoError(t, err)
+	form, err := multipart.NewReader(bytes.NewReader(requests[0].Body), params["boundary"]).ReadForm(1 << 20)
+	require.NoError(t, err)
+	assert.Equal(t, []string{client.DefaultSTSModelID}, form.Value["model_id"])
+	assert.JSONEq(t, `{"stability":0.4,"similarity_boost":0.8}`, form.Value["voice_settings"][0])
+	require.Len(t, form.File["audio"], 1)
+	assert.Equal(t, "recording.wav", form.File["audio"][0].Filename)
+
+	_, err = client.New(cfg, s.Client()).SpeechToSpeech(client.SpeechRequest{Audio: recording(1)})
+	assert.EqualError(t, err, "voice ID is required")
+	_, err = client.New(cfg, s.Client()).SpeechToSpeech(client.SpeechRequest{Audio: recording(1), VoiceID: "missing"})
+	assert.ErrorContains(t, err, http.StatusText(http.StatusNotFound))
+}
+
+func TestPipeline_ProcessRecording(t *testing.T) {
+	t.Parallel()
+
+	t.Run("revoices a long recording in segments cut at pauses", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t, fakeeleven.WithAPIKey("123"))
+		ledger := &memoryLedger{}
+		cfg := &config.AppConfig{APIKey: "123", BaseURL: s.URL, OutputDir: t.TempDir(), VoiceID: fakeeleven.DefaultVoices[0].VoiceID}
+		c := client.New(cfg, s.Client())
+		c.SegmentLength = 2 * time.Second
+		c.Ledger = ledger
+		require.NoError(t, c.ProcessRecording("interview.wav", recording(8)))
+
+		requests := s.Requests()
+		// three segments, then the voice name for the artist tag
+		require.Len(t, requests, 4)
+		files, err := filepath.Glob(filepath.Join(cfg.OutputDir, "*.mp3"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		data, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.InDelta(t, 4800*time.Millisecond, audio.Duration(data), float64(100*time.Millisecond))
+
+		require.Len(t, ledger.entries, 3)
+		assert.Equal(t, "recording", ledger.entries[0].Source)
+		assert.Equal(t, files[0], ledger.entries[0].Output)
+	})
+
+	t.Run("needs a provider that can revoice", func(t *testing.T) {
+		t.Parallel()
+		cfg := &config.AppConfig{VoiceID: "en-us", OutputDir: t.TempDir()}
+		err := client.NewPipeline(cfg, mocks.NewHTTP(t), &fakeSynthesizer{}).ProcessRecording("a.wav", recording(1))
+		assert.EqualError(t, err, "speech to speech needs the elevenlabs provider")
+	})
+
+	t.Run("keeps a short mp3 as it is", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		cfg := &config.AppConfig{BaseURL: s.URL, OutputDir: t.TempDir(), VoiceID: fakeeleven.DefaultVoices[0].VoiceID}
+		mp3 := audio.Silence(time.Second, nil)
+		require.NoError(t, client.New(cfg, s.Client()).ProcessRecording("memo.mp3", mp3))
+		requests := s.Requests()
+		require.Len(t, requests, 2)
+		assert.True(t, bytes.Contains(requests[0].Body, mp3))
+		assert.Contains(t, requests[0].Header.Get("Content-Type"), "multipart/form-data")
+	})
+}
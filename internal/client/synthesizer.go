@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/markup"
+	"github.com/sgerhardt/chatter/internal/subtitle"
+)
+
+// Providers chatter can synthesize speech with
+const (
+	ProviderElevenLabs = "elevenlabs"
+	ProviderOpenAI     = "openai"
+	ProviderCommand    = "command"
+)
+
+// Providers lists the supported providers, the default first
+func Providers() []string {
+	return []string{ProviderElevenLabs, ProviderOpenAI, ProviderCommand}
+}
+
+// ErrNoTimestamps is returned by synthesizers that cannot report when each character is spoken
+var ErrNoTimestamps = errors.New("provider does not support character timestamps")
+
+// Synthesizer turns text into audio
+type Synthesizer interface {
+	Synthesize(req SynthesisRequest) (*Synthesis, error)
+}
+
+// SynthesisRequest is a piece of text to voice
+type SynthesisRequest struct {
+	Text     string
+	VoiceID  string
+	Settings config.VoiceSettings
+	// Timestamps asks for character timings, used for subtitles
+	Timestamps bool
+	// Context carries the trace and cancellation of the request, context.Background() when nil
+	Context context.Context
+}
+
+func (r SynthesisRequest) context() context.Context {
+	if r.Context != nil {
+		return r.Context
+	}
+	return context.Background()
+}
+
+// Synthesis is voiced text: mp3 or WAV audio, with character timings when they were requested
+type Synthesis struct {
+	Audio []byte
+	Chars []subtitle.Char
+	// Model is the model that voiced the text, "" when the provider has none
+	Model string
+	// Characters is how many characters the provider billed, 0 when it doesn't say
+	Characters int
+	// RequestID identifies the request to the provider, "" when it doesn't send one
+	RequestID string
+}
+
+// SpeechRequest is a recording to voice again with another voice
+type SpeechRequest struct {
+	// Audio is the recording, mp3 or WAV
+	Audio    []byte
+	VoiceID  string
+	Settings config.VoiceSettings
+	// Context carries the trace and cancellation of the request, context.Background() when nil
+	Context context.Context
+}
+
+func (r SpeechRequest) context() context.Context {
+	if r.Context != nil {
+		return r.Context
+	}
+	return context.Background()
+}
+
+// voiceChanger is implemented by synthesizers that can voice recordings with another voice
+type voiceChanger interface {
+	SpeechToSpeech(req SpeechRequest) (*Synthesis, error)
+}
+
+// markupSupporter is implemented by synthesizers that render some markup natively
+type markupSupporter interface {
+	MarkupSupport() markup.Support
+}
+
+// characterLimiter is implemented by synthesizers that accept less text per request than the configured limit
+type characterLimiter interface {
+	CharacterLimit() int
+}
+
+// NewSynthesizer returns the synthesizer for the configured provider, Eleven Labs when none is set
+func NewSynthesizer(cfg *config.AppConfig, httpClient HTTP) (Synthesizer, error) {
+	switch cfg.Provider {
+	case "", ProviderElevenLabs:
+		return New(cfg, httpClient), nil
+	case ProviderOpenAI:
+		return NewOpenAI(cfg, httpClient), nil
+	case ProviderCommand:
+		return NewCommand(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q, expected one of %v", cfg.Provider, Providers())
+	}
+}
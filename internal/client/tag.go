@@ -0,0 +1,130 @@
+package client
+
+import (
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/id3"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxTitleLength = 80
+
+// chunk records where a synthesized piece of text sits in both the source text and the merged audio
+type chunk struct {
+	offset   int
+	length   int
+	start    time.Duration
+	duration time.Duration
+}
+
+// newTag builds the ID3 metadata shared by all outputs. The album falls back to the given default when no job name is set.
+func (c *Pipeline) newTag(title, album, source string) (*id3.Tag, error) {
+	artist := c.Config.VoiceName
+	if artist == "" {
+		artist = c.voiceName()
+	}
+	return c.newArtistTag(title, artist, album, source)
+}
+
+// newArtistTag builds the ID3 metadata with the given artist, for outputs voiced by more than the configured voice
+func (c *Pipeline) newArtistTag(title, artist, album, source string) (*id3.Tag, error) {
+	tag := &id3.Tag{
+		Title:   title,
+		Artist:  artist,
+		Album:   c.Config.JobName,
+		Comment: source,
+	}
+	if tag.Album == "" {
+		tag.Album = album
+	}
+	if c.Config.CoverArt != "" {
+		cover, err := os.ReadFile(c.Config.CoverArt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cover art: %w", err)
+		}
+		tag.Cover = cover
+	}
+	return tag, nil
+}
+
+// voiceLooker is implemented by synthesizers that can look up a voice by its ID
+type voiceLooker interface {
+	GetVoice(id string) (*Voice, error)
+}
+
+// voiceName returns the name of the configured voice for the artist tag, or its ID when the name can't be looked up
+func (c *Pipeline) voiceName() string {
+	id := c.Config.VoiceID
+	l, ok := c.synth.(voiceLooker)
+	if id == "" || !ok {
+		return id
+	}
+	v, err := l.GetVoice(id)
+	if err != nil || v.Name == "" {
+		slog.Debug("using the voice ID as the artist tag", "voice", id, "err", err)
+		return id
+	}
+	return v.Name
+}
+
+// firstSentence returns the first sentence of text, shortened to a usable track title
+func firstSentence(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexAny(text, ".!?\n"); i >= 0 {
+		text = text[:i]
+	}
+	if utf8.RuneCountInString(text) > maxTitleLength {
+		text = strings.TrimSpace(string([]rune(text)[:maxTitleLength])) + "…"
+	}
+	return text
+}
+
+// domain returns the host of a URL, or the URL itself when it cannot be parsed
+func domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// chaptersFor places the document headings on the merged audio timeline.
+// Headings inside a chunk are positioned proportionally to their character offset within it.
+func chaptersFor(doc *document, chunks []chunk, total time.Duration) []id3.Chapter {
+	if len(doc.Headings) == 0 || len(chunks) == 0 {
+		return nil
+	}
+	var chapters []id3.Chapter
+	if doc.Headings[0].Offset > 0 {
+		title := doc.Title
+		if title == "" {
+			title = "Introduction"
+		}
+		chapters = append(chapters, id3.Chapter{Title: title})
+	}
+	for _, h := range doc.Headings {
+		chapters = append(chapters, id3.Chapter{Title: h.Title, Start: timeAt(h.Offset, chunks)})
+	}
+	for i := range chapters {
+		if i+1 < len(chapters) {
+			chapters[i].End = chapters[i+1].Start
+		} else {
+			chapters[i].End = total
+		}
+	}
+	return chapters
+}
+
+func timeAt(offset int, chunks []chunk) time.Duration {
+	for _, ch := range chunks {
+		if offset < ch.offset+ch.length && ch.length > 0 {
+			return ch.start + ch.duration*time.Duration(offset-ch.offset)/time.Duration(ch.length)
+		}
+	}
+	last := chunks[len(chunks)-1]
+	return last.start + last.duration
+}
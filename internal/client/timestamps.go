@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/subtitle"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// timestampResponse is the body returned by the with-timestamps endpoint
+type timestampResponse struct {
+	AudioBase64         string     `json:"audio_base64"`
+	Alignment           *alignment `json:"alignment"`
+	NormalizedAlignment *alignment `json:"normalized_alignment"`
+}
+
+type alignment struct {
+	Characters                 []string  `json:"characters"`
+	CharacterStartTimesSeconds []float64 `json:"character_start_times_seconds"`
+	CharacterEndTimesSeconds   []float64 `json:"character_end_times_seconds"`
+}
+
+func (a *alignment) chars() ([]subtitle.Char, error) {
+	if len(a.Characters) != len(a.CharacterStartTimesSeconds) || len(a.Characters) != len(a.CharacterEndTimesSeconds) {
+		return nil, fmt.Errorf("alignment has %d characters but %d start and %d end times",
+			len(a.Characters), len(a.CharacterStartTimesSeconds), len(a.CharacterEndTimesSeconds))
+	}
+	chars := make([]subtitle.Char, len(a.Characters))
+	for i, ch := range a.Characters {
+		chars[i] = subtitle.Char{
+			Text:  ch,
+			Start: seconds(a.CharacterStartTimesSeconds[i]),
+			End:   seconds(a.CharacterEndTimesSeconds[i]),
+		}
+	}
+	return chars, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// FromTextWithTimestamps converts text to audio and returns when each character is spoken
+func (c *ElevenLabs) FromTextWithTimestamps(text string, voiceID string) ([]byte, []subtitle.Char, error) {
+	s, err := c.fromTextWithTimestamps(c.context(), text, voiceID, c.Config.VoiceSettings)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.Audio, s.Chars, nil
+}
+
+func (c *ElevenLabs) fromTextWithTimestamps(ctx context.Context, text string, voiceID string, settings config.VoiceSettings) (_ *Synthesis, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "FromTextWithTimestamps", attribute.String("voice_id", voiceID),
+		attribute.Int("characters", utf8.RuneCountInString(text)))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if count := utf8.RuneCountInString(text); count > c.Config.CharacterRequestLimit {
+		return nil, fmt.Errorf("text limit is %d characters, got :%d", c.Config.CharacterRequestLimit, count)
+	}
+	if voiceID == "" {
+		return nil, fmt.Errorf("voice ID is required")
+	}
+
+	payload, err := c.buildPayload(text, settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build payload: %w", err)
+	}
+
+	req, err := buildRequest(ctx, c.Config.APIKey, c.ttsURL(voiceID, "/with-timestamps"), "application/json", payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	body, header, err := sendRequest(c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var res timestampResponse
+	if err = json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(res.AudioBase64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode audio: %w", err)
+	}
+	s := c.synthesis(c.decodeAudio(data), header)
+	if res.Alignment == nil {
+		return s, nil
+	}
+	if s.Chars, err = res.Alignment.chars(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// writeSubtitles writes .srt and .vtt captions next to the audio file
+func writeSubtitles(audioFile string, chars []subtitle.Char) error {
+	cues := subtitle.Cues(chars)
+	base := strings.TrimSuffix(audioFile, filepath.Ext(audioFile))
+	for _, format := range []struct {
+		ext   string
+		write func(io.Writer, []subtitle.Cue) error
+	}{
+		{".srt", subtitle.WriteSRT},
+		{".vtt", subtitle.WriteVTT},
+	} {
+		f, err := os.Create(base + format.ext)
+		if err != nil {
+			return fmt.Errorf("failed to create subtitles: %w", err)
+		}
+		err = format.write(f, cues)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write subtitles: %w", err)
+		}
+	}
+	return nil
+}
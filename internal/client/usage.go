@@ -0,0 +1,89 @@
+package client
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Usage is what one synthesis request cost, for charging it back to a team
+type Usage struct {
+	Time    time.Time `json:"time"`
+	Profile string    `json:"profile,omitempty"`
+	VoiceID string    `json:"voice_id"`
+	Model   string    `json:"model,omitempty"`
+	// Characters is what the provider billed, or the length of the text when it doesn't say
+	Characters int `json:"characters"`
+	// Source is the website voiced, "text", "script" or "recording"
+	Source string `json:"source"`
+	// Output is the file the audio went into, "" when the render failed before writing one
+	Output    string `json:"output,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+}
+
+// UsageLedger keeps a record of every synthesis
+type UsageLedger interface {
+	Record(usage []Usage) error
+}
+
+// usageLog holds the usage of a pipeline's syntheses until the file they go into is known
+type usageLog struct {
+	mu      sync.Mutex
+	pending []Usage
+}
+
+// addUsage notes a synthesis of text, when the pipeline has a ledger
+func (c *Pipeline) addUsage(text, voiceID string, s *Synthesis) {
+	if c.Ledger == nil {
+		return
+	}
+	u := Usage{
+		Time:       time.Now().UTC(),
+		Profile:    c.Config.Profile,
+		VoiceID:    voiceID,
+		Model:      s.Model,
+		Characters: s.Characters,
+		Source:     c.usageSource(),
+		RequestID:  s.RequestID,
+		Tag:        c.Config.Tag,
+	}
+	if u.Characters == 0 {
+		u.Characters = utf8.RuneCountInString(text)
+	}
+	c.usage.mu.Lock()
+	defer c.usage.mu.Unlock()
+	c.usage.pending = append(c.usage.pending, u)
+}
+
+// RecordUsage writes the usage of the syntheses since the last call to the ledger, as having gone into output.
+// The audio has been paid for by then, so a ledger that fails is logged rather than failing the synthesis.
+func (c *Pipeline) RecordUsage(output string) {
+	if c.Ledger == nil {
+		return
+	}
+	c.usage.mu.Lock()
+	pending := c.usage.pending
+	c.usage.pending = nil
+	c.usage.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	for i := range pending {
+		pending[i].Output = output
+	}
+	if err := c.Ledger.Record(pending); err != nil {
+		slog.Warn("failed to record usage", "output", output, "syntheses", len(pending), "err", err)
+	}
+}
+
+func (c *Pipeline) usageSource() string {
+	switch {
+	case c.source != "":
+		return c.source
+	case c.Config.WebsiteURL != "":
+		return c.Config.WebsiteURL
+	}
+	return "text"
+}
@@ -0,0 +1,27 @@
+package client
+
+import "net/http"
+
+// Subscription is the account's plan and character usage
+type Subscription struct {
+	Tier           string `json:"tier"`
+	Status         string `json:"status"`
+	CharacterCount int    `json:"character_count"`
+	CharacterLimit int    `json:"character_limit"`
+}
+
+// User is the account an API key belongs to
+type User struct {
+	UserID       string       `json:"user_id"`
+	FirstName    string       `json:"first_name"`
+	Subscription Subscription `json:"subscription"`
+}
+
+// User returns the account for the configured API key, failing when the key is invalid
+func (c *ElevenLabs) User() (*User, error) {
+	var u User
+	if err := c.apiJSON(http.MethodGet, "/v1/user", nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
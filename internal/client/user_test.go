@@ -0,0 +1,24 @@
+package client_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestElevenLabs_User(t *testing.T) {
+	t.Parallel()
+
+	s := fakeeleven.New(t, fakeeleven.WithAPIKey("good"), fakeeleven.WithCharacterLimit(500))
+	u, err := client.New(&config.AppConfig{APIKey: "good", BaseURL: s.URL}, http.DefaultClient).User()
+	require.NoError(t, err)
+	assert.Equal(t, "Fake", u.FirstName)
+	assert.Equal(t, client.Subscription{Tier: "free", Status: "active", CharacterLimit: 500}, u.Subscription)
+
+	_, err = client.New(&config.AppConfig{APIKey: "bad", BaseURL: s.URL}, http.DefaultClient).User()
+	assert.Error(t, err)
+}
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/config"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Voice is a voice available to the account
+type Voice struct {
+	VoiceID     string                `json:"voice_id"`
+	Name        string                `json:"name"`
+	Category    string                `json:"category,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Labels      map[string]string     `json:"labels,omitempty"`
+	PreviewURL  string                `json:"preview_url,omitempty"`
+	Samples     []VoiceSample         `json:"samples,omitempty"`
+	Settings    *config.VoiceSettings `json:"settings,omitempty"`
+}
+
+// VoiceSample is a recording a cloned voice was made from
+type VoiceSample struct {
+	SampleID  string `json:"sample_id"`
+	FileName  string `json:"file_name"`
+	MimeType  string `json:"mime_type"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// Voices lists the premade, cloned and library voices the account can use
+func (c *ElevenLabs) Voices() ([]Voice, error) {
+	var res struct {
+		Voices []Voice `json:"voices"`
+	}
+	if err := c.apiJSON(http.MethodGet, "/v1/voices", nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Voices, nil
+}
+
+// Limits on the samples uploaded to clone a voice
+const (
+	MaxVoiceSamples = 25
+	MaxSampleSize   = 10 << 20
+)
+
+// SampleFile is a recording to upload to a voice
+type SampleFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// LoadSamples reads recordings to upload, checking each is audio in an accepted format and small enough, and that
+// there aren't too many
+func LoadSamples(paths []string) ([]SampleFile, error) {
+	if len(paths) > MaxVoiceSamples {
+		return nil, fmt.Errorf("at most %d samples can be uploaded, got %d", MaxVoiceSamples, len(paths))
+	}
+	samples := make([]SampleFile, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > MaxSampleSize {
+			return nil, fmt.Errorf("%s is %.1f MB, samples can be at most %d MB", path, float64(info.Size())/(1<<20), MaxSampleSize>>20)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		contentType := sampleType(data)
+		if contentType == "" {
+			return nil, fmt.Errorf("%s is not mp3, WAV, FLAC, OGG or M4A audio", path)
+		}
+		samples = append(samples, SampleFile{Name: filepath.Base(path), ContentType: contentType, Data: data})
+	}
+	return samples, nil
+}
+
+// sampleType returns the content type of a recording, or "" when it isn't in a format accepted for samples
+func sampleType(data []byte) string {
+	switch {
+	case audio.IsWAV(data):
+		return "audio/wav"
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return "audio/flac"
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return "audio/ogg"
+	case len(data) > 8 && string(data[4:8]) == "ftyp":
+		return "audio/mp4"
+	case audio.IsMP3(data):
+		return "audio/mpeg"
+	}
+	return ""
+}
+
+// VoiceEdit is a voice to add, or the changes to make to one. Empty fields are left as they are when editing.
+type VoiceEdit struct {
+	Name        string
+	Description string
+	Labels      map[string]string
+	Samples     []SampleFile
+	// RemoveBackgroundNoise cleans up the samples before they are used
+	RemoveBackgroundNoise bool
+}
+
+// AddVoice clones a voice from samples and returns its ID
+func (c *ElevenLabs) AddVoice(v VoiceEdit) (string, error) {
+	if v.Name == "" {
+		return "", errors.New("voice name is required")
+	}
+	if len(v.Samples) == 0 {
+		return "", errors.New("at least one sample is required")
+	}
+	var res struct {
+		VoiceID string `json:"voice_id"`
+	}
+	if err := c.apiForm("/v1/voices/add", v, &res); err != nil {
+		return "", fmt.Errorf("failed to add voice: %w", err)
+	}
+	return res.VoiceID, nil
+}
+
+// EditVoice renames a voice, changes its description or labels, or uploads more samples to it
+func (c *ElevenLabs) EditVoice(id string, v VoiceEdit) error {
+	// the name is required by the endpoint, so an edit that doesn't rename keeps the current one
+	if v.Name == "" {
+		current, err := c.GetVoice(id)
+		if err != nil {
+			return err
+		}
+		v.Name = current.Name
+	}
+	if err := c.apiForm("/v1/voices/"+url.PathEscape(id)+"/edit", v, nil); err != nil {
+		return fmt.Errorf("failed to edit voice: %w", err)
+	}
+	return nil
+}
+
+// DeleteVoice deletes a voice from the account
+func (c *ElevenLabs) DeleteVoice(id string) error {
+	if err := c.apiJSON(http.MethodDelete, "/v1/voices/"+url.PathEscape(id), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete voice: %w", err)
+	}
+	return nil
+}
+
+// GetVoice returns a voice with its samples and settings
+func (c *ElevenLabs) GetVoice(id string) (*Voice, error) {
+	var v Voice
+	if err := c.apiJSON(http.MethodGet, "/v1/voices/"+url.PathEscape(id)+"?with_settings=true", nil, &v); err != nil {
+		return nil, fmt.Errorf("failed to get voice: %w", err)
+	}
+	return &v, nil
+}
+
+// VoiceSettings returns the settings a voice speaks with when a request doesn't give its own
+func (c *ElevenLabs) VoiceSettings(id string) (*config.VoiceSettings, error) {
+	var s config.VoiceSettings
+	if err := c.apiJSON(http.MethodGet, "/v1/voices/"+url.PathEscape(id)+"/settings", nil, &s); err != nil {
+		return nil, fmt.Errorf("failed to get voice settings: %w", err)
+	}
+	return &s, nil
+}
+
+// EditVoiceSettings changes the settings a voice speaks with by default
+func (c *ElevenLabs) EditVoiceSettings(id string, s config.VoiceSettings) error {
+	// unlike in a synthesis request, a zero style or speaker boost left out would keep the voice's current one
+	body := struct {
+		Stability       float64 `json:"stability"`
+		SimilarityBoost float64 `json:"similarity_boost"`
+		Style           float64 `json:"style"`
+		UseSpeakerBoost bool    `json:"use_speaker_boost"`
+		Speed           float64 `json:"speed,omitempty"`
+	}{s.Stability, s.SimilarityBoost, s.Style, s.UseSpeakerBoost, s.Speed}
+	if err := c.apiJSON(http.MethodPost, "/v1/voices/"+url.PathEscape(id)+"/settings/edit", body, nil); err != nil {
+		return fmt.Errorf("failed to edit voice settings: %w", err)
+	}
+	return nil
+}
+
+// apiForm posts a voice as a multipart form, with its samples as files, and decodes the JSON response into out
+func (c *ElevenLabs) apiForm(path string, v VoiceEdit, out any) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fields := [][2]string{{"name", v.Name}}
+	if v.Description != "" {
+		fields = append(fields, [2]string{"description", v.Description})
+	}
+	if len(v.Labels) > 0 {
+		labels, err := json.Marshal(v.Labels)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, [2]string{"labels", string(labels)})
+	}
+	if v.RemoveBackgroundNoise {
+		fields = append(fields, [2]string{"remove_background_noise", "true"})
+	}
+	for _, field := range fields {
+		if err := w.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+	for _, s := range v.Samples {
+		part, err := w.CreatePart(fileHeader("files", s.Name, s.ContentType))
+		if err != nil {
+			return err
+		}
+		if _, err = part.Write(s.Data); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(c.context(), http.MethodPost, c.baseURL()+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", w.FormDataContentType())
+	req.Header.Add("xi-api-key", c.Config.APIKey)
+	res, err := c.doRequest(req)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err = json.Unmarshal(res, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package client_test
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand/v2"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestElevenLabs_Voices(t *testing.T) {
+	t.Parallel()
+
+	s := fakeeleven.New(t, fakeeleven.WithVoices(fakeeleven.Voice{VoiceID: "v1", Name: "Narrator", Category: "cloned"}))
+	voices, err := client.New(&config.AppConfig{APIKey: "123", BaseURL: s.URL}, http.DefaultClient).Voices()
+	require.NoError(t, err)
+	assert.Equal(t, []client.Voice{{VoiceID: "v1", Name: "Narrator", Category: "cloned"}}, voices)
+}
+
+func TestLoadSamples(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0600))
+		return path
+	}
+	mp3 := write("a.mp3", audio.Silence(time.Second, nil))
+	wav := write("b.wav", audio.EncodeWAV(audio.WAVFormat{AudioFormat: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16}, make([]byte, 3200)))
+	flac := write("c.flac", []byte("fLaC\x00\x00\x00\x22"))
+
+	samples, err := client.LoadSamples([]string{mp3, wav, flac})
+	require.NoError(t, err)
+	require.Len(t, samples, 3)
+	assert.Equal(t, "a.mp3", samples[0].Name)
+	assert.Equal(t, []string{"audio/mpeg", "audio/wav", "audio/flac"},
+		[]string{samples[0].ContentType, samples[1].ContentType, samples[2].ContentType})
+
+	_, err = client.LoadSamples([]string{mp3, write("notes.mp3", []byte("not audio at all"))})
+	assert.EqualError(t, err, filepath.Join(dir, "notes.mp3")+" is not mp3, WAV, FLAC, OGG or M4A audio")
+	random := make([]byte, 200<<10)
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range random {
+		random[i] = byte(r.Uint32())
+	}
+	for name, data := range map[string][]byte{"doc.pdf": append([]byte("%PDF-1.7\n"), random[:4096]...), "noise.mp3": random} {
+		_, err = client.LoadSamples([]string{write(name, data)})
+		assert.EqualError(t, err, filepath.Join(dir, name)+" is not mp3, WAV, FLAC, OGG or M4A audio")
+	}
+
+	_, err = client.LoadSamples([]string{write("big.wav", make([]byte, client.MaxSampleSize+1))})
+	assert.ErrorContains(t, err, "samples can be at most 10 MB")
+
+	_, err = client.LoadSamples(make([]string, client.MaxVoiceSamples+1))
+	assert.EqualError(t, err, "at most 25 samples can be uploaded, got 26")
+
+	_, err = client.LoadSamples([]string{filepath.Join(dir, "missing.mp3")})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestElevenLabs_ManageVoices(t *testing.T) {
+	t.Parallel()
+
+	s := fakeeleven.New(t, fakeeleven.WithAPIKey("123"))
+	c := client.New(&config.AppConfig{APIKey: "123", BaseURL: s.URL}, http.DefaultClient)
+	sample := client.SampleFile{Name: "a.mp3", ContentType: "audio/mpeg", Data: audio.Silence(time.Second, nil)}
+
+	id, err := c.AddVoice(client.VoiceEdit{Name: "Narrator", Labels: map[string]string{"accent": "british"},
+		Samples: []client.SampleFile{sample}, RemoveBackgroundNoise: true})
+	require.NoError(t, err)
+	requests := s.Requests()
+	_, params, err := mime.ParseMediaType(requests[0].Header.Get("Content-Type"))
+	require.NoError(t, err)
+	form, err := multipart.NewReader(bytes.NewReader(requests[0].Body), params["boundary"]).ReadForm(1 << 20)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Narrator"}, form.Value["name"])
+	assert.Equal(t, []string{`{"accent":"british"}`}, form.Value["labels"])
+	assert.Equal(t, []string{"true"}, form.Value["remove_background_noise"])
+	require.Len(t, form.File["files"], 1)
+	assert.Equal(t, "audio/mpeg", form.File["files"][0].Header.Get("Content-Type"))
+
+	sample.Name = `b "take 2" \.mp3`
+	require.NoError(t, c.EditVoice(id, client.VoiceEdit{Description: "Calm and warm", Samples: []client.SampleFile{sample}}))
+	v, err := c.GetVoice(id)
+	require.NoError(t, err)
+	assert.Equal(t, "Narrator", v.Name)
+	assert.Equal(t, "Calm and warm", v.Description)
+	assert.Equal(t, map[string]string{"accent": "british"}, v.Labels)
+	require.Len(t, v.Samples, 2)
+	assert.Equal(t, sample.Name, v.Samples[1].FileName, "quotes and backslashes in the filename are escaped")
+	require.NotNil(t, v.Settings)
+	assert.Equal(t, 0.5, v.Settings.Stability)
+
+	require.NoError(t, c.EditVoiceSettings(id, config.VoiceSettings{Stability: 0.3, SimilarityBoost: 0.9}))
+	assert.JSONEq(t, `{"stability":0.3,"similarity_boost":0.9,"style":0,"use_speaker_boost":false}`, string(s.Requests()[len(s.Requests())-1].Body))
+	settings, err := c.VoiceSettings(id)
+	require.NoError(t, err)
+	assert.Equal(t, config.VoiceSettings{Stability: 0.3, SimilarityBoost: 0.9, Speed: 1}, *settings)
+
+	require.NoError(t, c.DeleteVoice(id))
+	_, err = c.GetVoice(id)
+	assert.ErrorContains(t, err, http.StatusText(http.StatusNotFound))
+	assert.ErrorContains(t, c.DeleteVoice(id), "failed to delete voice")
+
+	_, err = c.AddVoice(client.VoiceEdit{Name: "Empty"})
+	assert.EqualError(t, err, "at least one sample is required")
+}
@@ -1,18 +1,46 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"unicode/utf8"
 )
 
+// document is the readable content of a web page
+type document struct {
+	Title    string
+	Text     string
+	Headings []heading
+}
+
+// heading is a section title and the rune offset in document.Text where it starts
+type heading struct {
+	Title  string
+	Offset int
+}
+
 // FromWebsite reads and parses text from a website
-func (c *ElevenLabs) FromWebsite(url string) ([]string, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (c *Pipeline) FromWebsite(url string) ([]string, error) {
+	doc, err := c.fetchDocument(c.context(), url)
+	if err != nil {
+		return nil, err
+	}
+	return batchText(c.context(), doc.Text, c.Config.CharacterRequestLimit), nil
+}
+
+// fetchDocument downloads a page and extracts its readable content
+func (c *Pipeline) fetchDocument(ctx context.Context, url string) (_ *document, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "fetch", attribute.String("url", url))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -23,7 +51,7 @@ func (c *ElevenLabs) FromWebsite(url string) ([]string, error) {
 	defer func(Body io.ReadCloser) {
 		closeErr := Body.Close()
 		if closeErr != nil {
-			log.Println("failed to close response body")
+			slog.Warn("error closing response body", "err", closeErr)
 		}
 	}(resp.Body)
 
@@ -31,37 +59,43 @@ func (c *ElevenLabs) FromWebsite(url string) ([]string, error) {
 		return nil, fmt.Errorf("failed to fetch website: %s", resp.Status)
 	}
 
-	text, err := extractTextFromHTML(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	return batchText(text, c.Config.CharacterRequestLimit), nil
+	return extractTextFromHTML(ctx, resp.Body)
 }
 
-// extractTextFromHTML extracts text from HTML document
-func extractTextFromHTML(r io.Reader) (string, error) {
+// extractTextFromHTML extracts the title, text and headings from an HTML document
+func extractTextFromHTML(ctx context.Context, r io.Reader) (_ *document, err error) {
+	_, span := telemetry.StartSpan(ctx, "extract")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	doc, err := goquery.NewDocumentFromReader(r)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse HTML: %w", err)
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
+	out := &document{Title: strings.TrimSpace(doc.Find("title").First().Text())}
 	var sb strings.Builder
 	// Select relevant tags and extract text
 	count := 0
 	doc.Find("title, h1, h2, h3, h4, h5, h6, p").Each(func(_ int, s *goquery.Selection) {
-		count += utf8.RuneCountInString(s.Text())
-		sb.WriteString(s.Text())
+		text := s.Text()
+		if s.Is("h1, h2, h3, h4, h5, h6") {
+			out.Headings = append(out.Headings, heading{Title: strings.TrimSpace(text), Offset: count})
+		}
+		count += utf8.RuneCountInString(text) + 1
+		sb.WriteString(text)
 		sb.WriteString("\n")
 	})
 
-	sb.Len()
-
-	return sb.String(), nil
+	out.Text = sb.String()
+	span.SetAttributes(attribute.Int("headings", len(out.Headings)), attribute.Int("characters", count))
+	return out, nil
 }
 
 // batchText splits the text into chunks of specified size
-func batchText(text string, size int) []string {
+func batchText(ctx context.Context, text string, size int) []string {
+	_, span := telemetry.StartSpan(ctx, "chunk", attribute.Int("size", size))
+	defer span.End()
+
 	var batches []string
 	runes := []rune(text)
 	for len(runes) > size {
@@ -70,5 +104,6 @@ func batchText(text string, size int) []string {
 		batches = append(batches, string(batch))
 	}
 	batches = append(batches, string(runes))
+	span.SetAttributes(attribute.Int("chunks", len(batches)))
 	return batches
 }
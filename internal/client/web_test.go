@@ -68,3 +68,21 @@ func TestWebReader(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizedText(t *testing.T) {
+	t.Parallel()
+
+	mockClient := mocks.NewHTTP(t)
+	mockClient.On("Do", mock.Anything).Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`<html><body><p>Only $5 at https://example.com/shop</p><h2>Offer ends 2024-03-21</h2></body></html>`)),
+	}, nil)
+	appConfig := &config.AppConfig{
+		CharacterRequestLimit: 100,
+		Language:              "en",
+		WebsiteURL:            "https://test.com",
+	}
+	text, err := client.New(appConfig, mockClient).NormalizedText()
+	assert.NoError(t, err)
+	assert.Equal(t, "Only 5 dollars at example dot com\nOffer ends March 21, 2024\n", text)
+}
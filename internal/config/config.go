@@ -1,12 +1,83 @@
 package config
 
-// AppConfig holds the application config - it should not import any other packages
+import "time"
+
+// AppConfig holds the application config - it should not import any other packages of this module, only standard library
+// value types such as time.Duration
 
 type AppConfig struct {
-	CharacterRequestLimit int
-	TextInput             string
-	OutputDir             string
-	APIKey                string
-	VoiceID               string
-	WebsiteURL            string
+	CharacterRequestLimit     int
+	TextInput                 string
+	OutputDir                 string
+	APIKey                    string
+	BaseURL                   string
+	VoiceID                   string
+	WebsiteURL                string
+	VoiceName                 string
+	JobName                   string
+	CoverArt                  string
+	Subtitles                 bool
+	VoiceSettings             VoiceSettings
+	ModelID                   string
+	PronunciationDictionaries []DictionaryLocator
+	Language                  string
+	Replacements              string
+	SpellAcronyms             bool
+	Provider                  string
+	ProviderURL               string
+	Command                   string
+	OutputFormat              string
+	// Profile is the name of the config file profile the settings came from
+	Profile string
+	// Tag attributes the usage of a run to a project in the usage ledger
+	Tag string
+	// Post is the post-processing applied to the finished audio
+	Post PostProcessing
+}
+
+// PostProcessing evens out the finished audio. The zero value leaves it as synthesized.
+type PostProcessing struct {
+	// TrimSilence removes silence from the start and end
+	TrimSilence bool
+	// Loudness is the integrated loudness to normalize to in LUFS, e.g. -16, or 0 to leave the level alone
+	Loudness float64
+	// ChunkPause is the silence between requests, ParagraphPause between paragraphs and HeadingPause either side of
+	// a heading
+	ChunkPause     time.Duration
+	ParagraphPause time.Duration
+	HeadingPause   time.Duration
+	// Intro and Outro are audio files played before and after the speech, and Bed one looped under it
+	Intro string
+	Outro string
+	Bed   string
+	// BedVolume is the bed's level in dB, Duck how much further it dips under speech and Fade how long it takes to
+	// fade in and out
+	BedVolume float64
+	Duck      float64
+	Fade      time.Duration
+}
+
+// Mastering reports whether the audio is trimmed, mixed or normalized, which means decoding it
+func (p PostProcessing) Mastering() bool {
+	return p.TrimSilence || p.Loudness != 0 || p.Mixing()
+}
+
+// Mixing reports whether music is mixed with the speech
+func (p PostProcessing) Mixing() bool {
+	return p.Intro != "" || p.Outro != "" || p.Bed != ""
+}
+
+// DictionaryLocator selects a pronunciation dictionary, and optionally a version of it, to apply to requests
+type DictionaryLocator struct {
+	ID        string
+	VersionID string
+}
+
+// VoiceSettings tune how a voice delivers text
+type VoiceSettings struct {
+	Stability       float64 `json:"stability" yaml:"stability" toml:"stability"`
+	SimilarityBoost float64 `json:"similarity_boost" yaml:"similarity_boost" toml:"similarity_boost"`
+	Style           float64 `json:"style,omitempty" yaml:"style,omitempty" toml:"style"`
+	UseSpeakerBoost bool    `json:"use_speaker_boost,omitempty" yaml:"use_speaker_boost,omitempty" toml:"use_speaker_boost"`
+	Speed           float64 `json:"speed,omitempty" yaml:"speed,omitempty" toml:"speed"`
 }
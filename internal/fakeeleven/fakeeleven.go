@@ -0,0 +1,745 @@
+// Package fakeeleven is an in-memory Eleven Labs API for end-to-end tests. It serves the text-to-speech,
+// streaming, speech-to-speech, sound generation, voices, user and history endpoints with deterministic silent audio,
+// records every request and can be told to fail or stall.
+package fakeeleven
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// CharDuration is how long the fake takes to speak each character
+const CharDuration = 50 * time.Millisecond
+
+// DefaultCharacterLimit is the subscription quota of a new server
+const DefaultCharacterLimit = 10000
+
+// Voice is a voice listed by the server
+type Voice struct {
+	VoiceID     string            `json:"voice_id"`
+	Name        string            `json:"name"`
+	Category    string            `json:"category"`
+	Description string            `json:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Samples     []Sample          `json:"samples,omitempty"`
+}
+
+// Sample is a recording uploaded to a cloned voice
+type Sample struct {
+	SampleID  string `json:"sample_id"`
+	FileName  string `json:"file_name"`
+	MimeType  string `json:"mime_type"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// VoiceSettings are the settings a voice speaks with by default
+type VoiceSettings struct {
+	Stability       float64 `json:"stability"`
+	SimilarityBoost float64 `json:"similarity_boost"`
+	Style           float64 `json:"style"`
+	UseSpeakerBoost bool    `json:"use_speaker_boost"`
+	Speed           float64 `json:"speed"`
+}
+
+// DefaultVoiceSettings are the settings of a voice until they are edited
+var DefaultVoiceSettings = VoiceSettings{Stability: 0.5, SimilarityBoost: 0.75, UseSpeakerBoost: true, Speed: 1}
+
+// Limits on the samples uploaded to a voice
+const (
+	MaxSamples    = 25
+	MaxSampleSize = 10 << 20
+)
+
+// DefaultVoices are the voices a new server knows
+var DefaultVoices = []Voice{
+	{VoiceID: "21m00Tcm4TlvDq8ikWAM", Name: "Rachel", Category: "premade"},
+	{VoiceID: "AZnzlk1XvdvUeBnXmlld", Name: "Domi", Category: "premade"},
+}
+
+// Request is a request received by the server
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Fault makes matching requests fail or respond slowly
+type Fault struct {
+	// Path is a prefix of the paths affected, empty for every path
+	Path string
+	// Status is sent instead of the normal response, or 0 to respond normally after Delay
+	Status int
+	// Delay is how long to wait before sending headers
+	Delay time.Duration
+	// Times is the number of requests affected, 0 for every request until the faults are cleared
+	Times int
+}
+
+// HistoryItem is a past synthesis
+type HistoryItem struct {
+	HistoryItemID            string `json:"history_item_id"`
+	RequestID                string `json:"request_id"`
+	VoiceID                  string `json:"voice_id"`
+	VoiceName                string `json:"voice_name"`
+	ModelID                  string `json:"model_id"`
+	Text                     string `json:"text"`
+	DateUnix                 int64  `json:"date_unix"`
+	CharacterCountChangeFrom int    `json:"character_count_change_from"`
+	CharacterCountChangeTo   int    `json:"character_count_change_to"`
+	ContentType              string `json:"content_type"`
+	State                    string `json:"state"`
+	audio                    []byte
+}
+
+// Server is a running fake Eleven Labs API. Point a client's base URL at Server.URL.
+type Server struct {
+	*httptest.Server
+	apiKey         string
+	characterLimit int
+
+	mu             sync.Mutex
+	voices         []Voice
+	settings       map[string]VoiceSettings
+	clones         int
+	requests       []Request
+	faults         []*Fault
+	history        []*HistoryItem
+	characterCount int
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithAPIKey makes the server reject requests without this xi-api-key
+func WithAPIKey(key string) Option {
+	return func(s *Server) { s.apiKey = key }
+}
+
+// WithVoices replaces the default voices
+func WithVoices(voices ...Voice) Option {
+	return func(s *Server) { s.voices = voices }
+}
+
+// WithCharacterLimit sets the subscription quota, after which synthesis fails with quota_exceeded
+func WithCharacterLimit(limit int) Option {
+	return func(s *Server) { s.characterLimit = limit }
+}
+
+// New starts a server that is closed when the test finishes
+func New(t testing.TB, opts ...Option) *Server {
+	s := &Server{
+		voices:         DefaultVoices,
+		characterLimit: DefaultCharacterLimit,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.voices = slices.Clone(s.voices)
+	s.settings = map[string]VoiceSettings{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/text-to-speech/{voice_id}", s.textToSpeech)
+	mux.HandleFunc("POST /v1/text-to-speech/{voice_id}/stream", s.stream)
+	mux.HandleFunc("POST /v1/text-to-speech/{voice_id}/with-timestamps", s.withTimestamps)
+	mux.HandleFunc("POST /v1/speech-to-speech/{voice_id}", s.speechToSpeech)
+	mux.HandleFunc("POST /v1/sound-generation", s.soundGeneration)
+	mux.HandleFunc("GET /v1/voices", s.listVoices)
+	mux.HandleFunc("POST /v1/voices/add", s.addVoice)
+	mux.HandleFunc("GET /v1/voices/{voice_id}", s.getVoice)
+	mux.HandleFunc("POST /v1/voices/{voice_id}/edit", s.editVoice)
+	mux.HandleFunc("DELETE /v1/voices/{voice_id}", s.deleteVoice)
+	mux.HandleFunc("GET /v1/voices/{voice_id}/settings", s.getVoiceSettings)
+	mux.HandleFunc("POST /v1/voices/{voice_id}/settings/edit", s.editVoiceSettings)
+	mux.HandleFunc("GET /v1/user", s.user)
+	mux.HandleFunc("GET /v1/user/subscription", s.subscription)
+	mux.HandleFunc("GET /v1/history", s.listHistory)
+	mux.HandleFunc("GET /v1/history/{id}", s.getHistory)
+	mux.HandleFunc("GET /v1/history/{id}/audio", s.historyAudio)
+
+	s.Server = httptest.NewServer(s.middleware(mux))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Audio returns the audio the server speaks for text: CharDuration of silent mp3 per character
+func Audio(text string) []byte {
+	return audio.Silence(time.Duration(utf8.RuneCountInString(text))*CharDuration, nil)
+}
+
+// Requests returns every request received so far, in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Inject adds a fault. Faults are checked in the order they were added.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes every fault
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// CharacterCount returns the characters billed so far
+func (s *Server) CharacterCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.characterCount
+}
+
+// middleware records the request, then applies authentication and any fault
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_body", err.Error())
+			return
+		}
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		fault := s.fault(r.URL.Path)
+		s.mu.Unlock()
+
+		if fault != nil && fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault != nil && fault.Status != 0 {
+			writeError(w, fault.Status, statusName(fault.Status), "injected fault")
+			return
+		}
+		if s.apiKey != "" && r.Header.Get("xi-api-key") != s.apiKey {
+			writeError(w, http.StatusUnauthorized, "invalid_api_key", "Invalid API key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fault returns the first fault matching path, using up one of its times. The caller must hold mu.
+func (s *Server) fault(path string) *Fault {
+	for i, f := range s.faults {
+		if !strings.HasPrefix(path, f.Path) {
+			continue
+		}
+		matched := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return &matched
+	}
+	return nil
+}
+
+func statusName(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return "invalid_api_key"
+	case http.StatusTooManyRequests:
+		return "too_many_concurrent_requests"
+	default:
+		return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, name, message string) {
+	writeJSON(w, status, map[string]any{"detail": map[string]string{"status": name, "message": message}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+type ttsRequest struct {
+	Text    string `json:"text"`
+	ModelID string `json:"model_id"`
+}
+
+// synthesize validates a text-to-speech request and bills it, writing an error response and returning nil on failure
+func (s *Server) synthesize(w http.ResponseWriter, r *http.Request) *HistoryItem {
+	var req ttsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return nil
+	}
+	if req.Text == "" {
+		writeError(w, http.StatusBadRequest, "empty_text", "text is required")
+		return nil
+	}
+	voice, ok := s.voice(r.PathValue("voice_id"))
+	if !ok {
+		voiceNotFound(w, r)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := utf8.RuneCountInString(req.Text)
+	if s.characterCount+count > s.characterLimit {
+		writeError(w, http.StatusUnauthorized, "quota_exceeded",
+			fmt.Sprintf("This request exceeds your quota. You have %d credits remaining, while %d credits are required for this request.", s.characterLimit-s.characterCount, count))
+		return nil
+	}
+	item := &HistoryItem{
+		HistoryItemID:            fmt.Sprintf("history%04d", len(s.history)+1),
+		RequestID:                fmt.Sprintf("request%04d", len(s.history)+1),
+		VoiceID:                  voice.VoiceID,
+		VoiceName:                voice.Name,
+		ModelID:                  req.ModelID,
+		Text:                     req.Text,
+		DateUnix:                 time.Now().Unix(),
+		CharacterCountChangeFrom: s.characterCount,
+		CharacterCountChangeTo:   s.characterCount + count,
+		ContentType:              "audio/mpeg",
+		State:                    "created",
+		audio:                    Audio(req.Text),
+	}
+	s.characterCount += count
+	s.history = append(s.history, item)
+
+	w.Header().Set("request-id", item.RequestID)
+	w.Header().Set("x-character-count", strconv.Itoa(count))
+	return item
+}
+
+func (s *Server) voice(id string) (Voice, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.voiceIndex(id); i >= 0 {
+		return s.voices[i], true
+	}
+	return Voice{}, false
+}
+
+// voiceIndex returns the position of a voice in s.voices, or -1. s.mu must be held.
+func (s *Server) voiceIndex(id string) int {
+	return slices.IndexFunc(s.voices, func(v Voice) bool { return v.VoiceID == id })
+}
+
+func (s *Server) textToSpeech(w http.ResponseWriter, r *http.Request) {
+	item := s.synthesize(w, r)
+	if item == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "audio/mpeg")
+	_, _ = w.Write(item.audio)
+}
+
+// stream sends the audio in small flushed chunks
+func (s *Server) stream(w http.ResponseWriter, r *http.Request) {
+	item := s.synthesize(w, r)
+	if item == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "audio/mpeg")
+	flusher, _ := w.(http.Flusher)
+	const chunk = 1024
+	for data := item.audio; len(data) > 0; {
+		n := min(chunk, len(data))
+		if _, err := w.Write(data[:n]); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		data = data[n:]
+	}
+}
+
+// withTimestamps returns the audio as base64 with each character spoken for CharDuration
+func (s *Server) withTimestamps(w http.ResponseWriter, r *http.Request) {
+	item := s.synthesize(w, r)
+	if item == nil {
+		return
+	}
+	var chars []string
+	var starts, ends []float64
+	for i, ch := range []rune(item.Text) {
+		chars = append(chars, string(ch))
+		starts = append(starts, (time.Duration(i) * CharDuration).Seconds())
+		ends = append(ends, (time.Duration(i+1) * CharDuration).Seconds())
+	}
+	alignment := map[string]any{
+		"characters":                    chars,
+		"character_start_times_seconds": starts,
+		"character_end_times_seconds":   ends,
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"audio_base64":         base64.StdEncoding.EncodeToString(item.audio),
+		"alignment":            alignment,
+		"normalized_alignment": alignment,
+	})
+}
+
+// STSCreditsPerMinute is what the fake bills for each minute of recording revoiced
+const STSCreditsPerMinute = 1000
+
+// speechToSpeech answers an uploaded recording with as long a silent mp3
+func (s *Server) speechToSpeech(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("audio")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_audio", "audio file is required")
+		return
+	}
+	recording, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_audio", err.Error())
+		return
+	}
+	var d time.Duration
+	if f, pcm, wErr := audio.ParseWAV(recording); wErr == nil {
+		d = f.Duration(len(pcm))
+	} else {
+		d = audio.Duration(recording)
+	}
+	if d == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_audio", "audio file could not be decoded")
+		return
+	}
+	if _, ok := s.voice(r.PathValue("voice_id")); !ok {
+		voiceNotFound(w, r)
+		return
+	}
+
+	if !s.bill(w, max(1, int(d.Minutes()*STSCreditsPerMinute))) {
+		return
+	}
+	w.Header().Set("Content-Type", "audio/mpeg")
+	_, _ = w.Write(audio.Silence(d, nil))
+}
+
+// bill adds count to the characters used and sets the x-character-count header, or writes a quota error and returns
+// false when it would exceed the limit
+func (s *Server) bill(w http.ResponseWriter, count int) bool {
+	s.mu.Lock()
+	if s.characterCount+count > s.characterLimit {
+		s.mu.Unlock()
+		writeError(w, http.StatusUnauthorized, "quota_exceeded", "This request exceeds your quota.")
+		return false
+	}
+	s.characterCount += count
+	s.mu.Unlock()
+	w.Header().Set("x-character-count", strconv.Itoa(count))
+	return true
+}
+
+// SoundCreditsPerSecond is what the fake bills for each second of sound effect generated
+const SoundCreditsPerSecond = 40
+
+// DefaultSoundDuration is how long the fake's sound effects are when the request doesn't say
+const DefaultSoundDuration = 5 * time.Second
+
+type soundRequest struct {
+	Text            string   `json:"text"`
+	DurationSeconds *float64 `json:"duration_seconds"`
+	PromptInfluence *float64 `json:"prompt_influence"`
+}
+
+// soundGeneration answers a sound effect prompt with silent mp3 of the requested duration
+func (s *Server) soundGeneration(w http.ResponseWriter, r *http.Request) {
+	var req soundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_request", err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_request", "text is required")
+		return
+	}
+	d := DefaultSoundDuration
+	if req.DurationSeconds != nil {
+		if *req.DurationSeconds < 0.5 || *req.DurationSeconds > 22 {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_request", "duration_seconds must be between 0.5 and 22")
+			return
+		}
+		d = time.Duration(*req.DurationSeconds * float64(time.Second))
+	}
+	if req.PromptInfluence != nil && (*req.PromptInfluence < 0 || *req.PromptInfluence > 1) {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_request", "prompt_influence must be between 0 and 1")
+		return
+	}
+	if !s.bill(w, int(d.Seconds()*SoundCreditsPerSecond)) {
+		return
+	}
+	w.Header().Set("Content-Type", "audio/mpeg")
+	_, _ = w.Write(audio.Silence(d, nil))
+}
+
+func (s *Server) listVoices(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	voices := slices.Clone(s.voices)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"voices": voices})
+}
+
+func (s *Server) getVoice(w http.ResponseWriter, r *http.Request) {
+	voice, ok := s.voice(r.PathValue("voice_id"))
+	if !ok {
+		voiceNotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Voice
+		Settings VoiceSettings `json:"settings"`
+	}{voice, s.voiceSettings(voice.VoiceID)})
+}
+
+func voiceNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, "voice_not_found", fmt.Sprintf("A voice with voice_id %s was not found.", r.PathValue("voice_id")))
+}
+
+// voiceForm reads the fields and samples of a voice being added or edited, writing an error response and returning
+// false when they are invalid
+func voiceForm(w http.ResponseWriter, r *http.Request, requireSamples bool) (Voice, bool) {
+	if err := r.ParseMultipartForm(MaxSamples * MaxSampleSize); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return Voice{}, false
+	}
+	v := Voice{Name: r.FormValue("name"), Description: r.FormValue("description")}
+	if v.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "name is required")
+		return Voice{}, false
+	}
+	if labels := r.FormValue("labels"); labels != "" {
+		if err := json.Unmarshal([]byte(labels), &v.Labels); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "labels must be a JSON object of strings")
+			return Voice{}, false
+		}
+	}
+	files := r.MultipartForm.File["files"]
+	switch {
+	case requireSamples && len(files) == 0:
+		writeError(w, http.StatusBadRequest, "invalid_request", "at least one sample is required")
+		return Voice{}, false
+	case len(files) > MaxSamples:
+		writeError(w, http.StatusBadRequest, "too_many_samples", fmt.Sprintf("at most %d samples can be uploaded", MaxSamples))
+		return Voice{}, false
+	}
+	for _, f := range files {
+		if f.Size > MaxSampleSize {
+			writeError(w, http.StatusBadRequest, "sample_too_large", f.Filename+" is too large")
+			return Voice{}, false
+		}
+		v.Samples = append(v.Samples, Sample{FileName: f.Filename, MimeType: f.Header.Get("Content-Type"), SizeBytes: f.Size})
+	}
+	return v, true
+}
+
+// addSamples numbers new samples and appends them to a voice's. s.mu must be held.
+func (s *Server) addSamples(v *Voice, samples []Sample) {
+	for _, sample := range samples {
+		sample.SampleID = fmt.Sprintf("sample%02d", len(v.Samples)+1)
+		v.Samples = append(v.Samples, sample)
+	}
+}
+
+// addVoice clones a voice from uploaded samples
+func (s *Server) addVoice(w http.ResponseWriter, r *http.Request) {
+	form, ok := voiceForm(w, r, true)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	s.clones++
+	v := Voice{VoiceID: fmt.Sprintf("cloned%04d", s.clones), Name: form.Name, Category: "cloned", Description: form.Description,
+		Labels: form.Labels}
+	s.addSamples(&v, form.Samples)
+	s.voices = append(s.voices, v)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"voice_id": v.VoiceID, "requires_verification": false})
+}
+
+// editVoice renames a voice, replaces its description and labels when given and appends any samples uploaded
+func (s *Server) editVoice(w http.ResponseWriter, r *http.Request) {
+	form, ok := voiceForm(w, r, false)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.voiceIndex(r.PathValue("voice_id"))
+	if i < 0 {
+		voiceNotFound(w, r)
+		return
+	}
+	v := &s.voices[i]
+	v.Name = form.Name
+	if form.Description != "" {
+		v.Description = form.Description
+	}
+	if form.Labels != nil {
+		v.Labels = form.Labels
+	}
+	s.addSamples(v, form.Samples)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) deleteVoice(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.voiceIndex(r.PathValue("voice_id"))
+	if i < 0 {
+		voiceNotFound(w, r)
+		return
+	}
+	s.voices = slices.Delete(s.voices, i, i+1)
+	delete(s.settings, r.PathValue("voice_id"))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// voiceSettings returns a voice's settings, DefaultVoiceSettings until they are edited
+func (s *Server) voiceSettings(id string) VoiceSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if settings, ok := s.settings[id]; ok {
+		return settings
+	}
+	return DefaultVoiceSettings
+}
+
+func (s *Server) getVoiceSettings(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.voice(r.PathValue("voice_id")); !ok {
+		voiceNotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.voiceSettings(r.PathValue("voice_id")))
+}
+
+// editVoiceSettings replaces a voice's settings, keeping the current speed when none is given
+func (s *Server) editVoiceSettings(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("voice_id")
+	if _, ok := s.voice(id); !ok {
+		voiceNotFound(w, r)
+		return
+	}
+	var settings VoiceSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	if settings.Speed == 0 {
+		settings.Speed = s.voiceSettings(id).Speed
+	}
+	s.mu.Lock()
+	s.settings[id] = settings
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) subscriptionInfo() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]any{
+		"tier":            "free",
+		"status":          "active",
+		"character_count": s.characterCount,
+		"character_limit": s.characterLimit,
+	}
+}
+
+func (s *Server) user(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"user_id":      "fakeeleven",
+		"first_name":   "Fake",
+		"subscription": s.subscriptionInfo(),
+	})
+}
+
+func (s *Server) subscription(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.subscriptionInfo())
+}
+
+// listHistory pages through the history newest first, honouring page_size and start_after_history_item_id
+func (s *Server) listHistory(w http.ResponseWriter, r *http.Request) {
+	pageSize := 100
+	if v := r.URL.Query().Get("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_page_size", "page_size must be a positive integer")
+			return
+		}
+		pageSize = n
+	}
+	after := r.URL.Query().Get("start_after_history_item_id")
+
+	s.mu.Lock()
+	var items []*HistoryItem
+	started := after == ""
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if started {
+			items = append(items, s.history[i])
+		} else if s.history[i].HistoryItemID == after {
+			started = true
+		}
+	}
+	s.mu.Unlock()
+
+	hasMore := len(items) > pageSize
+	if hasMore {
+		items = items[:pageSize]
+	}
+	last := ""
+	if len(items) > 0 {
+		last = items[len(items)-1].HistoryItemID
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"history":              items,
+		"has_more":             hasMore,
+		"last_history_item_id": last,
+	})
+}
+
+func (s *Server) historyItem(w http.ResponseWriter, r *http.Request) *HistoryItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range s.history {
+		if item.HistoryItemID == r.PathValue("id") {
+			return item
+		}
+	}
+	writeError(w, http.StatusNotFound, "history_item_not_found", "History item not found")
+	return nil
+}
+
+func (s *Server) getHistory(w http.ResponseWriter, r *http.Request) {
+	if item := s.historyItem(w, r); item != nil {
+		writeJSON(w, http.StatusOK, item)
+	}
+}
+
+func (s *Server) historyAudio(w http.ResponseWriter, r *http.Request) {
+	if item := s.historyItem(w, r); item != nil {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		_, _ = w.Write(item.audio)
+	}
+}
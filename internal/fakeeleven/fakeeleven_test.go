@@ -0,0 +1,212 @@
+package fakeeleven_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func post(t *testing.T, url, key, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("xi-api-key", key)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = res.Body.Close() })
+	return res
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("speaks text deterministically and records the request", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t, fakeeleven.WithAPIKey("123"))
+		res := post(t, s.URL+"/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM", "123", `{"text":"hello"}`)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, fakeeleven.Audio("hello"), data)
+		assert.Equal(t, "5", res.Header.Get("x-character-count"))
+		assert.Equal(t, 5, s.CharacterCount())
+
+		requests := s.Requests()
+		require.Len(t, requests, 1)
+		assert.Equal(t, "/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM", requests[0].Path)
+		assert.JSONEq(t, `{"text":"hello"}`, string(requests[0].Body))
+	})
+
+	t.Run("streams and times characters", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		res := post(t, s.URL+"/v1/text-to-speech/AZnzlk1XvdvUeBnXmlld/stream", "", `{"text":"hi"}`)
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, fakeeleven.Audio("hi"), data)
+
+		res = post(t, s.URL+"/v1/text-to-speech/AZnzlk1XvdvUeBnXmlld/with-timestamps", "", `{"text":"hi"}`)
+		var body struct {
+			Alignment struct {
+				Characters []string  `json:"characters"`
+				Ends       []float64 `json:"character_end_times_seconds"`
+			} `json:"alignment"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+		assert.Equal(t, []string{"h", "i"}, body.Alignment.Characters)
+		assert.Equal(t, []float64{0.05, 0.1}, body.Alignment.Ends)
+	})
+
+	t.Run("revoices an uploaded recording", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		recording := audio.Silence(3*time.Second, nil)
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, err := w.CreateFormFile("audio", "memo.mp3")
+		require.NoError(t, err)
+		_, err = part.Write(recording)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		res, err := http.Post(s.URL+"/v1/speech-to-speech/21m00Tcm4TlvDq8ikWAM", w.FormDataContentType(), &body)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = res.Body.Close() })
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, audio.Duration(recording), audio.Duration(data))
+		assert.Equal(t, 50, s.CharacterCount())
+
+		assert.Equal(t, http.StatusBadRequest, post(t, s.URL+"/v1/speech-to-speech/21m00Tcm4TlvDq8ikWAM", "", "").StatusCode)
+	})
+
+	t.Run("generates sound effects of the requested duration", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		res := post(t, s.URL+"/v1/sound-generation", "", `{"text":"door creaks open","duration_seconds":1.5}`)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, 1500*time.Millisecond, audio.Duration(data).Round(100*time.Millisecond))
+		assert.Equal(t, "60", res.Header.Get("x-character-count"))
+
+		assert.Equal(t, http.StatusOK, post(t, s.URL+"/v1/sound-generation", "", `{"text":"rain"}`).StatusCode)
+		assert.Equal(t, 60+fakeeleven.SoundCreditsPerSecond*5, s.CharacterCount())
+		assert.Equal(t, http.StatusUnprocessableEntity, post(t, s.URL+"/v1/sound-generation", "", `{"text":"rain","duration_seconds":30}`).StatusCode)
+	})
+
+	t.Run("clones voices from uploaded samples", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		upload := func(samples int) *http.Response {
+			var body bytes.Buffer
+			w := multipart.NewWriter(&body)
+			require.NoError(t, w.WriteField("name", "Narrator"))
+			for i := 0; i < samples; i++ {
+				part, err := w.CreateFormFile("files", "sample.mp3")
+				require.NoError(t, err)
+				_, err = part.Write(audio.Silence(time.Second, nil))
+				require.NoError(t, err)
+			}
+			require.NoError(t, w.Close())
+			res, err := http.Post(s.URL+"/v1/voices/add", w.FormDataContentType(), &body)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = res.Body.Close() })
+			return res
+		}
+
+		res := upload(2)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var added struct {
+			VoiceID string `json:"voice_id"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&added))
+		res, err := http.Get(s.URL + "/v1/voices")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = res.Body.Close() })
+		var list struct {
+			Voices []fakeeleven.Voice `json:"voices"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&list))
+		require.Len(t, list.Voices, len(fakeeleven.DefaultVoices)+1)
+		assert.Equal(t, added.VoiceID, list.Voices[2].VoiceID)
+		assert.Len(t, list.Voices[2].Samples, 2)
+
+		assert.Equal(t, http.StatusBadRequest, upload(0).StatusCode)
+		assert.Equal(t, http.StatusBadRequest, upload(fakeeleven.MaxSamples+1).StatusCode)
+		assert.Len(t, fakeeleven.DefaultVoices, 2)
+	})
+
+	t.Run("rejects bad keys, unknown voices and exhausted quotas", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t, fakeeleven.WithAPIKey("123"), fakeeleven.WithCharacterLimit(3))
+		assert.Equal(t, http.StatusUnauthorized, post(t, s.URL+"/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM", "456", `{"text":"hi"}`).StatusCode)
+		assert.Equal(t, http.StatusNotFound, post(t, s.URL+"/v1/text-to-speech/nobody", "123", `{"text":"hi"}`).StatusCode)
+		res := post(t, s.URL+"/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM", "123", `{"text":"hello"}`)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "quota_exceeded")
+	})
+
+	t.Run("injects faults", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		s.Inject(fakeeleven.Fault{Path: "/v1/text-to-speech", Status: http.StatusTooManyRequests, Times: 1})
+		s.Inject(fakeeleven.Fault{Path: "/v1/user", Delay: 50 * time.Millisecond})
+
+		url := s.URL + "/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM"
+		assert.Equal(t, http.StatusTooManyRequests, post(t, url, "", `{"text":"hi"}`).StatusCode)
+		assert.Equal(t, http.StatusOK, post(t, url, "", `{"text":"hi"}`).StatusCode)
+
+		start := time.Now()
+		res, err := http.Get(s.URL + "/v1/user")
+		require.NoError(t, err)
+		defer func() { _ = res.Body.Close() }()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+		s.ClearFaults()
+		s.Inject(fakeeleven.Fault{Status: http.StatusServiceUnavailable})
+		assert.Equal(t, http.StatusServiceUnavailable, post(t, url, "", `{"text":"hi"}`).StatusCode)
+		assert.Equal(t, http.StatusServiceUnavailable, post(t, url, "", `{"text":"hi"}`).StatusCode)
+	})
+
+	t.Run("pages through history newest first", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		for _, text := range []string{"one", "two", "three"} {
+			post(t, s.URL+"/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM", "", `{"text":"`+text+`"}`)
+		}
+		var page struct {
+			History []fakeeleven.HistoryItem `json:"history"`
+			HasMore bool                     `json:"has_more"`
+			Last    string                   `json:"last_history_item_id"`
+		}
+		res, err := http.Get(s.URL + "/v1/history?page_size=2")
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&page))
+		_ = res.Body.Close()
+		require.Len(t, page.History, 2)
+		assert.Equal(t, "three", page.History[0].Text)
+		assert.True(t, page.HasMore)
+
+		res, err = http.Get(s.URL + "/v1/history?page_size=2&start_after_history_item_id=" + page.Last)
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&page))
+		_ = res.Body.Close()
+		require.Len(t, page.History, 1)
+		assert.Equal(t, "one", page.History[0].Text)
+		assert.False(t, page.HasMore)
+	})
+}
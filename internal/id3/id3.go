@@ -0,0 +1,155 @@
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf16"
+)
+
+// Tag holds the metadata written in front of generated mp3 files
+type Tag struct {
+	Title     string
+	Artist    string
+	Album     string
+	Comment   string
+	Cover     []byte
+	CoverMIME string
+	Chapters  []Chapter
+}
+
+// Chapter marks a section of the audio so players can jump between them
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+// Bytes encodes the tag as an ID3v2.3 header, including CHAP/CTOC frames when chapters are set
+func (t *Tag) Bytes() ([]byte, error) {
+	var frames bytes.Buffer
+	for _, f := range []struct{ id, text string }{
+		{"TIT2", t.Title},
+		{"TPE1", t.Artist},
+		{"TALB", t.Album},
+	} {
+		if f.text != "" {
+			writeFrame(&frames, f.id, textFrame(f.text))
+		}
+	}
+	if t.Comment != "" {
+		writeFrame(&frames, "COMM", commentFrame(t.Comment))
+	}
+	if len(t.Cover) > 0 {
+		mime := t.CoverMIME
+		if mime == "" {
+			mime = http.DetectContentType(t.Cover)
+		}
+		writeFrame(&frames, "APIC", pictureFrame(mime, t.Cover))
+	}
+	if len(t.Chapters) > 0 {
+		if len(t.Chapters) > 255 {
+			return nil, errors.New("too many chapters, limit is 255")
+		}
+		writeFrame(&frames, "CTOC", tocFrame(len(t.Chapters)))
+		for i, ch := range t.Chapters {
+			if ch.End < ch.Start {
+				return nil, fmt.Errorf("chapter %q ends before it starts", ch.Title)
+			}
+			writeFrame(&frames, "CHAP", chapterFrame(i, ch))
+		}
+	}
+
+	size := frames.Len()
+	if size >= 1<<28 {
+		return nil, errors.New("tag too large")
+	}
+	header := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+	return append(header, frames.Bytes()...), nil
+}
+
+func writeFrame(buf *bytes.Buffer, id string, body []byte) {
+	buf.WriteString(id)
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(body)))
+	buf.Write([]byte{0, 0}) // flags
+	buf.Write(body)
+}
+
+// encodeText returns the ID3 encoding byte and the encoded, null terminated string.
+// Latin-1 is used when possible, falling back to UTF-16 with a byte order mark.
+func encodeText(s string) (byte, []byte) {
+	latin := true
+	for _, r := range s {
+		if r > 0xFF {
+			latin = false
+			break
+		}
+	}
+	if latin {
+		b := make([]byte, 0, len(s)+1)
+		for _, r := range s {
+			b = append(b, byte(r))
+		}
+		return 0, append(b, 0)
+	}
+	b := []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return 1, append(b, 0, 0)
+}
+
+func textFrame(s string) []byte {
+	enc, text := encodeText(s)
+	return append([]byte{enc}, text...)
+}
+
+func commentFrame(s string) []byte {
+	enc, text := encodeText(s)
+	body := []byte{enc, 'e', 'n', 'g'}
+	if enc == 0 {
+		body = append(body, 0) // empty description
+	} else {
+		body = append(body, 0xFF, 0xFE, 0, 0)
+	}
+	return append(body, text...)
+}
+
+func pictureFrame(mime string, data []byte) []byte {
+	body := []byte{0}
+	body = append(body, mime...)
+	body = append(body, 0, 3, 0) // front cover, empty description
+	return append(body, data...)
+}
+
+func chapterID(i int) string {
+	return fmt.Sprintf("chp%d", i)
+}
+
+func tocFrame(count int) []byte {
+	body := []byte("toc\x00")
+	body = append(body, 0x03, byte(count)) // top level, ordered
+	for i := 0; i < count; i++ {
+		body = append(body, chapterID(i)...)
+		body = append(body, 0)
+	}
+	return body
+}
+
+func chapterFrame(i int, ch Chapter) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(chapterID(i))
+	buf.WriteByte(0)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(ch.Start.Milliseconds()))
+	_ = binary.Write(&buf, binary.BigEndian, uint32(ch.End.Milliseconds()))
+	_ = binary.Write(&buf, binary.BigEndian, uint32(0xFFFFFFFF)) // byte offsets unused
+	_ = binary.Write(&buf, binary.BigEndian, uint32(0xFFFFFFFF))
+	if ch.Title != "" {
+		writeFrame(&buf, "TIT2", textFrame(ch.Title))
+	}
+	return buf.Bytes()
+}
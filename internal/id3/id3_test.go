@@ -0,0 +1,65 @@
+package id3_test
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/id3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTag_Bytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		tag      id3.Tag
+		contains [][]byte
+		error    string
+	}{
+		{
+			name:     "writes latin-1 text frames",
+			tag:      id3.Tag{Title: "Hello", Artist: "voice", Album: "example.com"},
+			contains: [][]byte{[]byte("TIT2\x00\x00\x00\x07\x00\x00\x00Hello\x00"), []byte("TPE1"), []byte("TALB")},
+		},
+		{
+			name:     "falls back to utf-16 for other scripts",
+			tag:      id3.Tag{Title: "日本"},
+			contains: [][]byte{{'T', 'I', 'T', '2', 0, 0, 0, 0x09, 0, 0, 1, 0xFF, 0xFE, 0xE5, 0x65, 0x2C, 0x67, 0, 0}},
+		},
+		{
+			name: "writes a table of contents and chapters",
+			tag: id3.Tag{Chapters: []id3.Chapter{
+				{Title: "Intro", Start: 0, End: time.Second},
+				{Title: "Body", Start: time.Second, End: 2 * time.Second},
+			}},
+			contains: [][]byte{
+				[]byte("toc\x00\x03\x02chp0\x00chp1\x00"),
+				[]byte("chp1\x00\x00\x00\x03\xe8\x00\x00\x07\xd0\xff\xff\xff\xff\xff\xff\xff\xffTIT2"),
+			},
+		},
+		{
+			name:  "rejects chapters that end before they start",
+			tag:   id3.Tag{Chapters: []id3.Chapter{{Title: "Broken", Start: time.Second}}},
+			error: `chapter "Broken" ends before it starts`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := tt.tag.Bytes()
+			if tt.error != "" {
+				assert.EqualError(t, err, tt.error)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, bytes.HasPrefix(b, []byte{'I', 'D', '3', 3, 0, 0}))
+			size := int(b[6])<<21 | int(b[7])<<14 | int(b[8])<<7 | int(b[9])
+			assert.Equal(t, len(b)-10, size)
+			for _, want := range tt.contains {
+				assert.True(t, bytes.Contains(b, want), "expected tag to contain %q", want)
+			}
+		})
+	}
+}
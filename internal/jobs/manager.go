@@ -0,0 +1,274 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is how many jobs run at once when Options leaves it unset
+const DefaultWorkers = 2
+
+// PipelineFunc returns the pipeline that voices a job
+type PipelineFunc func(Spec) (*client.Pipeline, error)
+
+// Options tune a Manager
+type Options struct {
+	// Workers is how many jobs are synthesized at once
+	Workers int
+	// WebhookSecret signs webhook deliveries. Jobs can only name a webhook when it is set.
+	WebhookSecret string
+	// WebhookClient delivers webhooks, a client with a 10 second timeout when nil
+	WebhookClient client.HTTP
+	// WebhookAttempts is how many times a delivery is tried, 3 when unset
+	WebhookAttempts int
+	// WebhookBackoff is the wait before the first retry, doubling after each, a second when unset
+	WebhookBackoff time.Duration
+}
+
+// Manager runs queued jobs on a pool of workers
+type Manager struct {
+	store    *Store
+	pipeline PipelineFunc
+	opts     Options
+	wake     chan struct{}
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+
+	deliveries sync.WaitGroup
+}
+
+// NewManager returns a manager for the jobs in store, voiced by the pipelines from pipeline
+func NewManager(store *Store, pipeline PipelineFunc, opts Options) *Manager {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.WebhookClient == nil {
+		opts.WebhookClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.WebhookAttempts <= 0 {
+		opts.WebhookAttempts = 3
+	}
+	if opts.WebhookBackoff <= 0 {
+		opts.WebhookBackoff = time.Second
+	}
+	return &Manager{
+		store:    store,
+		pipeline: pipeline,
+		opts:     opts,
+		wake:     make(chan struct{}, 1),
+		cancels:  map[string]context.CancelFunc{},
+	}
+}
+
+// Store returns the manager's job store
+func (m *Manager) Store() *Store {
+	return m.store
+}
+
+// Run resumes jobs interrupted by a previous shutdown and works through the queue until ctx is cancelled.
+// Running jobs stop after their current chunk and are queued again, to resume on the next Run.
+func (m *Manager) Run(ctx context.Context) error {
+	if err := m.store.Requeue(""); err != nil {
+		return fmt.Errorf("failed to resume jobs: %w", err)
+	}
+	var workers sync.WaitGroup
+	for range m.opts.Workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			m.work(ctx)
+		}()
+	}
+	workers.Wait()
+	m.deliveries.Wait()
+	return nil
+}
+
+// Submit queues a job
+func (m *Manager) Submit(spec Spec, webhookURL string) (*Job, error) {
+	if webhookURL != "" && m.opts.WebhookSecret == "" {
+		return nil, errors.New("webhooks are disabled: the server has no webhook secret")
+	}
+	job, err := m.store.Create(spec, webhookURL)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Cancel stops a job. A running job stops after its current chunk.
+func (m *Manager) Cancel(id string) (*Job, error) {
+	job, err := m.store.Cancel(id)
+	if err != nil {
+		return job, err
+	}
+	m.mu.Lock()
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+	}
+	m.mu.Unlock()
+	return job, nil
+}
+
+func (m *Manager) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := m.store.Claim()
+		if err != nil {
+			slog.Error("failed to claim a job", "err", err)
+		}
+		if job == nil {
+			select {
+			case <-m.wake:
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		// pass the wake up on, as more jobs may be waiting
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+		m.process(ctx, job)
+	}
+}
+
+// process plans the job if needed, synthesizes the chunks not yet done and assembles the artifacts
+func (m *Manager) process(ctx context.Context, job *Job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	m.mu.Lock()
+	m.cancels[job.ID] = cancel
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.cancels, job.ID)
+		m.mu.Unlock()
+		cancel()
+	}()
+
+	err := m.run(jobCtx, job)
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		// shutting down: leave the job to resume on the next start
+		if rErr := m.store.Requeue(job.ID); rErr != nil {
+			slog.Error("failed to requeue job", "job", job.ID, "err", rErr)
+		}
+	case jobCtx.Err() != nil:
+		// cancelled by the caller
+	default:
+		slog.Warn("job failed", "job", job.ID, "err", err)
+		if ok, fErr := m.store.Fail(job.ID, err); fErr != nil {
+			slog.Error("failed to record job failure", "job", job.ID, "err", fErr)
+		} else if ok {
+			m.notify(job.ID)
+		}
+	}
+}
+
+func (m *Manager) run(ctx context.Context, job *Job) (err error) {
+	// cancelling ctx stops the job between chunks, the chunk in flight still finishes
+	traceCtx, span := telemetry.StartSpan(context.WithoutCancel(ctx), "job",
+		attribute.String("job", job.ID), attribute.String("kind", job.Spec.Kind))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	p, err := m.pipeline(job.Spec)
+	if err != nil {
+		return err
+	}
+	p = p.WithContext(traceCtx)
+	// chunks synthesized before a failure are billed too
+	defer p.RecordUsage("")
+	plan, chunkAudio, done, err := m.store.Plan(job.ID)
+	if err != nil {
+		return err
+	}
+	if plan == nil {
+		if plan, err = planFor(p, job.Spec); err != nil {
+			return err
+		}
+		if err = m.store.SavePlan(job.ID, plan); err != nil {
+			return err
+		}
+		chunkAudio = make([][]byte, len(plan.Chunks))
+		done = make([]bool, len(plan.Chunks))
+	}
+
+	for i, ch := range plan.Chunks {
+		if done[i] {
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if chunkAudio[i], err = p.SynthesizeChunk(ch); err != nil {
+			return fmt.Errorf("chunk %d of %d: %w", i+1, len(plan.Chunks), err)
+		}
+		if err = m.store.SaveChunk(job.ID, i, chunkAudio[i]); err != nil {
+			return err
+		}
+	}
+
+	rendering, err := p.Assemble(plan, chunkAudio)
+	if err != nil {
+		return err
+	}
+	transcript := make([]string, 0, len(plan.Chunks))
+	for _, ch := range plan.Chunks {
+		if ch.Text != "" {
+			transcript = append(transcript, strings.TrimSpace(ch.Text))
+		}
+	}
+	ok, err := m.store.Complete(job.ID, []Artifact{
+		{Name: "audio." + string(rendering.Format), ContentType: ContentType(rendering.Format), Data: rendering.Audio},
+		{Name: "transcript.txt", ContentType: "text/plain; charset=utf-8", Data: []byte(strings.Join(transcript, "\n") + "\n")},
+	})
+	if err != nil {
+		return err
+	}
+	p.RecordUsage(job.ID + "/audio." + string(rendering.Format))
+	if ok {
+		m.notify(job.ID)
+	}
+	return nil
+}
+
+// planFor splits the job's input into chunks
+func planFor(p *client.Pipeline, spec Spec) (*client.Plan, error) {
+	switch spec.Kind {
+	case KindText, KindURL:
+		return p.Plan()
+	case KindDocument:
+		switch {
+		case strings.HasPrefix(spec.ContentType, "text/html"):
+			return p.PlanHTML(strings.NewReader(string(spec.Document)))
+		case strings.HasPrefix(spec.ContentType, "text/markdown"):
+			return p.PlanMarkdown(strings.NewReader(string(spec.Document)))
+		}
+		return p.PlanText()
+	}
+	return nil, fmt.Errorf("unknown job kind %q", spec.Kind)
+}
+
+// ContentType is the MIME type served for an audio format
+func ContentType(f audio.Format) string {
+	if f == audio.WAV {
+		return "audio/wav"
+	}
+	return "audio/mpeg"
+}
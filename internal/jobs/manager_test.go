@@ -0,0 +1,325 @@
+package jobs_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/sgerhardt/chatter/internal/jobs"
+	"github.com/sgerhardt/chatter/internal/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func openStore(t *testing.T) *jobs.Store {
+	t.Helper()
+	store, err := jobs.Open(filepath.Join(t.TempDir(), "jobs.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// pipelines voices jobs with the fake, splitting text into chunks of at most limit characters
+func pipelines(eleven *fakeeleven.Server, limit int) jobs.PipelineFunc {
+	return func(spec jobs.Spec) (*client.Pipeline, error) {
+		cfg := &config.AppConfig{
+			APIKey:                "123",
+			BaseURL:               eleven.URL,
+			VoiceID:               fakeeleven.DefaultVoices[0].VoiceID,
+			CharacterRequestLimit: limit,
+			TextInput:             spec.Text,
+		}
+		if spec.Kind == jobs.KindDocument {
+			cfg.TextInput = string(spec.Document)
+		}
+		synth, err := client.NewSynthesizer(cfg, http.DefaultClient)
+		if err != nil {
+			return nil, err
+		}
+		return client.NewPipeline(cfg, http.DefaultClient, synth), nil
+	}
+}
+
+// start runs the manager until the test ends
+func start(t *testing.T, m *jobs.Manager) context.CancelFunc {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, m.Run(ctx))
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func waitFor(t *testing.T, store *jobs.Store, id string) *jobs.Job {
+	t.Helper()
+	var job *jobs.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = store.Get(id)
+		require.NoError(t, err)
+		return job.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	t.Run("synthesizes jobs chunk by chunk", func(t *testing.T) {
+		t.Parallel()
+		eleven := fakeeleven.New(t)
+		store := openStore(t)
+		m := jobs.NewManager(store, pipelines(eleven, 20), jobs.Options{})
+		start(t, m)
+
+		submitted, err := m.Submit(jobs.Spec{Kind: jobs.KindText, Text: "The first sentence. The second sentence."}, "")
+		require.NoError(t, err)
+		assert.Equal(t, jobs.StatusQueued, submitted.Status)
+
+		job := waitFor(t, store, submitted.ID)
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+		assert.Equal(t, 2, job.ChunksTotal)
+		assert.Equal(t, 2, job.ChunksDone)
+		assert.InDelta(t, 1.0, job.Progress, 0.001)
+		// two chunks, then the voice name for the artist tag
+		assert.Len(t, eleven.Requests(), 3)
+
+		artifacts, err := store.Artifacts(job.ID)
+		require.NoError(t, err)
+		require.Len(t, artifacts, 2)
+		assert.Equal(t, "audio.mp3", artifacts[0].Name)
+		assert.Equal(t, "audio/mpeg", artifacts[0].ContentType)
+		transcript, err := store.Artifact(job.ID, "transcript.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "The first sentence.\nThe second sentence.\n", string(transcript.Data))
+	})
+
+	t.Run("records the usage of each chunk", func(t *testing.T) {
+		t.Parallel()
+		eleven := fakeeleven.New(t)
+		store := openStore(t)
+		ledger, err := usage.Open(filepath.Join(t.TempDir(), "usage.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = ledger.Close() })
+		synthesize := pipelines(eleven, 20)
+		m := jobs.NewManager(store, func(spec jobs.Spec) (*client.Pipeline, error) {
+			p, pErr := synthesize(spec)
+			if pErr == nil {
+				p.Config.Tag = spec.Tag
+				p.Ledger = ledger
+			}
+			return p, pErr
+		}, jobs.Options{})
+		start(t, m)
+
+		submitted, err := m.Submit(jobs.Spec{Kind: jobs.KindText, Text: "The first sentence. The second sentence.", Tag: "docs"}, "")
+		require.NoError(t, err)
+		job := waitFor(t, store, submitted.ID)
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+
+		entries, err := ledger.Query(usage.Filter{Tag: "docs"})
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		for _, u := range entries {
+			assert.Equal(t, job.ID+"/audio.mp3", u.Output)
+			assert.NotEmpty(t, u.RequestID)
+		}
+		assert.Equal(t, eleven.CharacterCount(), entries[0].Characters+entries[1].Characters)
+	})
+
+	t.Run("succeeds when the ledger fails", func(t *testing.T) {
+		t.Parallel()
+		eleven := fakeeleven.New(t)
+		store := openStore(t)
+		ledger, err := usage.Open(filepath.Join(t.TempDir(), "usage.db"))
+		require.NoError(t, err)
+		require.NoError(t, ledger.Close())
+		synthesize := pipelines(eleven, 20)
+		m := jobs.NewManager(store, func(spec jobs.Spec) (*client.Pipeline, error) {
+			p, pErr := synthesize(spec)
+			if pErr == nil {
+				p.Ledger = ledger
+			}
+			return p, pErr
+		}, jobs.Options{})
+		start(t, m)
+
+		submitted, err := m.Submit(jobs.Spec{Kind: jobs.KindText, Text: "The first sentence. The second sentence."}, "")
+		require.NoError(t, err)
+		job := waitFor(t, store, submitted.ID)
+		assert.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+	})
+
+	t.Run("resumes interrupted jobs from the next chunk", func(t *testing.T) {
+		t.Parallel()
+		eleven := fakeeleven.New(t)
+		eleven.Inject(fakeeleven.Fault{Path: "/v1/text-to-speech", Delay: 300 * time.Millisecond, Times: 1})
+		store := openStore(t)
+		m := jobs.NewManager(store, pipelines(eleven, 20), jobs.Options{Workers: 1})
+		stop := start(t, m)
+
+		submitted, err := m.Submit(jobs.Spec{Kind: jobs.KindText, Text: "The first sentence. The second sentence."}, "")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return len(eleven.Requests()) == 1 }, 5*time.Second, 5*time.Millisecond)
+		stop()
+
+		job, err := store.Get(submitted.ID)
+		require.NoError(t, err)
+		assert.Equal(t, jobs.StatusQueued, job.Status)
+		assert.Equal(t, 1, job.ChunksDone, "the chunk under way finishes before shutdown")
+
+		start(t, jobs.NewManager(store, pipelines(eleven, 20), jobs.Options{Workers: 1}))
+		job = waitFor(t, store, submitted.ID)
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+		assert.Len(t, eleven.Requests(), 3, "finished chunks are not synthesized again")
+	})
+
+	t.Run("cancels running jobs between chunks", func(t *testing.T) {
+		t.Parallel()
+		eleven := fakeeleven.New(t)
+		eleven.Inject(fakeeleven.Fault{Path: "/v1/text-to-speech", Delay: 200 * time.Millisecond})
+		store := openStore(t)
+		m := jobs.NewManager(store, pipelines(eleven, 20), jobs.Options{})
+		start(t, m)
+
+		submitted, err := m.Submit(jobs.Spec{Kind: jobs.KindText, Text: "The first sentence. The second sentence. The third sentence."}, "")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return len(eleven.Requests()) == 1 }, 5*time.Second, 5*time.Millisecond)
+		job, err := m.Cancel(submitted.ID)
+		require.NoError(t, err)
+		assert.Equal(t, jobs.StatusCancelled, job.Status)
+
+		_, err = m.Cancel(submitted.ID)
+		require.ErrorIs(t, err, jobs.ErrFinished)
+		_, err = m.Cancel("missing")
+		require.ErrorIs(t, err, jobs.ErrNotFound)
+
+		time.Sleep(500 * time.Millisecond)
+		assert.Len(t, eleven.Requests(), 1)
+		job, err = store.Get(submitted.ID)
+		require.NoError(t, err)
+		assert.Equal(t, jobs.StatusCancelled, job.Status)
+	})
+
+	t.Run("signs webhooks", func(t *testing.T) {
+		t.Parallel()
+		eleven := fakeeleven.New(t)
+		events := make(chan jobs.Event, 2)
+		var attempts atomic.Int32
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) == 1 {
+				// the first delivery is retried
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			timestamp, err := strconv.ParseInt(r.Header.Get(jobs.TimestampHeader), 10, 64)
+			assert.NoError(t, err)
+			assert.True(t, jobs.Verify("shh", r.Header.Get(jobs.SignatureHeader), timestamp, body, time.Minute))
+			var event jobs.Event
+			assert.NoError(t, json.Unmarshal(body, &event))
+			events <- event
+		}))
+		t.Cleanup(hook.Close)
+
+		store := openStore(t)
+		m := jobs.NewManager(store, pipelines(eleven, 100), jobs.Options{Workers: 1, WebhookSecret: "shh", WebhookBackoff: time.Millisecond})
+		start(t, m)
+
+		succeeded, err := m.Submit(jobs.Spec{Kind: jobs.KindText, Text: "Hello."}, hook.URL)
+		require.NoError(t, err)
+		event := <-events
+		assert.Equal(t, "job.succeeded", event.Type)
+		assert.Equal(t, succeeded.ID, event.Job.ID)
+		assert.Equal(t, hook.URL, event.Job.WebhookURL)
+
+		eleven.Inject(fakeeleven.Fault{Path: "/v1/text-to-speech", Status: http.StatusInternalServerError})
+		failed, err := m.Submit(jobs.Spec{Kind: jobs.KindText, Text: "Hello."}, hook.URL)
+		require.NoError(t, err)
+		event = <-events
+		assert.Equal(t, "job.failed", event.Type)
+		assert.Equal(t, failed.ID, event.Job.ID)
+		assert.Contains(t, event.Job.Error, "500")
+	})
+
+	t.Run("refuses webhooks without a secret", func(t *testing.T) {
+		t.Parallel()
+		m := jobs.NewManager(openStore(t), pipelines(fakeeleven.New(t), 100), jobs.Options{})
+		_, err := m.Submit(jobs.Spec{Kind: jobs.KindText, Text: "Hello."}, "https://example.com/hook")
+		require.EqualError(t, err, "webhooks are disabled: the server has no webhook secret")
+	})
+
+	t.Run("plans uploaded HTML like a website", func(t *testing.T) {
+		t.Parallel()
+		eleven := fakeeleven.New(t)
+		store := openStore(t)
+		m := jobs.NewManager(store, pipelines(eleven, 100), jobs.Options{})
+		start(t, m)
+
+		submitted, err := m.Submit(jobs.Spec{
+			Kind:        jobs.KindDocument,
+			Document:    []byte("<html><head><title>Notes</title></head><body><p>Only the body is read.</p></body></html>"),
+			ContentType: "text/html",
+		}, "")
+		require.NoError(t, err)
+		job := waitFor(t, store, submitted.ID)
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+		assert.Equal(t, "Notes", job.Title)
+		requests := eleven.Requests()
+		require.Len(t, requests, 2)
+		assert.False(t, strings.Contains(string(requests[0].Body), "<p>"))
+	})
+
+	t.Run("plans uploaded markdown without its syntax", func(t *testing.T) {
+		t.Parallel()
+		eleven := fakeeleven.New(t)
+		store := openStore(t)
+		m := jobs.NewManager(store, pipelines(eleven, 100), jobs.Options{})
+		start(t, m)
+
+		submitted, err := m.Submit(jobs.Spec{
+			Kind:        jobs.KindDocument,
+			Document:    []byte("# Notes\n\nRead **this** [page](https://example.com).\n"),
+			ContentType: "text/markdown",
+		}, "")
+		require.NoError(t, err)
+		job := waitFor(t, store, submitted.ID)
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+		assert.Equal(t, "Notes", job.Title)
+		var body struct{ Text string }
+		require.NoError(t, json.Unmarshal(eleven.Requests()[0].Body, &body))
+		assert.Equal(t, "Notes\nRead this page.\n", body.Text)
+	})
+}
+
+func TestSign(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"type":"job.succeeded"}`)
+	now := time.Now().Unix()
+	signature := jobs.Sign("shh", now, body)
+	assert.True(t, strings.HasPrefix(signature, "sha256="))
+	assert.True(t, jobs.Verify("shh", signature, now, body, time.Minute))
+	assert.False(t, jobs.Verify("other", signature, now, body, time.Minute), "wrong secret")
+	assert.False(t, jobs.Verify("shh", signature, now, []byte("{}"), time.Minute), "altered body")
+	old := now - 600
+	assert.False(t, jobs.Verify("shh", jobs.Sign("shh", old, body), old, body, time.Minute), "replayed")
+}
@@ -0,0 +1,437 @@
+// Package jobs runs long conversions in the background. Jobs are planned into chunks, synthesized one chunk
+// at a time by a pool of workers and kept in SQLite, so progress survives restarts and interrupted jobs resume
+// where they stopped. Callers poll progress, download artifacts, cancel jobs and receive signed webhooks.
+package jobs
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	_ "modernc.org/sqlite" // registers the pure Go sqlite driver, so builds don't need cgo
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Job kinds
+const (
+	KindText     = "text"
+	KindURL      = "url"
+	KindDocument = "document"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// ErrNotFound is returned for a job or artifact that doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// ErrFinished is returned when cancelling a job that has already finished
+var ErrFinished = errors.New("job has already finished")
+
+// Spec is what a job converts and how
+type Spec struct {
+	Kind string `json:"kind"`
+	Text string `json:"text,omitempty"`
+	URL  string `json:"url,omitempty"`
+	// Document is an uploaded HTML or plain text file
+	Document      []byte                `json:"document,omitempty"`
+	ContentType   string                `json:"content_type,omitempty"`
+	VoiceID       string                `json:"voice_id,omitempty"`
+	ModelID       string                `json:"model_id,omitempty"`
+	VoiceSettings *config.VoiceSettings `json:"voice_settings,omitempty"`
+	OutputFormat  string                `json:"output_format,omitempty"`
+	Tag           string                `json:"tag,omitempty"`
+}
+
+// Job is a conversion and its progress
+type Job struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	ChunksTotal int        `json:"chunks_total"`
+	ChunksDone  int        `json:"chunks_done"`
+	Progress    float64    `json:"progress"`
+	WebhookURL  string     `json:"webhook_url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Spec        Spec       `json:"-"`
+}
+
+// Finished reports whether the job has stopped for good
+func (j *Job) Finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCancelled
+}
+
+// Artifact is a file produced by a job
+type Artifact struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	Data        []byte `json:"-"`
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS jobs (
+	id           TEXT PRIMARY KEY,
+	kind         TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	spec         TEXT NOT NULL,
+	webhook_url  TEXT NOT NULL DEFAULT '',
+	plan         TEXT NOT NULL DEFAULT '',
+	chunks_total INTEGER NOT NULL DEFAULT 0,
+	chunks_done  INTEGER NOT NULL DEFAULT 0,
+	title        TEXT NOT NULL DEFAULT '',
+	error        TEXT NOT NULL DEFAULT '',
+	created_at   INTEGER NOT NULL,
+	updated_at   INTEGER NOT NULL,
+	finished_at  INTEGER
+);
+CREATE INDEX IF NOT EXISTS jobs_status ON jobs (status, created_at);
+CREATE TABLE IF NOT EXISTS chunks (
+	job_id TEXT NOT NULL,
+	idx    INTEGER NOT NULL,
+	chunk  TEXT NOT NULL,
+	done   INTEGER NOT NULL DEFAULT 0,
+	audio  BLOB,
+	PRIMARY KEY (job_id, idx)
+);
+CREATE TABLE IF NOT EXISTS artifacts (
+	job_id       TEXT NOT NULL,
+	name         TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	data         BLOB NOT NULL,
+	PRIMARY KEY (job_id, name)
+);`
+
+// Store keeps jobs, their chunks and artifacts in a SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	// one connection serializes writers, which SQLite would otherwise reject as busy
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create job tables in %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func now() int64 {
+	return time.Now().UnixMilli()
+}
+
+// Create stores a queued job for the spec
+func (s *Store) Create(spec Spec, webhookURL string) (*Job, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	id, t := newID(), now()
+	_, err = s.db.Exec(`INSERT INTO jobs (id, kind, status, spec, webhook_url, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, spec.Kind, StatusQueued, string(data), webhookURL, t, t)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// Get returns a job
+func (s *Store) Get(id string) (*Job, error) {
+	var j Job
+	var spec string
+	var created, updated int64
+	var finished sql.NullInt64
+	err := s.db.QueryRow(`SELECT id, kind, status, spec, webhook_url, chunks_total, chunks_done, title, error, created_at, updated_at, finished_at
+		FROM jobs WHERE id = ?`, id).
+		Scan(&j.ID, &j.Kind, &j.Status, &spec, &j.WebhookURL, &j.ChunksTotal, &j.ChunksDone, &j.Title, &j.Error, &created, &updated, &finished)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal([]byte(spec), &j.Spec); err != nil {
+		return nil, fmt.Errorf("job %s has an invalid spec: %w", id, err)
+	}
+	j.CreatedAt = time.UnixMilli(created).UTC()
+	j.UpdatedAt = time.UnixMilli(updated).UTC()
+	if finished.Valid {
+		t := time.UnixMilli(finished.Int64).UTC()
+		j.FinishedAt = &t
+	}
+	if j.ChunksTotal > 0 {
+		j.Progress = float64(j.ChunksDone) / float64(j.ChunksTotal)
+	}
+	if j.Status == StatusSucceeded {
+		j.Progress = 1
+	}
+	return &j, nil
+}
+
+// Claim marks the oldest queued job as running and returns it, or nil when none is queued
+func (s *Store) Claim() (*Job, error) {
+	var id string
+	err := s.db.QueryRow(`UPDATE jobs SET status = ?, updated_at = ?
+		WHERE id = (SELECT id FROM jobs WHERE status = ? ORDER BY created_at, rowid LIMIT 1) RETURNING id`,
+		StatusRunning, now(), StatusQueued).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// Requeue returns a running job, or every running job when id is empty, to the queue to be resumed
+func (s *Store) Requeue(id string) error {
+	query, args := `UPDATE jobs SET status = ?, updated_at = ? WHERE status = ?`, []any{StatusQueued, now(), StatusRunning}
+	if id != "" {
+		query += ` AND id = ?`
+		args = append(args, id)
+	}
+	_, err := s.db.Exec(query, args...)
+	return err
+}
+
+// SavePlan stores the job's chunks, ready to be synthesized
+func (s *Store) SavePlan(id string, plan *client.Plan) error {
+	summary := *plan
+	summary.Chunks = nil
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err = tx.Exec(`DELETE FROM chunks WHERE job_id = ?`, id); err != nil {
+		return err
+	}
+	for i, ch := range plan.Chunks {
+		chunk, mErr := json.Marshal(ch)
+		if mErr != nil {
+			return mErr
+		}
+		if _, err = tx.Exec(`INSERT INTO chunks (job_id, idx, chunk) VALUES (?, ?, ?)`, id, i, string(chunk)); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(`UPDATE jobs SET plan = ?, title = ?, chunks_total = ?, chunks_done = 0, updated_at = ? WHERE id = ?`,
+		string(data), plan.Title, len(plan.Chunks), now(), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Plan returns the job's plan with its chunks, and the audio of the chunks already synthesized. It returns nil
+// when the job hasn't been planned.
+func (s *Store) Plan(id string) (*client.Plan, [][]byte, []bool, error) {
+	var data string
+	if err := s.db.QueryRow(`SELECT plan FROM jobs WHERE id = ?`, id).Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil, ErrNotFound
+		}
+		return nil, nil, nil, err
+	}
+	if data == "" {
+		return nil, nil, nil, nil
+	}
+	var plan client.Plan
+	if err := json.Unmarshal([]byte(data), &plan); err != nil {
+		return nil, nil, nil, err
+	}
+	rows, err := s.db.Query(`SELECT chunk, done, audio FROM chunks WHERE job_id = ? ORDER BY idx`, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var chunkAudio [][]byte
+	var done []bool
+	for rows.Next() {
+		var chunk string
+		var d bool
+		var a []byte
+		if err = rows.Scan(&chunk, &d, &a); err != nil {
+			return nil, nil, nil, err
+		}
+		var ch client.Chunk
+		if err = json.Unmarshal([]byte(chunk), &ch); err != nil {
+			return nil, nil, nil, err
+		}
+		plan.Chunks = append(plan.Chunks, ch)
+		chunkAudio = append(chunkAudio, a)
+		done = append(done, d)
+	}
+	return &plan, chunkAudio, done, rows.Err()
+}
+
+// SaveChunk stores a synthesized chunk and advances the job's progress
+func (s *Store) SaveChunk(id string, idx int, audio []byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	res, err := tx.Exec(`UPDATE chunks SET done = 1, audio = ? WHERE job_id = ? AND idx = ? AND done = 0`, audio, id, idx)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		if _, err = tx.Exec(`UPDATE jobs SET chunks_done = chunks_done + 1, updated_at = ? WHERE id = ?`, now(), id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Complete stores the job's artifacts and marks it succeeded, dropping the chunk audio. It does nothing
+// when the job is no longer running, such as after being cancelled.
+func (s *Store) Complete(id string, artifacts []Artifact) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	t := now()
+	res, err := tx.Exec(`UPDATE jobs SET status = ?, updated_at = ?, finished_at = ? WHERE id = ? AND status = ?`,
+		StatusSucceeded, t, t, id, StatusRunning)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	for _, a := range artifacts {
+		if _, err = tx.Exec(`INSERT OR REPLACE INTO artifacts (job_id, name, content_type, data) VALUES (?, ?, ?, ?)`,
+			id, a.Name, a.ContentType, a.Data); err != nil {
+			return false, err
+		}
+	}
+	if _, err = tx.Exec(`DELETE FROM chunks WHERE job_id = ?`, id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Fail marks a running job failed
+func (s *Store) Fail(id string, reason error) (bool, error) {
+	t := now()
+	res, err := s.db.Exec(`UPDATE jobs SET status = ?, error = ?, updated_at = ?, finished_at = ? WHERE id = ? AND status = ?`,
+		StatusFailed, reason.Error(), t, t, id, StatusRunning)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// Cancel stops a queued or running job
+func (s *Store) Cancel(id string) (*Job, error) {
+	t := now()
+	res, err := s.db.Exec(`UPDATE jobs SET status = ?, updated_at = ?, finished_at = ? WHERE id = ? AND status IN (?, ?)`,
+		StatusCancelled, t, t, id, StatusQueued, StatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	job, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return job, ErrFinished
+	}
+	_, err = s.db.Exec(`DELETE FROM chunks WHERE job_id = ?`, id)
+	return job, err
+}
+
+// Artifacts lists a job's artifacts, without their data
+func (s *Store) Artifacts(id string) ([]Artifact, error) {
+	rows, err := s.db.Query(`SELECT name, content_type, length(data) FROM artifacts WHERE job_id = ? ORDER BY name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	artifacts := []Artifact{}
+	for rows.Next() {
+		var a Artifact
+		if err = rows.Scan(&a.Name, &a.ContentType, &a.Size); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
+
+// Artifact returns one of a job's artifacts with its data
+func (s *Store) Artifact(id, name string) (*Artifact, error) {
+	a := Artifact{Name: name}
+	err := s.db.QueryRow(`SELECT content_type, data FROM artifacts WHERE job_id = ? AND name = ?`, id, name).Scan(&a.ContentType, &a.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.Size = len(a.Data)
+	return &a, nil
+}
+
+// Prune deletes jobs that finished before cutoff, with their artifacts
+func (s *Store) Prune(cutoff time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	old := `SELECT id FROM jobs WHERE finished_at IS NOT NULL AND finished_at < ?`
+	for _, table := range []string{"chunks", "artifacts"} {
+		if _, err = tx.Exec(`DELETE FROM `+table+` WHERE job_id IN (`+old+`)`, cutoff.UnixMilli()); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(`DELETE FROM jobs WHERE finished_at IS NOT NULL AND finished_at < ?`, cutoff.UnixMilli()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
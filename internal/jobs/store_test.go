@@ -0,0 +1,102 @@
+package jobs_test
+
+import (
+	"errors"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/jobs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keeps a job's plan and finished chunks", func(t *testing.T) {
+		t.Parallel()
+		store := openStore(t)
+		created, err := store.Create(jobs.Spec{Kind: jobs.KindText, Text: "Hello world"}, "")
+		require.NoError(t, err)
+		assert.Equal(t, "Hello world", created.Spec.Text)
+
+		job, err := store.Claim()
+		require.NoError(t, err)
+		require.Equal(t, created.ID, job.ID)
+		assert.Equal(t, jobs.StatusRunning, job.Status)
+		job, err = store.Claim()
+		require.NoError(t, err)
+		assert.Nil(t, job, "nothing else is queued")
+
+		plan, _, _, err := store.Plan(created.ID)
+		require.NoError(t, err)
+		assert.Nil(t, plan, "not planned yet")
+		require.NoError(t, store.SavePlan(created.ID, &client.Plan{Title: "Hello", Chunks: []client.Chunk{{Text: "Hello"}, {Text: "world"}}}))
+		require.NoError(t, store.SaveChunk(created.ID, 0, []byte("hello audio")))
+
+		plan, chunkAudio, done, err := store.Plan(created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", plan.Title)
+		assert.Equal(t, [][]byte{[]byte("hello audio"), nil}, chunkAudio)
+		assert.Equal(t, []bool{true, false}, done)
+
+		job, err = store.Get(created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", job.Title)
+		assert.InDelta(t, 0.5, job.Progress, 0.001)
+
+		require.NoError(t, store.Requeue(""))
+		job, err = store.Claim()
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, job.ID, "requeued jobs are claimed again")
+	})
+
+	t.Run("finishes a job once", func(t *testing.T) {
+		t.Parallel()
+		store := openStore(t)
+		created, err := store.Create(jobs.Spec{Kind: jobs.KindText, Text: "Hello"}, "")
+		require.NoError(t, err)
+		ok, err := store.Complete(created.ID, nil)
+		require.NoError(t, err)
+		assert.False(t, ok, "only running jobs complete")
+
+		_, err = store.Claim()
+		require.NoError(t, err)
+		_, err = store.Cancel(created.ID)
+		require.NoError(t, err)
+		ok, err = store.Fail(created.ID, errors.New("too late"))
+		require.NoError(t, err)
+		assert.False(t, ok, "a cancelled job stays cancelled")
+
+		job, err := store.Get(created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, jobs.StatusCancelled, job.Status)
+		assert.True(t, job.Finished())
+		assert.Empty(t, job.Error)
+	})
+
+	t.Run("prunes old jobs", func(t *testing.T) {
+		t.Parallel()
+		store := openStore(t)
+		finished, err := store.Create(jobs.Spec{Kind: jobs.KindText, Text: "Hello"}, "")
+		require.NoError(t, err)
+		_, err = store.Claim()
+		require.NoError(t, err)
+		_, err = store.Complete(finished.ID, []jobs.Artifact{{Name: "transcript.txt", ContentType: "text/plain", Data: []byte("Hello")}})
+		require.NoError(t, err)
+		queued, err := store.Create(jobs.Spec{Kind: jobs.KindText, Text: "Later"}, "")
+		require.NoError(t, err)
+
+		require.NoError(t, store.Prune(time.Now().Add(-time.Hour)))
+		_, err = store.Get(finished.ID)
+		require.NoError(t, err, "finished within the retention")
+
+		require.NoError(t, store.Prune(time.Now().Add(time.Hour)))
+		_, err = store.Get(finished.ID)
+		require.ErrorIs(t, err, jobs.ErrNotFound)
+		_, err = store.Artifact(finished.ID, "transcript.txt")
+		require.ErrorIs(t, err, jobs.ErrNotFound)
+		_, err = store.Get(queued.ID)
+		require.NoError(t, err, "unfinished jobs are kept")
+	})
+}
@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Webhook headers. The signature is "sha256=" and the hex HMAC-SHA256, keyed with the webhook secret, of the
+// timestamp, a full stop and the body, so receivers can reject both forged and replayed deliveries.
+const (
+	SignatureHeader = "X-Chatter-Signature"
+	TimestampHeader = "X-Chatter-Timestamp"
+)
+
+// Event is the body of a webhook delivery
+type Event struct {
+	// Type is job.succeeded or job.failed
+	Type string `json:"type"`
+	Job  Job    `json:"job"`
+}
+
+// Sign returns the signature of a delivery made at timestamp, in Unix seconds
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery's signature, and that its timestamp is within tolerance of now
+func Verify(secret, signature string, timestamp int64, body []byte, tolerance time.Duration) bool {
+	if d := time.Since(time.Unix(timestamp, 0)); d > tolerance || d < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+// notify delivers the finished job to its webhook in the background
+func (m *Manager) notify(id string) {
+	job, err := m.store.Get(id)
+	if err != nil || job.WebhookURL == "" {
+		return
+	}
+	body, err := json.Marshal(Event{Type: "job." + job.Status, Job: *job})
+	if err != nil {
+		slog.Error("failed to encode webhook", "job", id, "err", err)
+		return
+	}
+	m.deliveries.Add(1)
+	go func() {
+		defer m.deliveries.Done()
+		backoff := m.opts.WebhookBackoff
+		for attempt := 1; ; attempt++ {
+			err := m.deliver(job.WebhookURL, body)
+			if err == nil {
+				return
+			}
+			if attempt == m.opts.WebhookAttempts {
+				slog.Warn("giving up on webhook", "job", id, "attempts", attempt, "err", err)
+				return
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}()
+}
+
+func (m *Manager) deliver(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(m.opts.WebhookSecret, timestamp, body))
+	res, err := m.opts.WebhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package lexicon
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultAlphabet is used for phonemes when a file doesn't name one
+const DefaultAlphabet = "ipa"
+
+// Rule is a single pronunciation, in the form the Eleven Labs API accepts
+type Rule struct {
+	StringToReplace string `json:"string_to_replace"`
+	Type            string `json:"type"`
+	Alias           string `json:"alias,omitempty"`
+	Phoneme         string `json:"phoneme,omitempty"`
+	Alphabet        string `json:"alphabet,omitempty"`
+}
+
+// Load reads pronunciation rules from a PLS lexicon (.pls or .xml) or a plain text file
+// with one word=phoneme pair per line. alphabet applies to plain text files.
+func Load(path, alphabet string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dictionary: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			slog.Warn("error closing file", "path", path, "err", closeErr)
+		}
+	}()
+
+	var rules []Rule
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pls", ".xml":
+		rules, err = ParsePLS(f)
+	default:
+		rules, err = ParseText(f, alphabet)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("dictionary has no rules")
+	}
+	return rules, nil
+}
+
+// ParseText reads word=phoneme lines, skipping blank lines and # comments
+func ParseText(r io.Reader, alphabet string) ([]Rule, error) {
+	if alphabet == "" {
+		alphabet = DefaultAlphabet
+	}
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, phoneme, ok := strings.Cut(line, "=")
+		word, phoneme = strings.TrimSpace(word), strings.TrimSpace(phoneme)
+		if !ok || word == "" || phoneme == "" {
+			return nil, fmt.Errorf("line %d: expected word=phoneme", n)
+		}
+		rules = append(rules, Rule{StringToReplace: word, Type: "phoneme", Phoneme: phoneme, Alphabet: alphabet})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dictionary: %w", err)
+	}
+	return rules, nil
+}
+
+type pls struct {
+	Alphabet string `xml:"alphabet,attr"`
+	Lexemes  []struct {
+		Graphemes []string `xml:"grapheme"`
+		Phoneme   string   `xml:"phoneme"`
+		Alias     string   `xml:"alias"`
+	} `xml:"lexeme"`
+}
+
+// ParsePLS reads a W3C Pronunciation Lexicon Specification document. Each grapheme of a lexeme
+// becomes a rule using the lexeme's phoneme, or its alias when there is no phoneme.
+func ParsePLS(r io.Reader) ([]Rule, error) {
+	var doc pls
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse PLS: %w", err)
+	}
+	alphabet := doc.Alphabet
+	if alphabet == "" {
+		alphabet = DefaultAlphabet
+	}
+	var rules []Rule
+	for i, lx := range doc.Lexemes {
+		phoneme, alias := strings.TrimSpace(lx.Phoneme), strings.TrimSpace(lx.Alias)
+		if len(lx.Graphemes) == 0 || (phoneme == "" && alias == "") {
+			return nil, fmt.Errorf("lexeme %d: needs a grapheme and a phoneme or alias", i+1)
+		}
+		for _, g := range lx.Graphemes {
+			rule := Rule{StringToReplace: strings.TrimSpace(g), Type: "alias", Alias: alias}
+			if phoneme != "" {
+				rule = Rule{StringToReplace: rule.StringToReplace, Type: "phoneme", Phoneme: phoneme, Alphabet: alphabet}
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
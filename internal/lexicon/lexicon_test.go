@@ -0,0 +1,80 @@
+package lexicon_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/lexicon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+		alphabet string
+		want     []lexicon.Rule
+		error    string
+	}{
+		{
+			name:     "reads word=phoneme files",
+			file:     "words.txt",
+			contents: "# product names\nchatter = ˈtʃætɚ\n\nKubernetes=kuːbərˈnɛtiːz\n",
+			want: []lexicon.Rule{
+				{StringToReplace: "chatter", Type: "phoneme", Phoneme: "ˈtʃætɚ", Alphabet: "ipa"},
+				{StringToReplace: "Kubernetes", Type: "phoneme", Phoneme: "kuːbərˈnɛtiːz", Alphabet: "ipa"},
+			},
+		},
+		{
+			name:     "uses the requested alphabet",
+			file:     "words.txt",
+			contents: "tomato=T AH0 M EY1 T OW2",
+			alphabet: "cmu-arpabet",
+			want:     []lexicon.Rule{{StringToReplace: "tomato", Type: "phoneme", Phoneme: "T AH0 M EY1 T OW2", Alphabet: "cmu-arpabet"}},
+		},
+		{
+			name:     "reports malformed lines",
+			file:     "words.txt",
+			contents: "tomato\n",
+			error:    "line 1: expected word=phoneme",
+		},
+		{
+			name: "reads PLS lexicons",
+			file: "lexicon.pls",
+			contents: `<?xml version="1.0" encoding="UTF-8"?>
+<lexicon version="1.0" xmlns="http://www.w3.org/2005/01/pronunciation-lexicon" alphabet="ipa" xml:lang="en-US">
+  <lexeme><grapheme>tomato</grapheme><grapheme>Tomato</grapheme><phoneme>təˈmeɪtoʊ</phoneme></lexeme>
+  <lexeme><grapheme>UN</grapheme><alias>United Nations</alias></lexeme>
+</lexicon>`,
+			want: []lexicon.Rule{
+				{StringToReplace: "tomato", Type: "phoneme", Phoneme: "təˈmeɪtoʊ", Alphabet: "ipa"},
+				{StringToReplace: "Tomato", Type: "phoneme", Phoneme: "təˈmeɪtoʊ", Alphabet: "ipa"},
+				{StringToReplace: "UN", Type: "alias", Alias: "United Nations"},
+			},
+		},
+		{
+			name:     "rejects empty dictionaries",
+			file:     "empty.txt",
+			contents: "# nothing yet\n",
+			error:    "dictionary has no rules",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), tt.file)
+			require.NoError(t, os.WriteFile(path, []byte(tt.contents), 0600))
+			rules, err := lexicon.Load(path, tt.alphabet)
+			if tt.error != "" {
+				assert.EqualError(t, err, tt.error)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rules)
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	tagRe  = regexp.MustCompile(`<\s*(/)?\s*([a-zA-Z][a-zA-Z-]*)((?:\s+[a-zA-Z:-]+\s*=\s*"[^"]*")*)\s*(/)?\s*>`)
+	attrRe = regexp.MustCompile(`([a-zA-Z:-]+)\s*=\s*"([^"]*)"`)
+)
+
+// tags lists the supported elements and whether each one wraps text
+var tags = map[string]bool{
+	"speak":    true,
+	"break":    false,
+	"phoneme":  true,
+	"sub":      true,
+	"say-as":   true,
+	"emphasis": true,
+	"voice":    true,
+}
+
+// Support describes which tags a model understands natively
+type Support struct {
+	Breaks   bool
+	Phonemes bool
+	MaxBreak time.Duration
+}
+
+// SupportFor returns the tags accepted by an Eleven Labs model. Unknown models get no native
+// support so markup is always rendered locally.
+func SupportFor(modelID string) Support {
+	switch modelID {
+	case "eleven_monolingual_v1", "eleven_turbo_v2", "eleven_flash_v2":
+		return Support{Breaks: true, Phonemes: true, MaxBreak: 3 * time.Second}
+	case "eleven_multilingual_v2", "eleven_turbo_v2_5", "eleven_flash_v2_5":
+		return Support{Breaks: true, MaxBreak: 3 * time.Second}
+	default:
+		return Support{}
+	}
+}
+
+// Segment is a run of text spoken by one voice, followed by a pause
+type Segment struct {
+	// Text to send, including any inline tags the model accepts. Empty for a pause on its own.
+	Text string
+	// Voice overrides the default voice when set
+	Voice string
+	// Pause is the silence to insert after the text
+	Pause time.Duration
+}
+
+// Contains reports whether text uses any supported markup
+func Contains(text string) bool {
+	for _, m := range tagRe.FindAllStringSubmatch(text, -1) {
+		if _, ok := tags[strings.ToLower(m[2])]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Strip removes markup tags, leaving the text they wrap
+func Strip(text string) string {
+	return tagRe.ReplaceAllStringFunc(text, func(tag string) string {
+		if Contains(tag) {
+			return ""
+		}
+		return tag
+	})
+}
+
+// MapText applies fn to the text outside of tags. The content of say-as, phoneme and sub elements
+// is left alone since it is already spoken exactly as written.
+func MapText(text string, fn func(string) string) string {
+	var sb strings.Builder
+	leafDepth := 0
+	pos := 0
+	for _, loc := range tagRe.FindAllStringSubmatchIndex(text, -1) {
+		name := strings.ToLower(text[loc[4]:loc[5]])
+		if _, ok := tags[name]; !ok {
+			continue
+		}
+		if leafDepth == 0 {
+			sb.WriteString(fn(text[pos:loc[0]]))
+		} else {
+			sb.WriteString(text[pos:loc[0]])
+		}
+		sb.WriteString(text[loc[0]:loc[1]])
+		pos = loc[1]
+
+		switch name {
+		case "say-as", "phoneme", "sub":
+			if loc[2] >= 0 {
+				leafDepth--
+			} else if loc[8] < 0 {
+				leafDepth++
+			}
+		}
+	}
+	if leafDepth == 0 {
+		sb.WriteString(fn(text[pos:]))
+	} else {
+		sb.WriteString(text[pos:])
+	}
+	return sb.String()
+}
+
+type element struct {
+	name  string
+	attrs map[string]string
+	text  strings.Builder
+}
+
+// Compile validates the markup in text and translates it for a model. Breaks the model cannot
+// render become pauses between segments, voice switches start a new segment and say-as,
+// sub and unsupported phoneme tags are expanded to plain text.
+func Compile(text string, support Support) ([]Segment, error) {
+	c := &compiler{support: support, segments: []Segment{{}}}
+	var stack []*element
+	pos := 0
+	for _, loc := range tagRe.FindAllStringSubmatchIndex(text, -1) {
+		c.text(stack, text[pos:loc[0]])
+		pos = loc[1]
+
+		closing := loc[2] >= 0
+		name := strings.ToLower(text[loc[4]:loc[5]])
+		selfClosing := loc[8] >= 0
+		wraps, ok := tags[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported tag <%s> at offset %d", name, loc[0])
+		}
+		attrs := map[string]string{}
+		for _, a := range attrRe.FindAllStringSubmatch(text[loc[6]:loc[7]], -1) {
+			attrs[strings.ToLower(a[1])] = a[2]
+		}
+
+		switch {
+		case closing:
+			if len(stack) == 0 || stack[len(stack)-1].name != name {
+				return nil, fmt.Errorf("unexpected </%s> at offset %d", name, loc[0])
+			}
+			el := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if err := c.end(stack, el); err != nil {
+				return nil, fmt.Errorf("<%s> at offset %d: %w", name, loc[0], err)
+			}
+		case !wraps || selfClosing:
+			if wraps {
+				return nil, fmt.Errorf("<%s/> at offset %d must wrap text", name, loc[0])
+			}
+			if err := c.empty(stack, name, attrs); err != nil {
+				return nil, fmt.Errorf("<%s> at offset %d: %w", name, loc[0], err)
+			}
+		default:
+			if err := c.start(stack, name, attrs); err != nil {
+				return nil, fmt.Errorf("<%s> at offset %d: %w", name, loc[0], err)
+			}
+			stack = append(stack, &element{name: name, attrs: attrs})
+		}
+	}
+	c.text(stack, text[pos:])
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("<%s> is never closed", stack[len(stack)-1].name)
+	}
+	return c.result(), nil
+}
+
+type compiler struct {
+	support  Support
+	segments []Segment
+	voices   []string
+}
+
+func (c *compiler) current() *Segment {
+	return &c.segments[len(c.segments)-1]
+}
+
+// text appends plain text, either to the innermost element that rewrites its content or to the current segment
+func (c *compiler) text(stack []*element, s string) {
+	if s == "" {
+		return
+	}
+	if el := innermostLeaf(stack); el != nil {
+		el.text.WriteString(s)
+		return
+	}
+	c.current().Text += s
+}
+
+// innermostLeaf returns the open element whose text is rewritten when it closes
+func innermostLeaf(stack []*element) *element {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch stack[i].name {
+		case "phoneme", "sub", "say-as", "emphasis":
+			return stack[i]
+		}
+	}
+	return nil
+}
+
+func (c *compiler) start(stack []*element, name string, attrs map[string]string) error {
+	if innermostLeaf(stack) != nil {
+		return fmt.Errorf("cannot be nested inside <%s>", innermostLeaf(stack).name)
+	}
+	switch name {
+	case "voice":
+		voice := attrs["name"]
+		if voice == "" {
+			return fmt.Errorf("name attribute is required")
+		}
+		c.voices = append(c.voices, voice)
+		c.next(voice)
+	case "phoneme":
+		if attrs["ph"] == "" {
+			return fmt.Errorf("ph attribute is required")
+		}
+		if a := attrs["alphabet"]; a != "" && a != "ipa" && a != "cmu-arpabet" {
+			return fmt.Errorf("unsupported alphabet %q", a)
+		}
+	case "sub":
+		if attrs["alias"] == "" {
+			return fmt.Errorf("alias attribute is required")
+		}
+	case "say-as":
+		if _, ok := interpreters[attrs["interpret-as"]]; !ok {
+			return fmt.Errorf("unsupported interpret-as %q", attrs["interpret-as"])
+		}
+	case "emphasis":
+		switch attrs["level"] {
+		case "", "strong", "moderate", "reduced", "none":
+		default:
+			return fmt.Errorf("unsupported level %q", attrs["level"])
+		}
+	}
+	return nil
+}
+
+func (c *compiler) end(stack []*element, el *element) error {
+	content := el.text.String()
+	switch el.name {
+	case "voice":
+		c.voices = c.voices[:len(c.voices)-1]
+		voice := ""
+		if len(c.voices) > 0 {
+			voice = c.voices[len(c.voices)-1]
+		}
+		c.next(voice)
+	case "phoneme":
+		if c.support.Phonemes {
+			alphabet := el.attrs["alphabet"]
+			if alphabet == "" {
+				alphabet = "ipa"
+			}
+			c.text(stack, fmt.Sprintf(`<phoneme alphabet="%s" ph="%s">%s</phoneme>`, alphabet, el.attrs["ph"], content))
+		} else {
+			c.text(stack, content)
+		}
+	case "sub":
+		c.text(stack, el.attrs["alias"])
+	case "say-as":
+		expanded, err := interpreters[el.attrs["interpret-as"]](strings.TrimSpace(content), el.attrs["format"])
+		if err != nil {
+			return err
+		}
+		c.text(stack, expanded)
+	case "emphasis":
+		// No model has an emphasis tag, but capitals are delivered with more stress
+		if el.attrs["level"] == "strong" {
+			content = strings.ToUpper(content)
+		}
+		c.text(stack, content)
+	}
+	return nil
+}
+
+func (c *compiler) empty(stack []*element, name string, attrs map[string]string) error {
+	if name != "break" {
+		return fmt.Errorf("must wrap text")
+	}
+	if innermostLeaf(stack) != nil {
+		return fmt.Errorf("cannot be nested inside <%s>", innermostLeaf(stack).name)
+	}
+	d, err := breakDuration(attrs)
+	if err != nil {
+		return err
+	}
+	if c.support.Breaks && d <= c.support.MaxBreak {
+		c.current().Text += fmt.Sprintf(`<break time="%ss" />`, strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+		return nil
+	}
+	c.current().Pause += d
+	c.next(c.current().Voice)
+	return nil
+}
+
+var strengths = map[string]time.Duration{
+	"none":     0,
+	"x-weak":   100 * time.Millisecond,
+	"weak":     250 * time.Millisecond,
+	"medium":   500 * time.Millisecond,
+	"strong":   time.Second,
+	"x-strong": 2 * time.Second,
+}
+
+func breakDuration(attrs map[string]string) (time.Duration, error) {
+	if t, ok := attrs["time"]; ok {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q", t)
+		}
+		if d < 0 {
+			return 0, fmt.Errorf("time %q is negative", t)
+		}
+		return d, nil
+	}
+	strength := attrs["strength"]
+	if strength == "" {
+		strength = "medium"
+	}
+	d, ok := strengths[strength]
+	if !ok {
+		return 0, fmt.Errorf("unsupported strength %q", strength)
+	}
+	return d, nil
+}
+
+// next starts a new segment for voice
+func (c *compiler) next(voice string) {
+	if cur := c.current(); strings.TrimSpace(cur.Text) == "" && cur.Pause == 0 {
+		cur.Text = ""
+		cur.Voice = voice
+		return
+	}
+	c.segments = append(c.segments, Segment{Voice: voice})
+}
+
+// result tidies whitespace and folds pauses from empty segments into the previous segment
+func (c *compiler) result() []Segment {
+	var out []Segment
+	for _, s := range c.segments {
+		s.Text = strings.TrimSpace(s.Text)
+		if s.Text == "" && len(out) > 0 {
+			out[len(out)-1].Pause += s.Pause
+			continue
+		}
+		if s.Text == "" && s.Pause == 0 {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package markup_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/markup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	native := markup.SupportFor("eleven_monolingual_v1")
+	none := markup.SupportFor("some_future_model")
+
+	tests := []struct {
+		name    string
+		text    string
+		support markup.Support
+		want    []markup.Segment
+		error   string
+	}{
+		{
+			name:    "keeps short breaks inline when the model supports them",
+			text:    `Hello <break time="1.5s"/> world`,
+			support: native,
+			want:    []markup.Segment{{Text: `Hello <break time="1.5s" /> world`}},
+		},
+		{
+			name:    "splits at breaks the model cannot render",
+			text:    `Hello <break time="5s"/> world <break strength="weak"/>`,
+			support: native,
+			want:    []markup.Segment{{Text: "Hello", Pause: 5 * time.Second}, {Text: `world <break time="0.25s" />`}},
+		},
+		{
+			name:    "falls back to silence for models without break support",
+			text:    `<speak>One.<break time="500ms"/>Two.</speak>`,
+			support: none,
+			want:    []markup.Segment{{Text: "One.", Pause: 500 * time.Millisecond}, {Text: "Two."}},
+		},
+		{
+			name:    "keeps phonemes only for models that accept them",
+			text:    `A <phoneme alphabet="ipa" ph="təˈmɑːtoʊ">tomato</phoneme>.`,
+			support: native,
+			want:    []markup.Segment{{Text: `A <phoneme alphabet="ipa" ph="təˈmɑːtoʊ">tomato</phoneme>.`}},
+		},
+		{
+			name:    "drops phonemes for other models",
+			text:    `A <phoneme ph="təˈmɑːtoʊ">tomato</phoneme>.`,
+			support: none,
+			want:    []markup.Segment{{Text: "A tomato."}},
+		},
+		{
+			name:    "expands say-as, sub and emphasis",
+			text:    `<say-as interpret-as="characters">API</say-as> v<say-as interpret-as="cardinal">1,021</say-as> ships on <say-as interpret-as="date" format="mdy">03/21/2024</say-as> for the <say-as interpret-as="ordinal">2nd</say-as> time, <sub alias="as soon as possible">ASAP</sub>, <emphasis level="strong">really</emphasis>`,
+			support: none,
+			want:    []markup.Segment{{Text: "A P I vone thousand twenty-one ships on March twenty-first, twenty twenty-four for the second time, as soon as possible, REALLY"}},
+		},
+		{
+			name:    "switches voices",
+			text:    `Narrator here. <voice name="villain">Hello!</voice> Back again.`,
+			support: native,
+			want:    []markup.Segment{{Text: "Narrator here."}, {Text: "Hello!", Voice: "villain"}, {Text: "Back again."}},
+		},
+		{
+			name:  "rejects unknown tags",
+			text:  `Hello <whisper>there</whisper>`,
+			error: "unsupported tag <whisper> at offset 6",
+		},
+		{
+			name:  "rejects unclosed tags",
+			text:  `Hello <emphasis>there`,
+			error: "<emphasis> is never closed",
+		},
+		{
+			name:  "rejects mismatched tags",
+			text:  `<voice name="a"><emphasis>there</voice></emphasis>`,
+			error: "unexpected </voice> at offset 31",
+		},
+		{
+			name:  "rejects bad attributes",
+			text:  `<break time="soon"/>`,
+			error: `<break> at offset 0: invalid time "soon"`,
+		},
+		{
+			name:  "rejects unknown say-as types",
+			text:  `<say-as interpret-as="money">5</say-as>`,
+			error: `<say-as> at offset 0: unsupported interpret-as "money"`,
+		},
+		{
+			name:  "rejects digits other than 0 to 9",
+			text:  `<say-as interpret-as="digits">٣</say-as>`,
+			error: `<say-as> at offset 32: "٣" has a digit '٣' that isn't 0 to 9`,
+		},
+		{
+			name:  "rejects full-width telephone digits",
+			text:  `<say-as interpret-as="telephone">555-１２３</say-as>`,
+			error: `<say-as> at offset 46: "555-１２３" has a digit '１' that isn't 0 to 9`,
+		},
+		{
+			name:  "rejects cardinals too large to read",
+			text:  `<say-as interpret-as="cardinal">-9223372036854775808</say-as>`,
+			error: `<say-as> at offset 52: "-9223372036854775808" is too large, numbers must be under a quintillion`,
+		},
+		{
+			name:  "rejects ordinals too large to read",
+			text:  `<say-as interpret-as="ordinal">1000000000000000000th</say-as>`,
+			error: `<say-as> at offset 52: "1000000000000000000" is too large, numbers must be under a quintillion`,
+		},
+		{
+			name:    "reads telephone numbers digit by digit",
+			text:    `<say-as interpret-as="telephone">555-0199</say-as>`,
+			support: none,
+			want:    []markup.Segment{{Text: "five five five zero one nine nine"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := markup.Compile(tt.text, tt.support)
+			if tt.error != "" {
+				assert.EqualError(t, err, tt.error)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestContainsAndStrip(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, markup.Contains("if a < b and c > d"))
+	assert.False(t, markup.Contains("<b>bold</b>"))
+	assert.True(t, markup.Contains(`Wait <break time="1s"/>`))
+	assert.Equal(t, "Wait  for it <b>", markup.Strip(`Wait <break time="1s"/> for <emphasis>it</emphasis> <b>`))
+}
+
+func TestMapText(t *testing.T) {
+	t.Parallel()
+
+	upper := func(s string) string { return strings.ToUpper(s) }
+	assert.Equal(t,
+		`HELLO <break time="1s"/> <say-as interpret-as="date">2024-01-02</say-as> <emphasis>THERE</emphasis> <B>`,
+		markup.MapText(`hello <break time="1s"/> <say-as interpret-as="date">2024-01-02</say-as> <emphasis>there</emphasis> <b>`, upper))
+}
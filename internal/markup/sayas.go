@@ -0,0 +1,177 @@
+package markup
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// interpreters expand say-as content into words, keyed by interpret-as
+var interpreters = map[string]func(content, format string) (string, error){
+	"characters": spellOut,
+	"spell-out":  spellOut,
+	"cardinal":   cardinal,
+	"number":     cardinal,
+	"ordinal":    ordinal,
+	"digits":     digits,
+	"telephone":  digits,
+	"date":       date,
+}
+
+func spellOut(content, _ string) (string, error) {
+	var parts []string
+	for _, r := range content {
+		if !unicode.IsSpace(r) {
+			parts = append(parts, string(r))
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+func digits(content, _ string) (string, error) {
+	var parts []string
+	for _, r := range content {
+		switch {
+		case '0' <= r && r <= '9':
+			parts = append(parts, ones[r-'0'])
+		case unicode.IsDigit(r):
+			return "", fmt.Errorf("%q has a digit %q that isn't 0 to 9", content, r)
+		}
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("%q has no digits", content)
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// maxNumber bounds cardinals and ordinals to what numberToWords has scale words for
+const maxNumber = 1_000_000_000_000_000_000
+
+func parseNumber(content string) (int64, error) {
+	n, err := strconv.ParseInt(strings.ReplaceAll(content, ",", ""), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a whole number", content)
+	}
+	if n <= -maxNumber || n >= maxNumber {
+		return 0, fmt.Errorf("%q is too large, numbers must be under a quintillion", content)
+	}
+	return n, nil
+}
+
+func cardinal(content, _ string) (string, error) {
+	n, err := parseNumber(content)
+	if err != nil {
+		return "", err
+	}
+	return numberToWords(n), nil
+}
+
+func ordinal(content, _ string) (string, error) {
+	n, err := parseNumber(strings.TrimRight(content, "stndrh"))
+	if err != nil {
+		return "", err
+	}
+	return ordinalWords(n), nil
+}
+
+var dateFormats = map[string]string{
+	"":    "2006-01-02",
+	"ymd": "2006-01-02",
+	"mdy": "01/02/2006",
+	"dmy": "02/01/2006",
+}
+
+func date(content, format string) (string, error) {
+	layout, ok := dateFormats[format]
+	if !ok {
+		return "", fmt.Errorf("unsupported date format %q", format)
+	}
+	t, err := time.Parse(layout, content)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a %s date", content, layout)
+	}
+	return fmt.Sprintf("%s %s, %s", t.Month(), ordinalWords(int64(t.Day())), yearWords(t.Year())), nil
+}
+
+var (
+	ones = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	tens   = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	scales = []string{"", "thousand", "million", "billion", "trillion", "quadrillion", "quintillion"}
+)
+
+func numberToWords(n int64) string {
+	if n < 0 {
+		return "minus " + numberToWords(-n)
+	}
+	if n < 20 {
+		return ones[n]
+	}
+	var groups []string
+	for scale := 0; n > 0; scale++ {
+		if group := n % 1000; group > 0 {
+			words := hundreds(group)
+			if scales[scale] != "" {
+				words += " " + scales[scale]
+			}
+			groups = append([]string{words}, groups...)
+		}
+		n /= 1000
+	}
+	return strings.Join(groups, " ")
+}
+
+func hundreds(n int64) string {
+	var parts []string
+	if n >= 100 {
+		parts = append(parts, ones[n/100]+" hundred")
+		n %= 100
+	}
+	switch {
+	case n == 0:
+	case n < 20:
+		parts = append(parts, ones[n])
+	case n%10 == 0:
+		parts = append(parts, tens[n/10])
+	default:
+		parts = append(parts, tens[n/10]+"-"+ones[n%10])
+	}
+	return strings.Join(parts, " ")
+}
+
+var irregularOrdinals = map[string]string{
+	"one": "first", "two": "second", "three": "third", "five": "fifth",
+	"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+}
+
+func ordinalWords(n int64) string {
+	words := numberToWords(n)
+	i := strings.LastIndexAny(words, " -") + 1
+	last := words[i:]
+	switch {
+	case irregularOrdinals[last] != "":
+		last = irregularOrdinals[last]
+	case strings.HasSuffix(last, "y"):
+		last = strings.TrimSuffix(last, "y") + "ieth"
+	default:
+		last += "th"
+	}
+	return words[:i] + last
+}
+
+// yearWords reads years the way they are spoken, e.g. nineteen eighty-four
+func yearWords(year int) string {
+	if year < 1100 || year >= 10000 || (year >= 2000 && year < 2010) || year%1000 < 10 {
+		return numberToWords(int64(year))
+	}
+	if year%100 == 0 {
+		return numberToWords(int64(year/100)) + " hundred"
+	}
+	low := numberToWords(int64(year % 100))
+	if year%100 < 10 {
+		low = "oh " + low
+	}
+	return numberToWords(int64(year/100)) + " " + low
+}
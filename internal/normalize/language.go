@@ -0,0 +1,224 @@
+package normalize
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// currency names the major and minor units of a currency symbol
+type currency struct {
+	one, many           string
+	minorOne, minorMany string
+}
+
+// language is a rule set for reading symbols, units and dates in one language
+type language struct {
+	dot, at, and, percent string
+	// thousands and decimal are the number separators, e.g. 1,250.50 in English
+	thousands, decimal string
+	currencies         map[string]currency
+	units              map[string]string
+	months             [12]string
+	// date formats a day, month name and year
+	date func(day int, month string, year int) string
+	// datePattern matches the numeric date form common in the language
+	datePattern *regexp.Regexp
+	// dateOrder maps datePattern's groups to day, month and year
+	dateOrder [3]int
+}
+
+var languages = map[string]*language{
+	"en": {
+		dot: "dot", at: "at", and: "and", percent: "percent", thousands: ",", decimal: ".",
+		currencies: map[string]currency{
+			"$": {"dollar", "dollars", "cent", "cents"},
+			"€": {"euro", "euros", "cent", "cents"},
+			"£": {"pound", "pounds", "penny", "pence"},
+			"¥": {"yen", "yen", "", ""},
+		},
+		units: map[string]string{
+			"km": "kilometers", "m": "meters", "cm": "centimeters", "mm": "millimeters",
+			"mi": "miles", "ft": "feet",
+			"kg": "kilograms", "g": "grams", "mg": "milligrams", "lb": "pounds", "lbs": "pounds",
+			"km/h": "kilometers per hour", "mph": "miles per hour",
+			"ms": "milliseconds", "s": "seconds", "min": "minutes", "h": "hours",
+			"KB": "kilobytes", "MB": "megabytes", "GB": "gigabytes", "TB": "terabytes",
+			"°C": "degrees Celsius", "°F": "degrees Fahrenheit",
+		},
+		months: [12]string{"January", "February", "March", "April", "May", "June", "July",
+			"August", "September", "October", "November", "December"},
+		date: func(day int, month string, year int) string {
+			return fmt.Sprintf("%s %d, %d", month, day, year)
+		},
+		datePattern: regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})/(\d{4})\b`),
+		dateOrder:   [3]int{2, 1, 3}, // month/day/year
+	},
+	"de": {
+		dot: "Punkt", at: "ät", and: "und", percent: "Prozent", thousands: ".", decimal: ",",
+		currencies: map[string]currency{
+			"$": {"Dollar", "Dollar", "Cent", "Cent"},
+			"€": {"Euro", "Euro", "Cent", "Cent"},
+			"£": {"Pfund", "Pfund", "Penny", "Pence"},
+			"¥": {"Yen", "Yen", "", ""},
+		},
+		units: map[string]string{
+			"km": "Kilometer", "m": "Meter", "cm": "Zentimeter", "mm": "Millimeter",
+			"kg": "Kilogramm", "g": "Gramm", "mg": "Milligramm", "km/h": "Kilometer pro Stunde",
+			"ms": "Millisekunden", "s": "Sekunden", "min": "Minuten", "h": "Stunden",
+			"KB": "Kilobyte", "MB": "Megabyte", "GB": "Gigabyte", "TB": "Terabyte",
+			"°C": "Grad Celsius", "°F": "Grad Fahrenheit",
+		},
+		months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli",
+			"August", "September", "Oktober", "November", "Dezember"},
+		date: func(day int, month string, year int) string {
+			return fmt.Sprintf("%d. %s %d", day, month, year)
+		},
+		datePattern: regexp.MustCompile(`\b(\d{1,2})\.(\d{1,2})\.(\d{4})\b`),
+		dateOrder:   [3]int{1, 2, 3}, // day.month.year
+	},
+}
+
+var (
+	urlRe     = regexp.MustCompile(`\b(?:https?://|www\.)[^\s<>"')\]]+`)
+	emailRe   = regexp.MustCompile(`\b[\w.+-]+@[\w-]+(?:\.[\w-]+)+\b`)
+	percentRe = regexp.MustCompile(`(\d)\s?%`)
+	isoDateRe = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	ampRe     = regexp.MustCompile(`\s&\s`)
+)
+
+func (l *language) pipeline() Pipeline {
+	return Pipeline{
+		Func(l.urls),
+		Func(l.emails),
+		Func(l.currencyRule()),
+		Func(l.dates),
+		Func(func(s string) string { return percentRe.ReplaceAllString(s, "$1 "+l.percent) }),
+		Func(l.unitsRule()),
+		Func(func(s string) string { return ampRe.ReplaceAllString(s, " "+l.and+" ") }),
+	}
+}
+
+// speakDomain reads a host name aloud, e.g. example.com becomes "example dot com"
+func (l *language) speakDomain(host string) string {
+	return strings.Join(strings.Split(host, "."), " "+l.dot+" ")
+}
+
+// urls shortens links to the domain they point at
+func (l *language) urls(text string) string {
+	return urlRe.ReplaceAllStringFunc(text, func(u string) string {
+		trailing := ""
+		for strings.ContainsAny(u[len(u)-1:], ".,;:!?") {
+			trailing = u[len(u)-1:] + trailing
+			u = u[:len(u)-1]
+		}
+		host := strings.TrimPrefix(strings.TrimPrefix(u, "http://"), "https://")
+		host, _, _ = strings.Cut(host, "/")
+		host, _, _ = strings.Cut(host, "?")
+		host, _, _ = strings.Cut(host, ":")
+		host = strings.TrimPrefix(host, "www.")
+		return l.speakDomain(host) + trailing
+	})
+}
+
+func (l *language) emails(text string) string {
+	return emailRe.ReplaceAllStringFunc(text, func(e string) string {
+		user, host, _ := strings.Cut(e, "@")
+		return l.speakDomain(user) + " " + l.at + " " + l.speakDomain(host)
+	})
+}
+
+// currencyRule reads amounts with the symbol before or after the number, e.g. $5.50 or 5,50 €
+func (l *language) currencyRule() func(string) string {
+	amount := `(\d{1,3}(?:` + regexp.QuoteMeta(l.thousands) + `\d{3})+|\d+)(?:` + regexp.QuoteMeta(l.decimal) + `(\d{2}))?`
+	prefix := regexp.MustCompile(`([$€£¥])\s?` + amount + `\b`)
+	suffix := regexp.MustCompile(`\b` + amount + `\s?([$€£¥])`)
+	return func(text string) string {
+		text = prefix.ReplaceAllStringFunc(text, func(m string) string {
+			p := prefix.FindStringSubmatch(m)
+			return l.speakAmount(p[1], p[2], p[3], m)
+		})
+		return suffix.ReplaceAllStringFunc(text, func(m string) string {
+			p := suffix.FindStringSubmatch(m)
+			return l.speakAmount(p[3], p[1], p[2], m)
+		})
+	}
+}
+
+func (l *language) speakAmount(symbol, major, minor, original string) string {
+	cur, ok := l.currencies[symbol]
+	if !ok {
+		return original
+	}
+	name := cur.many
+	if major == "1" {
+		name = cur.one
+	}
+	out := major + " " + name
+	if minor = strings.TrimLeft(minor, "0"); minor != "" && cur.minorMany != "" {
+		minorName := cur.minorMany
+		if minor == "1" {
+			minorName = cur.minorOne
+		}
+		out += " " + l.and + " " + minor + " " + minorName
+	}
+	return out
+}
+
+func (l *language) spokenDate(day, month, year int) (string, bool) {
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if month < 1 || month > 12 || t.Day() != day {
+		return "", false
+	}
+	return l.date(day, l.months[month-1], year), true
+}
+
+func (l *language) dates(text string) string {
+	text = isoDateRe.ReplaceAllStringFunc(text, func(m string) string {
+		p := isoDateRe.FindStringSubmatch(m)
+		year, _ := strconv.Atoi(p[1])
+		month, _ := strconv.Atoi(p[2])
+		day, _ := strconv.Atoi(p[3])
+		if s, ok := l.spokenDate(day, month, year); ok {
+			return s
+		}
+		return m
+	})
+	return l.datePattern.ReplaceAllStringFunc(text, func(m string) string {
+		p := l.datePattern.FindStringSubmatch(m)
+		day, _ := strconv.Atoi(p[l.dateOrder[0]])
+		month, _ := strconv.Atoi(p[l.dateOrder[1]])
+		year, _ := strconv.Atoi(p[l.dateOrder[2]])
+		if s, ok := l.spokenDate(day, month, year); ok {
+			return s
+		}
+		return m
+	})
+}
+
+// unitsRule expands unit abbreviations that directly follow a number
+func (l *language) unitsRule() func(string) string {
+	var names []string
+	for unit := range l.units {
+		names = append(names, regexp.QuoteMeta(unit))
+	}
+	// Longest first so km/h wins over km
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	re := regexp.MustCompile(`(\d)\s?(` + strings.Join(names, "|") + `)(?:\b|$|[\s.,;:!?)])`)
+	return func(text string) string {
+		return re.ReplaceAllStringFunc(text, func(m string) string {
+			p := re.FindStringSubmatch(m)
+			rest := strings.TrimPrefix(m[len(p[1]):], " ")
+			rest = strings.TrimPrefix(rest, p[2])
+			return p[1] + " " + l.units[p[2]] + rest
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package normalize
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Normalizer rewrites text into the form it should be spoken in
+type Normalizer interface {
+	Normalize(text string) string
+}
+
+// Func adapts a function to a Normalizer
+type Func func(string) string
+
+// Normalize calls f(text)
+func (f Func) Normalize(text string) string {
+	return f(text)
+}
+
+// Pipeline runs normalizers in order
+type Pipeline []Normalizer
+
+// Normalize applies each stage of the pipeline to text
+func (p Pipeline) Normalize(text string) string {
+	for _, n := range p {
+		text = n.Normalize(text)
+	}
+	return text
+}
+
+// Options selects the stages of a pipeline
+type Options struct {
+	// Language picks a built-in rule set, empty or "none" disables it
+	Language string
+	// ReplacementsFile is a list of regular expression replacements applied first
+	ReplacementsFile string
+	// SpellAcronyms spells out words written in capitals, e.g. API becomes A P I
+	SpellAcronyms bool
+}
+
+// New builds the pipeline for opts. Custom replacements run first so they can override the
+// language rules, and acronyms are spelled last.
+func New(opts Options) (Pipeline, error) {
+	var p Pipeline
+	if opts.ReplacementsFile != "" {
+		r, err := LoadReplacements(opts.ReplacementsFile)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, r)
+	}
+	if opts.Language != "" && opts.Language != "none" {
+		lang, ok := languages[strings.ToLower(opts.Language)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported language %q, expected one of: %s", opts.Language, strings.Join(Languages(), ", "))
+		}
+		p = append(p, lang.pipeline()...)
+	}
+	if opts.SpellAcronyms {
+		p = append(p, Acronyms(nil))
+	}
+	return p, nil
+}
+
+// Languages lists the built-in rule sets
+func Languages() []string {
+	var names []string
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Replacement rewrites every match of Pattern with Replacement, which may refer to groups as $1
+type Replacement struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Replacements applies a list of regular expression replacements in order
+type Replacements []Replacement
+
+// Normalize applies every replacement to text
+func (r Replacements) Normalize(text string) string {
+	for _, rep := range r {
+		text = rep.Pattern.ReplaceAllString(text, rep.Replacement)
+	}
+	return text
+}
+
+// LoadReplacements reads a replacement list, one `pattern => replacement` per line.
+// Blank lines and lines starting with # are ignored.
+func LoadReplacements(path string) (Replacements, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replacements: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			slog.Warn("error closing file", "path", path, "err", closeErr)
+		}
+	}()
+	return ParseReplacements(f)
+}
+
+// ParseReplacements reads a replacement list from r
+func ParseReplacements(r io.Reader) (Replacements, error) {
+	var out Replacements
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, replacement, ok := strings.Cut(line, "=>")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("line %d: expected pattern => replacement", n)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		out = append(out, Replacement{Pattern: re, Replacement: strings.TrimSpace(replacement)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read replacements: %w", err)
+	}
+	return out, nil
+}
+
+var acronymRe = regexp.MustCompile(`\b([A-Z]{2,6})(s?)\b`)
+
+// pronounceable acronyms are read as words rather than spelled out
+var pronounceable = []string{"NASA", "NATO", "UNESCO", "UNICEF", "LASER", "RADAR", "SCUBA", "JPEG", "GIF", "FAQ", "ASAP", "OK", "II", "III", "IV", "VI", "VII", "VIII", "IX", "XI", "XII"}
+
+// Acronyms spells out words written in capitals, except for those in keep and a list of
+// acronyms that are normally pronounced as words
+func Acronyms(keep []string) Normalizer {
+	skip := map[string]bool{}
+	for _, k := range append(pronounceable, keep...) {
+		skip[strings.ToUpper(k)] = true
+	}
+	return Func(func(text string) string {
+		return acronymRe.ReplaceAllStringFunc(text, func(m string) string {
+			parts := acronymRe.FindStringSubmatch(m)
+			if skip[parts[1]] {
+				return m
+			}
+			return strings.Join(strings.Split(parts[1], ""), " ") + parts[2]
+		})
+	})
+}
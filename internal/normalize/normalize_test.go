@@ -0,0 +1,91 @@
+package normalize_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/normalize"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts normalize.Options
+		in   string
+		want string
+	}{
+		{
+			name: "english links and emails",
+			opts: normalize.Options{Language: "en"},
+			in:   "See https://www.example.com/docs?page=2, or mail jane.doe@example.co.uk.",
+			want: "See example dot com, or mail jane dot doe at example dot co dot uk.",
+		},
+		{
+			name: "english currency, percentages and dates",
+			opts: normalize.Options{Language: "en"},
+			in:   "It cost $1,250.05 (up 5% on 2024-03-21) and £1 on 12/25/2023.",
+			want: "It cost 1,250 dollars and 5 cents (up 5 percent on March 21, 2024) and 1 pound on December 25, 2023.",
+		},
+		{
+			name: "english units",
+			opts: normalize.Options{Language: "en"},
+			in:   "Drive 5km at 80 km/h, it takes 3h & uses 2GB. Then 5 minutes.",
+			want: "Drive 5 kilometers at 80 kilometers per hour, it takes 3 hours and uses 2 gigabytes. Then 5 minutes.",
+		},
+		{
+			name: "german rules",
+			opts: normalize.Options{Language: "de"},
+			in:   "Am 24.12.2024 kostet es 5€ statt €7,50 bei 20°C.",
+			want: "Am 24. Dezember 2024 kostet es 5 Euro statt 7 Euro und 50 Cent bei 20 Grad Celsius.",
+		},
+		{
+			name: "acronyms are spelled out when requested",
+			opts: normalize.Options{SpellAcronyms: true},
+			in:   "The API and SDKs from NASA are OK.",
+			want: "The A P I and S D Ks from NASA are OK.",
+		},
+		{
+			name: "no rules leaves text alone",
+			opts: normalize.Options{Language: "none"},
+			in:   "$5 at https://example.com",
+			want: "$5 at https://example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := normalize.New(tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p.Normalize(tt.in))
+		})
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := normalize.New(normalize.Options{Language: "xx"})
+	assert.EqualError(t, err, `unsupported language "xx", expected one of: de, en`)
+
+	_, err = normalize.ParseReplacements(strings.NewReader("# comment\nno arrow here"))
+	assert.EqualError(t, err, "line 2: expected pattern => replacement")
+
+	_, err = normalize.ParseReplacements(strings.NewReader("([a-z => x"))
+	assert.ErrorContains(t, err, "line 1: error parsing regexp")
+}
+
+func TestReplacementsRunFirst(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "replacements.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# house style\n\\bk8s\\b => Kubernetes\n\\$(\\d+)k => $$${1},000\n"), 0600))
+
+	p, err := normalize.New(normalize.Options{Language: "en", ReplacementsFile: path})
+	require.NoError(t, err)
+	assert.Equal(t, "Kubernetes costs 5,000 dollars", p.Normalize("k8s costs $5k"))
+}
@@ -0,0 +1,98 @@
+// Package playback plays audio through a player program installed on the system: ffplay or mpv for any format,
+// paplay (PulseAudio), pw-play (PipeWire) or aplay (ALSA) for WAV, and afplay on macOS
+package playback
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrNoPlayer is returned when none of the known players is installed
+var ErrNoPlayer = errors.New("no audio player found: install ffplay (ffmpeg) or mpv, or for WAV paplay, pw-play or aplay")
+
+// Player is a program that plays audio read from stdin, or from a file
+type Player struct {
+	// Args run the player, Args[0] being the program
+	Args []string
+	// Formats are the formats the player understands
+	Formats []audio.Format
+	// File is set for players that can't read stdin. The audio is written to a temporary file named after Args.
+	File bool
+}
+
+// players are tried in order by Find
+var players = []Player{
+	{Args: []string{"ffplay", "-nodisp", "-autoexit", "-loglevel", "error", "-i", "-"}, Formats: []audio.Format{audio.MP3, audio.WAV}},
+	{Args: []string{"mpv", "--no-video", "--really-quiet", "-"}, Formats: []audio.Format{audio.MP3, audio.WAV}},
+	{Args: []string{"paplay"}, Formats: []audio.Format{audio.WAV}},
+	{Args: []string{"pw-play"}, Formats: []audio.Format{audio.WAV}, File: true},
+	{Args: []string{"aplay", "-q", "-"}, Formats: []audio.Format{audio.WAV}},
+	{Args: []string{"afplay"}, Formats: []audio.Format{audio.MP3, audio.WAV}, File: true},
+}
+
+// Find returns the first installed player that can play the format
+func Find(format audio.Format) (*Player, error) {
+	for _, p := range players {
+		if !slices.Contains(p.Formats, format) {
+			continue
+		}
+		if _, err := exec.LookPath(p.Args[0]); err == nil {
+			return &p, nil
+		}
+	}
+	return nil, ErrNoPlayer
+}
+
+// Play plays the audio with the first installed player that can play its format
+func Play(ctx context.Context, data []byte) error {
+	p, err := Find(audio.Detect(data))
+	if err != nil {
+		return err
+	}
+	return p.Play(ctx, data)
+}
+
+// Play plays the audio, returning once it has finished or ctx is cancelled
+func (p *Player) Play(ctx context.Context, data []byte) (err error) {
+	format := audio.Detect(data)
+	if !slices.Contains(p.Formats, format) {
+		return fmt.Errorf("%s cannot play %s audio", p.Args[0], format)
+	}
+	args := p.Args[1:]
+	var stdin io.Reader = bytes.NewReader(data)
+	if p.File {
+		dir, tErr := os.MkdirTemp("", "chatter-play")
+		if tErr != nil {
+			return tErr
+		}
+		defer func() { err = errors.Join(err, os.RemoveAll(dir)) }()
+		name := filepath.Join(dir, "audio."+string(format))
+		if err = os.WriteFile(name, data, 0600); err != nil {
+			return err
+		}
+		args, stdin = append(slices.Clone(args), name), nil
+	}
+	cmd := exec.CommandContext(ctx, p.Args[0], args...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s failed: %w: %s", p.Args[0], err, msg)
+		}
+		return fmt.Errorf("%s failed: %w", p.Args[0], err)
+	}
+	return nil
+}
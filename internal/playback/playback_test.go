@@ -0,0 +1,72 @@
+package playback_test
+
+import (
+	"context"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/playback"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakePlayer installs an aplay on PATH that copies what it plays to the returned file
+func fakePlayer(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	played := filepath.Join(dir, "played")
+	script := "#!/bin/sh\nPATH=/usr/bin:/bin cat > " + played + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "aplay"), []byte(script), 0755))
+	t.Setenv("PATH", dir)
+	return played
+}
+
+func TestFind(t *testing.T) { // nolint:paralleltest
+	played := fakePlayer(t)
+
+	_, err := playback.Find(audio.MP3)
+	require.ErrorIs(t, err, playback.ErrNoPlayer, "aplay can't play mp3")
+
+	p, err := playback.Find(audio.WAV)
+	require.NoError(t, err)
+	assert.Equal(t, "aplay", p.Args[0])
+
+	wav := audio.EncodeWAV(audio.WAVFormat{AudioFormat: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16}, make([]byte, 320))
+	require.NoError(t, p.Play(context.Background(), wav))
+	data, err := os.ReadFile(played)
+	require.NoError(t, err)
+	assert.Equal(t, wav, data)
+
+	assert.EqualError(t, p.Play(context.Background(), []byte("ID3")), "aplay cannot play mp3 audio")
+}
+
+func TestPlayer_Play(t *testing.T) {
+	t.Parallel()
+
+	p := &playback.Player{Args: []string{"sh", "-c", "echo broken speaker >&2; exit 1"}, Formats: []audio.Format{audio.MP3}}
+	assert.EqualError(t, p.Play(context.Background(), []byte("ID3")), "sh failed: exit status 1: broken speaker")
+}
+
+func TestPlayer_PlayFile(t *testing.T) {
+	t.Parallel()
+
+	played := filepath.Join(t.TempDir(), "played")
+	p := &playback.Player{Args: []string{"sh", "-c", `cp "$0" ` + played}, Formats: []audio.Format{audio.MP3}, File: true}
+	require.NoError(t, p.Play(context.Background(), []byte("ID3 audio")))
+	data, err := os.ReadFile(played)
+	require.NoError(t, err)
+	assert.Equal(t, "ID3 audio", string(data))
+}
+
+func TestPlay(t *testing.T) { // nolint:paralleltest
+	played := fakePlayer(t)
+
+	wav := audio.EncodeWAV(audio.WAVFormat{AudioFormat: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16}, make([]byte, 320))
+	require.NoError(t, playback.Play(context.Background(), wav))
+	data, err := os.ReadFile(played)
+	require.NoError(t, err)
+	assert.Equal(t, wav, data)
+
+	require.ErrorIs(t, playback.Play(context.Background(), []byte("ID3")), playback.ErrNoPlayer)
+}
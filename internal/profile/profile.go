@@ -0,0 +1,190 @@
+// Package profile reads chatter's configuration file, which holds named profiles of default settings in YAML or TOML:
+//
+//	default_profile: work
+//	profiles:
+//	  work:
+//	    api_key_command: pass show elevenlabs
+//	    voice: 21m00Tcm4TlvDq8ikWAM
+//	    output_dir: ~/podcasts
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/sgerhardt/chatter/internal/config"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileNames are the file names searched for in the config directory, in order
+var FileNames = []string{"config.yaml", "config.yml", "config.toml"}
+
+// DefaultName is the profile used when the file names no default_profile
+const DefaultName = "default"
+
+// File is a configuration file
+type File struct {
+	Path           string             `yaml:"-" toml:"-"`
+	DefaultProfile string             `yaml:"default_profile" toml:"default_profile"`
+	Profiles       map[string]Profile `yaml:"profiles" toml:"profiles"`
+}
+
+// Profile is a named set of defaults. Anything left empty falls back to chatter's defaults.
+type Profile struct {
+	// APIKey is env:NAME to read an environment variable, file:PATH to read a file, or the key itself
+	APIKey string `yaml:"api_key,omitempty" toml:"api_key"`
+	// APIKeyCommand is run to print the key, e.g. pass show elevenlabs, when APIKey is empty
+	APIKeyCommand  string                `yaml:"api_key_command,omitempty" toml:"api_key_command"`
+	Provider       string                `yaml:"provider,omitempty" toml:"provider"`
+	ProviderURL    string                `yaml:"provider_url,omitempty" toml:"provider_url"`
+	Command        string                `yaml:"command,omitempty" toml:"command"`
+	BaseURL        string                `yaml:"base_url,omitempty" toml:"base_url"`
+	Voice          string                `yaml:"voice,omitempty" toml:"voice"`
+	Model          string                `yaml:"model,omitempty" toml:"model"`
+	VoiceSettings  *config.VoiceSettings `yaml:"voice_settings,omitempty" toml:"voice_settings"`
+	OutputDir      string                `yaml:"output_dir,omitempty" toml:"output_dir"`
+	Format         string                `yaml:"format,omitempty" toml:"format"`
+	CharacterLimit int                   `yaml:"character_limit,omitempty" toml:"character_limit"`
+	Language       string                `yaml:"lang,omitempty" toml:"lang"`
+}
+
+// Dir returns chatter's directory in the user's config dir, $XDG_CONFIG_HOME/chatter on Linux
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chatter"), nil
+}
+
+// DefaultPath returns the first config file that exists in Dir, or "" when there is none
+func DefaultPath() string {
+	dir, err := Dir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		if _, err = os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Load reads a config file, as TOML when it ends in .toml and YAML otherwise. Unknown keys are errors.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		md, tErr := toml.Decode(string(data), f)
+		if tErr != nil {
+			return nil, fmt.Errorf("%s: %w", path, tErr)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("%s: unknown key %s", path, undecoded[0])
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	f.Path = path
+	return f, nil
+}
+
+// Names returns the profile names in alphabetical order
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select returns the named profile, or when name is empty the default profile. A file without a default
+// profile selects an empty profile; naming a profile that doesn't exist is an error.
+func (f *File) Select(name string) (string, Profile, error) {
+	if name == "" {
+		name = f.DefaultProfile
+		if name == "" {
+			name = DefaultName
+			if _, ok := f.Profiles[name]; !ok {
+				return "", Profile{}, nil
+			}
+		}
+	}
+	p, ok := f.Profiles[name]
+	if !ok {
+		return "", Profile{}, fmt.Errorf("profile %q not found in %s", name, f.Path)
+	}
+	return name, p, nil
+}
+
+// ResolveAPIKey returns the key the profile refers to, running APIKeyCommand when there is no APIKey
+func (p Profile) ResolveAPIKey() (string, error) {
+	if p.APIKey == "" && p.APIKeyCommand != "" {
+		return runKeyCommand(p.APIKeyCommand)
+	}
+	if name, ok := strings.CutPrefix(p.APIKey, "env:"); ok {
+		return os.Getenv(name), nil
+	}
+	if path, ok := strings.CutPrefix(p.APIKey, "file:"); ok {
+		data, err := os.ReadFile(ExpandHome(path))
+		if err != nil {
+			return "", fmt.Errorf("failed to read api_key: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return p.APIKey, nil
+}
+
+// runKeyCommand runs command and returns the first line it prints, as password managers print the secret first
+func runKeyCommand(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("api_key_command is empty")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("api_key_command failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("api_key_command failed: %w", err)
+	}
+	key, _, _ := strings.Cut(string(out), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", errors.New("api_key_command printed no key")
+	}
+	return key, nil
+}
+
+// ExpandHome replaces a leading ~ with the user's home directory
+func ExpandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok || (rest != "" && rest[0] != '/' && rest[0] != filepath.Separator) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + rest
+}
@@ -0,0 +1,148 @@
+package profile_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func write(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	want := profile.Profile{
+		APIKey:         "env:WORK_KEY",
+		Voice:          "voice1",
+		VoiceSettings:  &config.VoiceSettings{Stability: 0.5, SimilarityBoost: 0.75},
+		CharacterLimit: 5000,
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		error   string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `default_profile: work
+profiles:
+  work:
+    api_key: env:WORK_KEY
+    voice: voice1
+    voice_settings: {stability: 0.5, similarity_boost: 0.75}
+    character_limit: 5000
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `default_profile = "work"
+[profiles.work]
+api_key = "env:WORK_KEY"
+voice = "voice1"
+character_limit = 5000
+[profiles.work.voice_settings]
+stability = 0.5
+similarity_boost = 0.75
+`,
+		},
+		{
+			name:    "unknown yaml key",
+			file:    "config.yaml",
+			content: "profiles:\n  work:\n    voise: voice1\n",
+			error:   "field voise not found",
+		},
+		{
+			name:    "unknown toml key",
+			file:    "config.toml",
+			content: "[profiles.work]\nvoise = \"voice1\"\n",
+			error:   "unknown key profiles.work.voise",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			f, err := profile.Load(write(t, tt.file, tt.content))
+			if tt.error != "" {
+				assert.ErrorContains(t, err, tt.error)
+				return
+			}
+			require.NoError(t, err)
+			name, p, err := f.Select("")
+			require.NoError(t, err)
+			assert.Equal(t, "work", name)
+			assert.Equal(t, want, p)
+		})
+	}
+}
+
+func TestFile_Select(t *testing.T) {
+	t.Parallel()
+
+	f := &profile.File{Path: "config.yaml", Profiles: map[string]profile.Profile{"home": {Voice: "a"}}}
+	name, p, err := f.Select("")
+	require.NoError(t, err)
+	assert.Empty(t, name, "no default profile")
+	assert.Equal(t, profile.Profile{}, p)
+
+	_, p, err = f.Select("home")
+	require.NoError(t, err)
+	assert.Equal(t, "a", p.Voice)
+
+	_, _, err = f.Select("work")
+	assert.EqualError(t, err, `profile "work" not found in config.yaml`)
+
+	f.Profiles[profile.DefaultName] = profile.Profile{Voice: "b"}
+	name, p, err = f.Select("")
+	require.NoError(t, err)
+	assert.Equal(t, profile.DefaultName, name)
+	assert.Equal(t, "b", p.Voice)
+}
+
+func TestProfile_ResolveAPIKey(t *testing.T) {
+	t.Setenv("CHATTER_TEST_KEY", "from-env")
+	keyFile := write(t, "key", "from-file\n")
+
+	for ref, want := range map[string]string{
+		"env:CHATTER_TEST_KEY": "from-env",
+		"file:" + keyFile:      "from-file",
+		"literal":              "literal",
+	} {
+		got, err := profile.Profile{APIKey: ref}.ResolveAPIKey()
+		require.NoError(t, err)
+		assert.Equal(t, want, got, ref)
+	}
+
+	_, err := profile.Profile{APIKey: "file:" + keyFile + ".missing"}.ResolveAPIKey()
+	assert.Error(t, err)
+}
+
+func TestProfile_ResolveAPIKeyCommand(t *testing.T) {
+	t.Parallel()
+
+	keyFile := write(t, "pass", "from-command\nuser: someone\n")
+	got, err := profile.Profile{APIKeyCommand: "cat " + keyFile}.ResolveAPIKey()
+	require.NoError(t, err)
+	assert.Equal(t, "from-command", got, "first line only")
+
+	_, err = profile.Profile{APIKeyCommand: "cat " + keyFile + ".missing"}.ResolveAPIKey()
+	assert.ErrorContains(t, err, "api_key_command failed")
+
+	_, err = profile.Profile{APIKeyCommand: "true"}.ResolveAPIKey()
+	assert.ErrorContains(t, err, "printed no key")
+
+	_, err = profile.Profile{APIKeyCommand: " \t "}.ResolveAPIKey()
+	assert.EqualError(t, err, "api_key_command is empty")
+}
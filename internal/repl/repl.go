@@ -0,0 +1,264 @@
+// Package repl is an interactive session for tuning how lines are voiced. Each line typed is synthesized and played
+// straight away; :commands change the voice, model and settings, replay, redo or save earlier takes and list the
+// session's history.
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Take is a line voiced during the session, with the settings it was voiced with
+type Take struct {
+	Text      string
+	VoiceID   string
+	ModelID   string
+	Settings  config.VoiceSettings
+	Rendering *client.Rendering
+}
+
+// Session holds the current settings and the takes voiced so far
+type Session struct {
+	// Config is the configuration each line is voiced with, changed by :commands
+	Config *config.AppConfig
+	// Render voices cfg.TextInput
+	Render func(cfg *config.AppConfig) (*client.Rendering, error)
+	// Play plays a take's audio. Takes aren't played when it is nil.
+	Play func(data []byte) error
+	// Out receives prompts, take summaries and errors
+	Out   io.Writer
+	takes []Take
+}
+
+// errQuit ends the session
+var errQuit = errors.New("quit")
+
+const help = `Type a line to voice and play it. Commands:
+  :voice <id>                  voice later lines with another voice
+  :model <id>                  and another model
+  :set <setting> <value>       stability, similarity or style (0 to 1), speed (0.7 to 1.2), boost (on or off)
+  :settings                    show the current voice, model and settings
+  :replay [n]                  play take n again, the last take by default
+  :redo [n]                    voice take n again with the current settings
+  :save [n] [file]             write take n to a file, by default take-<n>.<format> in the output directory
+  :history                     list the takes of this session
+  :help                        show this help
+  :quit                        leave, also Ctrl-D`
+
+// Run reads lines from in until it ends or :quit. Errors are reported and the session carries on.
+func (s *Session) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		s.printf("> ")
+		if !scanner.Scan() {
+			s.printf("\n")
+			return scanner.Err()
+		}
+		err := s.Execute(scanner.Text())
+		if errors.Is(err, errQuit) {
+			return nil
+		}
+		if err != nil {
+			s.printf("error: %v\n", err)
+		}
+	}
+}
+
+// Execute runs a command, or voices a line that isn't one
+func (s *Session) Execute(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	if !strings.HasPrefix(line, ":") {
+		return s.voice(line)
+	}
+	fields := strings.Fields(line[1:])
+	if len(fields) == 0 {
+		return errors.New("missing command, see :help")
+	}
+	name, args := fields[0], fields[1:]
+	switch name {
+	case "voice", "model":
+		if len(args) != 1 {
+			return fmt.Errorf(":%s takes one argument", name)
+		}
+		if name == "voice" {
+			s.Config.VoiceID = args[0]
+		} else {
+			s.Config.ModelID = args[0]
+		}
+		return nil
+	case "set":
+		if len(args) != 2 {
+			return errors.New(":set takes a setting and a value")
+		}
+		return s.set(args[0], args[1])
+	case "settings":
+		s.printf("%s\n", describe(s.Config.VoiceID, s.Config.ModelID, s.Config.VoiceSettings))
+		return nil
+	case "replay":
+		n, _, err := s.take(args, 1)
+		if err != nil {
+			return err
+		}
+		return s.play(s.takes[n-1])
+	case "redo":
+		n, _, err := s.take(args, 1)
+		if err != nil {
+			return err
+		}
+		return s.voice(s.takes[n-1].Text)
+	case "save":
+		n, rest, err := s.take(args, 2)
+		if err != nil {
+			return err
+		}
+		return s.save(n, rest)
+	case "history":
+		for i, t := range s.takes {
+			s.printf("%d  %s  %s\n", i+1, describe(t.VoiceID, t.ModelID, t.Settings), t.Text)
+		}
+		return nil
+	case "help":
+		s.printf("%s\n", help)
+		return nil
+	case "quit", "q", "exit":
+		return errQuit
+	}
+	return fmt.Errorf("unknown command :%s, see :help", name)
+}
+
+// Takes returns the takes voiced so far
+func (s *Session) Takes() []Take {
+	return s.takes
+}
+
+// voice synthesizes and plays a line with the current settings
+func (s *Session) voice(text string) error {
+	cfg := *s.Config
+	cfg.TextInput = text
+	r, err := s.Render(&cfg)
+	if err != nil {
+		return err
+	}
+	s.takes = append(s.takes, Take{Text: text, VoiceID: cfg.VoiceID, ModelID: cfg.ModelID, Settings: cfg.VoiceSettings, Rendering: r})
+	s.printf("take %d\n", len(s.takes))
+	return s.play(s.takes[len(s.takes)-1])
+}
+
+func (s *Session) play(t Take) error {
+	if s.Play == nil {
+		return nil
+	}
+	return s.Play(t.Rendering.Audio)
+}
+
+// take returns the number of the take named by the first argument, or of the last take when it isn't a number, and
+// the remaining arguments, of which there may be at most limit in all
+func (s *Session) take(args []string, limit int) (int, []string, error) {
+	if len(args) > limit {
+		return 0, nil, errors.New("too many arguments, see :help")
+	}
+	if len(s.takes) == 0 {
+		return 0, nil, errors.New("nothing has been voiced yet")
+	}
+	n := len(s.takes)
+	if len(args) > 0 {
+		if i, err := strconv.Atoi(args[0]); err == nil {
+			if i < 1 || i > len(s.takes) {
+				return 0, nil, fmt.Errorf("no take %d, there are %d", i, len(s.takes))
+			}
+			n, args = i, args[1:]
+		}
+	}
+	if len(args) > 0 && limit == 1 {
+		return 0, nil, fmt.Errorf("invalid take %q", args[0])
+	}
+	return n, args, nil
+}
+
+// save writes take n to the named file, or take-<n>.<format> in the output directory
+func (s *Session) save(n int, args []string) error {
+	t := s.takes[n-1]
+	name := filepath.Join(s.Config.OutputDir, fmt.Sprintf("take-%d.%s", n, t.Rendering.Format))
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if err := os.WriteFile(name, t.Rendering.Audio, 0644); err != nil {
+		return err
+	}
+	s.printf("saved %s\n", name)
+	return nil
+}
+
+// set changes one voice setting
+func (s *Session) set(name, value string) error {
+	settings := &s.Config.VoiceSettings
+	if name == "boost" {
+		switch value {
+		case "on", "true":
+			settings.UseSpeakerBoost = true
+		case "off", "false":
+			settings.UseSpeakerBoost = false
+		default:
+			return fmt.Errorf("boost is on or off, got %q", value)
+		}
+		return nil
+	}
+	if !slices.Contains([]string{"stability", "similarity", "style", "speed"}, name) {
+		return fmt.Errorf("unknown setting %q, expected stability, similarity, style, speed or boost", name)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", name, value)
+	}
+	switch name {
+	case "stability", "similarity", "style":
+		if v < 0 || v > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %g", name, v)
+		}
+		switch name {
+		case "stability":
+			settings.Stability = v
+		case "similarity":
+			settings.SimilarityBoost = v
+		default:
+			settings.Style = v
+		}
+	default:
+		if v < 0.7 || v > 1.2 {
+			return fmt.Errorf("speed must be between 0.7 and 1.2, got %g", v)
+		}
+		settings.Speed = v
+	}
+	return nil
+}
+
+// describe summarizes a voice, model and settings on one line
+func describe(voiceID, modelID string, s config.VoiceSettings) string {
+	if modelID == "" {
+		modelID = "default"
+	}
+	out := fmt.Sprintf("voice=%s model=%s stability=%g similarity=%g style=%g", voiceID, modelID, s.Stability, s.SimilarityBoost, s.Style)
+	if s.Speed != 0 {
+		out += fmt.Sprintf(" speed=%g", s.Speed)
+	}
+	if s.UseSpeakerBoost {
+		out += " boost=on"
+	}
+	return out
+}
+
+func (s *Session) printf(format string, args ...any) {
+	_, _ = fmt.Fprintf(s.Out, format, args...)
+}
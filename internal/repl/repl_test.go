@@ -0,0 +1,112 @@
+package repl_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/repl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newSession returns a session whose renderings are the text as mp3 bytes, recording what it played
+func newSession(t *testing.T) (*repl.Session, *[]string, *bytes.Buffer) {
+	t.Helper()
+	var played []string
+	var out bytes.Buffer
+	s := &repl.Session{
+		Config: &config.AppConfig{VoiceID: "alice", OutputDir: t.TempDir()},
+		Render: func(cfg *config.AppConfig) (*client.Rendering, error) {
+			if cfg.TextInput == "fail" {
+				return nil, errors.New("synthesis failed")
+			}
+			return &client.Rendering{Format: audio.MP3, Audio: []byte(cfg.VoiceID + ": " + cfg.TextInput)}, nil
+		},
+		Play: func(data []byte) error {
+			played = append(played, string(data))
+			return nil
+		},
+		Out: &out,
+	}
+	return s, &played, &out
+}
+
+func TestSession(t *testing.T) {
+	t.Parallel()
+
+	t.Run("voices and plays each line", func(t *testing.T) {
+		t.Parallel()
+		s, played, out := newSession(t)
+		require.NoError(t, s.Run(strings.NewReader("Hello\n\n:voice bob\nHello again\n:replay 1\n")))
+		assert.Equal(t, []string{"alice: Hello", "bob: Hello again", "alice: Hello"}, *played)
+		assert.Contains(t, out.String(), "take 2\n")
+		require.Len(t, s.Takes(), 2)
+		assert.Equal(t, "bob", s.Takes()[1].VoiceID)
+	})
+
+	t.Run("redoes a take with the current settings", func(t *testing.T) {
+		t.Parallel()
+		s, played, out := newSession(t)
+		for _, line := range []string{"Hello", ":set stability 0.3", ":set boost on", ":model eleven_turbo_v2_5", ":redo"} {
+			require.NoError(t, s.Execute(line), line)
+		}
+		assert.Equal(t, []string{"alice: Hello", "alice: Hello"}, *played)
+		takes := s.Takes()
+		require.Len(t, takes, 2)
+		assert.InDelta(t, 0.3, takes[1].Settings.Stability, 0.001)
+		assert.Equal(t, "eleven_turbo_v2_5", takes[1].ModelID)
+
+		require.NoError(t, s.Execute(":history"))
+		assert.Contains(t, out.String(), "1  voice=alice model=default stability=0 similarity=0 style=0  Hello\n")
+		assert.Contains(t, out.String(), "2  voice=alice model=eleven_turbo_v2_5 stability=0.3 similarity=0 style=0 boost=on  Hello\n")
+	})
+
+	t.Run("saves takes", func(t *testing.T) {
+		t.Parallel()
+		s, _, _ := newSession(t)
+		require.NoError(t, s.Execute("One"))
+		require.NoError(t, s.Execute("Two"))
+		require.NoError(t, s.Execute(":save 1"))
+		data, err := os.ReadFile(filepath.Join(s.Config.OutputDir, "take-1.mp3"))
+		require.NoError(t, err)
+		assert.Equal(t, "alice: One", string(data))
+
+		named := filepath.Join(t.TempDir(), "two.mp3")
+		require.NoError(t, s.Execute(":save "+named))
+		data, err = os.ReadFile(named)
+		require.NoError(t, err)
+		assert.Equal(t, "alice: Two", string(data))
+	})
+
+	t.Run("reports errors and carries on", func(t *testing.T) {
+		t.Parallel()
+		s, played, out := newSession(t)
+		require.NoError(t, s.Run(strings.NewReader(":replay\nfail\n:set speed 2\n:nope\nHello\n:quit\nIgnored\n")))
+		assert.Equal(t, `> error: nothing has been voiced yet
+> error: synthesis failed
+> error: speed must be between 0.7 and 1.2, got 2
+> error: unknown command :nope, see :help
+> take 1
+> `, out.String())
+		assert.Equal(t, []string{"alice: Hello"}, *played)
+
+		for line, want := range map[string]string{
+			":replay 3":        "no take 3, there are 1",
+			":replay last":     `invalid take "last"`,
+			":save 1 a b":      "too many arguments, see :help",
+			":set pitch 1":     `unknown setting "pitch", expected stability, similarity, style, speed or boost`,
+			":set stability x": `invalid stability "x"`,
+			":set boost maybe": `boost is on or off, got "maybe"`,
+			":voice":           ":voice takes one argument",
+			":":                "missing command, see :help",
+		} {
+			assert.EqualError(t, s.Execute(line), want, line)
+		}
+	})
+}
@@ -0,0 +1,235 @@
+package script
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/config"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultGap is the silence inserted between lines when none is configured
+const DefaultGap = 400 * time.Millisecond
+
+// maxSpeakerLength bounds the text before a colon that is treated as a speaker name
+const maxSpeakerLength = 40
+
+// Script is an ordered list of lines spoken by a cast of voices
+type Script struct {
+	Title string
+	Gap   time.Duration
+	Cast  map[string]Speaker
+	Lines []Line
+}
+
+// Speaker maps a character in the script to a voice
+type Speaker struct {
+	VoiceID       string               `json:"voice_id" yaml:"voice_id"`
+	VoiceSettings config.VoiceSettings `json:"voice_settings" yaml:"voice_settings"`
+}
+
+// Line is a single piece of dialogue. Gap overrides the script gap after this line when set.
+type Line struct {
+	Speaker string
+	Text    string
+	Gap     time.Duration
+}
+
+// file is the on-disk YAML/JSON form of a script or cast file
+type file struct {
+	Title string             `json:"title" yaml:"title"`
+	Gap   string             `json:"gap" yaml:"gap"`
+	Cast  map[string]Speaker `json:"cast" yaml:"cast"`
+	Lines []struct {
+		Speaker string `json:"speaker" yaml:"speaker"`
+		Text    string `json:"text" yaml:"text"`
+		Gap     string `json:"gap" yaml:"gap"`
+	} `json:"lines" yaml:"lines"`
+}
+
+// Load reads a script from disk. YAML and JSON files are decoded by extension, anything else is read as
+// plain text in `SPEAKER: line` form.
+func Load(path string) (*Script, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open script: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			slog.Warn("error closing file", "path", path, "err", closeErr)
+		}
+	}()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		var sf file
+		if err = decode(f, path, &sf); err != nil {
+			return nil, err
+		}
+		return sf.script()
+	default:
+		return ParseText(f)
+	}
+}
+
+// LoadCast reads a YAML/JSON cast file and merges it into s, overriding speakers already present
+func (s *Script) LoadCast(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open cast: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			slog.Warn("error closing file", "path", path, "err", closeErr)
+		}
+	}()
+
+	var sf file
+	if err = decode(f, path, &sf); err != nil {
+		return err
+	}
+	if sf.Gap != "" {
+		if s.Gap, err = time.ParseDuration(sf.Gap); err != nil {
+			return fmt.Errorf("invalid gap %q: %w", sf.Gap, err)
+		}
+	}
+	for name, speaker := range sf.Cast {
+		s.AddSpeaker(name, speaker)
+	}
+	return nil
+}
+
+func decode(r io.Reader, path string, v any) error {
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.NewDecoder(r).Decode(v)
+	} else {
+		err = yaml.NewDecoder(r).Decode(v)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to decode %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+func (sf *file) script() (*Script, error) {
+	s := &Script{Title: sf.Title, Gap: DefaultGap}
+	if sf.Gap != "" {
+		gap, err := time.ParseDuration(sf.Gap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gap %q: %w", sf.Gap, err)
+		}
+		s.Gap = gap
+	}
+	for name, speaker := range sf.Cast {
+		s.AddSpeaker(name, speaker)
+	}
+	for i, l := range sf.Lines {
+		line := Line{Speaker: normalize(l.Speaker), Text: strings.TrimSpace(l.Text)}
+		if l.Gap != "" {
+			gap, err := time.ParseDuration(l.Gap)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid gap %q: %w", i+1, l.Gap, err)
+			}
+			line.Gap = gap
+		}
+		s.Lines = append(s.Lines, line)
+	}
+	return s, nil
+}
+
+// ParseText reads a plain text script. Each line starts with the speaker's name followed by a colon;
+// lines without a speaker continue the previous line and lines starting with # are comments.
+// Names with spaces must be written in capitals, e.g. "DR SMITH: line".
+func ParseText(r io.Reader) (*Script, error) {
+	s := &Script{Gap: DefaultGap}
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if speaker, line, ok := strings.Cut(text, ":"); ok && isSpeaker(speaker) {
+			s.Lines = append(s.Lines, Line{Speaker: normalize(speaker), Text: strings.TrimSpace(line)})
+			continue
+		}
+		if len(s.Lines) == 0 {
+			return nil, fmt.Errorf("line %d: expected SPEAKER: text", n)
+		}
+		last := &s.Lines[len(s.Lines)-1]
+		last.Text = strings.TrimSpace(last.Text + " " + text)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	return s, nil
+}
+
+func isSpeaker(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" || len(s) > maxSpeakerLength {
+		return false
+	}
+	if strings.ContainsAny(s, ".,!?\"") {
+		return false
+	}
+	// Multi-word names must be in capitals, screenplay style, so "It says: go" reads as dialogue
+	return !strings.Contains(s, " ") || s == strings.ToUpper(s)
+}
+
+func normalize(speaker string) string {
+	return strings.ToUpper(strings.TrimSpace(speaker))
+}
+
+// AddSpeaker casts a voice for a speaker, replacing any previous casting
+func (s *Script) AddSpeaker(name string, speaker Speaker) {
+	if s.Cast == nil {
+		s.Cast = map[string]Speaker{}
+	}
+	s.Cast[normalize(name)] = speaker
+}
+
+// Speaker returns the casting for a speaker name
+func (s *Script) Speaker(name string) (Speaker, bool) {
+	speaker, ok := s.Cast[normalize(name)]
+	return speaker, ok
+}
+
+// GapAfter returns the silence to insert after line i
+func (s *Script) GapAfter(i int) time.Duration {
+	if s.Lines[i].Gap > 0 {
+		return s.Lines[i].Gap
+	}
+	return s.Gap
+}
+
+// Validate checks the script has lines and that every speaker has a voice
+func (s *Script) Validate() error {
+	if len(s.Lines) == 0 {
+		return errors.New("script has no lines")
+	}
+	var missing []string
+	seen := map[string]bool{}
+	for i, l := range s.Lines {
+		if l.Text == "" {
+			return fmt.Errorf("line %d: %s has no text", i+1, l.Speaker)
+		}
+		if sp, ok := s.Speaker(l.Speaker); (!ok || sp.VoiceID == "") && !seen[l.Speaker] {
+			missing = append(missing, l.Speaker)
+		}
+		seen[l.Speaker] = true
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no voice cast for: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
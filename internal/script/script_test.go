@@ -0,0 +1,89 @@
+package script_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/script"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []script.Line
+		error string
+	}{
+		{
+			name:  "reads speakers and joins continuation lines",
+			input: "# scene 1\nAlice: Hello there.\n\nBob: Hi Alice,\nhow are you?\n",
+			want: []script.Line{
+				{Speaker: "ALICE", Text: "Hello there."},
+				{Speaker: "BOB", Text: "Hi Alice, how are you?"},
+			},
+		},
+		{
+			name:  "keeps colons inside dialogue",
+			input: "ALICE: Meet me at 10: sharp.\nIt says: go.",
+			want:  []script.Line{{Speaker: "ALICE", Text: "Meet me at 10: sharp. It says: go."}},
+		},
+		{
+			name:  "errors when the first line has no speaker",
+			input: "Hello, there.",
+			error: "line 1: expected SPEAKER: text",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := script.ParseText(strings.NewReader(tt.input))
+			if tt.error != "" {
+				assert.EqualError(t, err, tt.error)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.Lines)
+			assert.Equal(t, script.DefaultGap, s.Gap)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "episode.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+title: Episode 1
+gap: 1s
+cast:
+  alice:
+    voice_id: voice-a
+    voice_settings: {stability: 0.5, similarity_boost: 0.75}
+lines:
+  - {speaker: alice, text: Hello, gap: 2s}
+  - {speaker: bob, text: Hi}
+`), 0600))
+	castPath := filepath.Join(dir, "cast.json")
+	require.NoError(t, os.WriteFile(castPath, []byte(`{"cast":{"Bob":{"voice_id":"voice-b"}}}`), 0600))
+
+	s, err := script.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Episode 1", s.Title)
+	assert.Equal(t, 2*time.Second, s.GapAfter(0))
+	assert.Equal(t, time.Second, s.GapAfter(1))
+	alice, ok := s.Speaker("Alice")
+	require.True(t, ok)
+	assert.Equal(t, script.Speaker{VoiceID: "voice-a", VoiceSettings: config.VoiceSettings{Stability: 0.5, SimilarityBoost: 0.75}}, alice)
+
+	assert.EqualError(t, s.Validate(), "no voice cast for: BOB")
+	require.NoError(t, s.LoadCast(castPath))
+	assert.NoError(t, s.Validate())
+}
@@ -0,0 +1,165 @@
+// Package secret stores the Eleven Labs API key in the system keyring (the Secret Service on Linux), falling back
+// to a passphrase-encrypted file where no keyring is reachable, such as headless servers.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	service = "chatter"
+	account = "xi-api-key"
+)
+
+// ErrNotFound is returned when no key is stored
+var ErrNotFound = errors.New("no API key stored")
+
+// Store holds a single API key
+type Store interface {
+	Get() (string, error)
+	Set(key string) error
+	Delete() error
+	// String describes where the key is kept
+	String() string
+}
+
+// Open returns the system keyring when it can be reached, and otherwise the encrypted file at path,
+// unlocked with the passphrase function
+func Open(path string, passphrase func() (string, error)) Store {
+	if _, err := keyring.Get(service, account); err == nil || errors.Is(err, keyring.ErrNotFound) {
+		return Keyring{}
+	}
+	return NewFile(path, passphrase)
+}
+
+// Keyring keeps the key in the system keyring
+type Keyring struct{}
+
+func (Keyring) Get() (string, error) {
+	key, err := keyring.Get(service, account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	return key, err
+}
+
+func (Keyring) Set(key string) error {
+	return keyring.Set(service, account, key)
+}
+
+func (Keyring) Delete() error {
+	err := keyring.Delete(service, account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (Keyring) String() string {
+	return "the system keyring"
+}
+
+// File keeps the key in a file encrypted with AES-256-GCM, under a key derived from a passphrase with scrypt
+type File struct {
+	path       string
+	passphrase func() (string, error)
+}
+
+// encryptedFile is the JSON stored by File
+type encryptedFile struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func NewFile(path string, passphrase func() (string, error)) *File {
+	return &File{path: path, passphrase: passphrase}
+}
+
+// cipher derives the AES-GCM cipher for salt from the passphrase
+func (f *File) cipher(salt []byte) (cipher.AEAD, error) {
+	passphrase, err := f.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, errors.New("a passphrase is required to encrypt the API key")
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (f *File) Get() (string, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	var enc encryptedFile
+	if err = json.Unmarshal(data, &enc); err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", f.path, err)
+	}
+	aead, err := f.cipher(enc.Salt)
+	if err != nil {
+		return "", err
+	}
+	key, err := aead.Open(nil, enc.Nonce, enc.Ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: wrong passphrase?", f.path)
+	}
+	return string(key), nil
+}
+
+func (f *File) Set(key string) error {
+	enc := encryptedFile{Salt: make([]byte, 16)}
+	if _, err := rand.Read(enc.Salt); err != nil {
+		return err
+	}
+	aead, err := f.cipher(enc.Salt)
+	if err != nil {
+		return err
+	}
+	enc.Nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(enc.Nonce); err != nil {
+		return err
+	}
+	enc.Ciphertext = aead.Seal(nil, enc.Nonce, []byte(key), nil)
+	data, err := json.Marshal(enc)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, data, 0600)
+}
+
+func (f *File) Delete() error {
+	err := os.Remove(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (f *File) String() string {
+	return "the encrypted file " + f.path
+}
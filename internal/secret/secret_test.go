@@ -0,0 +1,59 @@
+package secret_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func passphrase(p string) func() (string, error) {
+	return func() (string, error) { return p, nil }
+}
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "chatter", "credentials")
+	store := secret.NewFile(path, passphrase("correct horse"))
+
+	_, err := store.Get()
+	require.ErrorIs(t, err, secret.ErrNotFound)
+
+	require.NoError(t, store.Set("xi-key-1234"))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "xi-key-1234")
+
+	key, err := store.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "xi-key-1234", key)
+
+	_, err = secret.NewFile(path, passphrase("wrong")).Get()
+	assert.ErrorContains(t, err, "wrong passphrase")
+	assert.ErrorContains(t, secret.NewFile(path, passphrase("")).Set("x"), "passphrase is required")
+
+	require.NoError(t, store.Delete())
+	assert.ErrorIs(t, store.Delete(), secret.ErrNotFound)
+}
+
+func TestOpen(t *testing.T) { // nolint:paralleltest
+	keyring.MockInit()
+
+	store := secret.Open(filepath.Join(t.TempDir(), "credentials"), passphrase("unused"))
+	assert.Equal(t, "the system keyring", store.String())
+	_, err := store.Get()
+	require.ErrorIs(t, err, secret.ErrNotFound)
+	require.NoError(t, store.Set("xi-key-1234"))
+	key, err := store.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "xi-key-1234", key)
+	require.NoError(t, store.Delete())
+	assert.ErrorIs(t, store.Delete(), secret.ErrNotFound)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when a job's URL leads to the server's own network
+var ErrPrivateAddress = errors.New("private address")
+
+// fetchTimeout bounds fetching the page of a URL job
+const fetchTimeout = 30 * time.Second
+
+// NewPublicClient returns a client that refuses to connect to loopback, private and link-local addresses, for URLs
+// named by callers. The address is checked as it is dialed, after DNS resolution and on every redirect.
+func NewPublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 3 * time.Second, Control: refusePrivate}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 3 * time.Second,
+		},
+	}
+}
+
+// refusePrivate is a net.Dialer Control that fails connections to private addresses
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if isPrivate(ip) {
+		return fmt.Errorf("%w %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+func isPrivate(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// checkURL returns why a job can't use raw as the named field: it must be an absolute http or https URL, and
+// unless private URLs are allowed, mustn't name a private address outright
+func (s *Server) checkURL(field, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http or https URL", field)
+	}
+	if s.opts.AllowPrivateURLs {
+		return nil
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	ip, err := netip.ParseAddr(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || (err == nil && isPrivate(ip)) {
+		return fmt.Errorf("%s must not be a private address", field)
+	}
+	return nil
+}
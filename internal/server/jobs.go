@@ -0,0 +1,241 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/jobs"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// documentTypes are the uploads POST /v1/documents accepts
+var documentTypes = []string{"text/plain", "text/markdown", "text/html"}
+
+// spec returns the job spec for the options
+func (o SynthesisOptions) spec(kind string) jobs.Spec {
+	return jobs.Spec{
+		Kind:          kind,
+		VoiceID:       o.VoiceID,
+		ModelID:       o.ModelID,
+		VoiceSettings: o.VoiceSettings,
+		OutputFormat:  o.OutputFormat,
+		Tag:           o.Tag,
+	}
+}
+
+// pipelineFor builds the pipeline that voices a job
+func (s *Server) pipelineFor(spec jobs.Spec) (*client.Pipeline, error) {
+	cfg, err := s.jobConfig(SynthesisOptions{
+		VoiceID:       spec.VoiceID,
+		ModelID:       spec.ModelID,
+		VoiceSettings: spec.VoiceSettings,
+		OutputFormat:  spec.OutputFormat,
+		Tag:           spec.Tag,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch spec.Kind {
+	case jobs.KindText:
+		cfg.TextInput = spec.Text
+	case jobs.KindURL:
+		cfg.WebsiteURL = spec.URL
+	case jobs.KindDocument:
+		// HTML and markdown are parsed into text when the job is planned, plain text is spoken as it is
+		cfg.TextInput = string(spec.Document)
+	}
+	synth, err := client.NewSynthesizer(cfg, s.httpClient)
+	if err != nil {
+		return nil, err
+	}
+	p := client.NewPipeline(cfg, s.fetchClient, synth)
+	p.Ledger = s.opts.Ledger
+	return p, nil
+}
+
+func (s *Server) submitText(w http.ResponseWriter, r *http.Request) {
+	var req TextRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		writeError(w, http.StatusBadRequest, "text is required")
+		return
+	}
+	spec := req.SynthesisOptions.spec(jobs.KindText)
+	spec.Text = req.Text
+	s.submit(w, spec, req.SynthesisOptions, req.WebhookURL)
+}
+
+func (s *Server) submitURL(w http.ResponseWriter, r *http.Request) {
+	var req URLRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if err := s.checkURL("url", req.URL); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	spec := req.SynthesisOptions.spec(jobs.KindURL)
+	spec.URL = req.URL
+	s.submit(w, spec, req.SynthesisOptions, req.WebhookURL)
+}
+
+// submitDocument takes an uploaded text, markdown or HTML file as the body, with the options as query parameters
+func (s *Server) submitDocument(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !slices.Contains(documentTypes, mediaType) {
+		writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be one of "+strings.Join(documentTypes, ", "))
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if errors.As(err, new(*http.MaxBytesError)) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", s.opts.MaxBodyBytes))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(string(body)) == "" {
+		writeError(w, http.StatusBadRequest, "document is empty")
+		return
+	}
+	q := r.URL.Query()
+	opts := SynthesisOptions{VoiceID: q.Get("voice_id"), ModelID: q.Get("model_id"), OutputFormat: q.Get("output_format"),
+		Tag: q.Get("tag")}
+	spec := opts.spec(jobs.KindDocument)
+	spec.Document = body
+	spec.ContentType = mediaType
+	s.submit(w, spec, opts, q.Get("webhook_url"))
+}
+
+// submit validates and queues a job, answering 202 with its status
+func (s *Server) submit(w http.ResponseWriter, spec jobs.Spec, opts SynthesisOptions, webhookURL string) {
+	if _, err := s.jobConfig(opts); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if webhookURL != "" {
+		if err := s.checkURL("webhook_url", webhookURL); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err := s.store.Prune(time.Now().Add(-s.opts.Retention)); err != nil {
+		slog.Error("failed to prune old jobs", "err", err)
+	}
+	job, err := s.manager.Submit(spec, webhookURL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// job looks up the job named in the path, answering 404 when it doesn't exist
+func (s *Server) job(w http.ResponseWriter, r *http.Request) (*jobs.Job, bool) {
+	job, err := s.store.Get(r.PathValue("id"))
+	if errors.Is(err, jobs.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "job not found")
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return job, true
+}
+
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	if job, ok := s.job(w, r); ok {
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func (s *Server) cancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.manager.Cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeError(w, http.StatusNotFound, "job not found")
+	case errors.Is(err, jobs.ErrFinished):
+		writeError(w, http.StatusConflict, fmt.Sprintf("job has already %s", job.Status))
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func (s *Server) listArtifacts(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+	artifacts, err := s.store.Artifacts(job.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"artifacts": artifacts})
+}
+
+func (s *Server) getArtifact(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+	s.writeArtifact(w, job, r.PathValue("name"))
+}
+
+// getAudio serves the job's audio artifact, whatever its format
+func (s *Server) getAudio(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != jobs.StatusSucceeded {
+		writeError(w, http.StatusConflict, fmt.Sprintf("job is %s", job.Status))
+		return
+	}
+	artifacts, err := s.store.Artifacts(job.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, a := range artifacts {
+		if strings.HasPrefix(a.Name, "audio.") {
+			s.writeArtifact(w, job, a.Name)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "job has no audio")
+}
+
+func (s *Server) writeArtifact(w http.ResponseWriter, job *jobs.Job, name string) {
+	if job.Status != jobs.StatusSucceeded {
+		writeError(w, http.StatusConflict, fmt.Sprintf("job is %s", job.Status))
+		return
+	}
+	a, err := s.store.Artifact(job.ID, name)
+	if errors.Is(err, jobs.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "artifact not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s"`, job.ID, a.Name))
+	if _, err = w.Write(a.Data); err != nil {
+		slog.Warn("error writing artifact", "job", job.ID, "artifact", name, "err", err)
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"gopkg.in/yaml.v3"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Aliases map the OpenAI model and voice names callers send onto Eleven Labs models and voice IDs
+type Aliases struct {
+	Models map[string]string `yaml:"models"`
+	Voices map[string]string `yaml:"voices"`
+}
+
+// DefaultAliases maps OpenAI's models and voices onto similar Eleven Labs models and premade voices
+func DefaultAliases() Aliases {
+	return Aliases{
+		Models: map[string]string{
+			"tts-1":           "eleven_turbo_v2",
+			"tts-1-hd":        "eleven_multilingual_v2",
+			"gpt-4o-mini-tts": "eleven_multilingual_v2",
+		},
+		Voices: map[string]string{
+			"alloy":   "21m00Tcm4TlvDq8ikWAM", // Rachel
+			"echo":    "TxGEqnHWrfWFTfGW9XjX", // Josh
+			"fable":   "ErXwobaYiN019PkySvjV", // Antoni
+			"onyx":    "pNInz6obpgDQGcFmaJgB", // Adam
+			"nova":    "EXAVITQu4vr4xnSDxMaL", // Bella
+			"shimmer": "MF3mGyEYCl7XYWbV9V6O", // Elli
+		},
+	}
+}
+
+// LoadAliases reads a YAML alias file and adds its entries to the defaults, replacing any with the same name:
+//
+//	models:
+//	  tts-1: eleven_turbo_v2_5
+//	voices:
+//	  alloy: <voiceID>
+func LoadAliases(path string) (Aliases, error) {
+	aliases := DefaultAliases()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return aliases, err
+	}
+	var file Aliases
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return aliases, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, model := range file.Models {
+		aliases.Models[name] = model
+	}
+	for name, voiceID := range file.Voices {
+		aliases.Voices[name] = voiceID
+	}
+	return aliases, nil
+}
+
+// model returns the Eleven Labs model for an OpenAI model name. Eleven Labs model IDs pass through.
+func (a Aliases) model(name string) (string, bool) {
+	if model, ok := a.Models[name]; ok {
+		return model, true
+	}
+	return name, strings.HasPrefix(name, "eleven_")
+}
+
+// voice returns the voice ID for an alias, or name itself, taken to be a voice ID
+func (a Aliases) voice(name string) string {
+	if voiceID, ok := a.Voices[name]; ok {
+		return voiceID
+	}
+	return name
+}
+
+// speechFormats are the OpenAI response formats chatter can produce and the Eleven Labs format each needs
+var speechFormats = map[string]string{
+	"mp3": "mp3_44100_128",
+	"wav": "pcm_24000",
+	"pcm": "pcm_24000",
+}
+
+// Eleven Labs accepts speeds between these
+const (
+	minSpeed = 0.7
+	maxSpeed = 1.2
+)
+
+// SpeechRequest is the body of the OpenAI-compatible POST /v1/audio/speech
+type SpeechRequest struct {
+	Model          string  `json:"model"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice"`
+	ResponseFormat string  `json:"response_format"`
+	Speed          float64 `json:"speed"`
+	// Instructions is accepted for compatibility and ignored, as Eleven Labs voices take no prompt
+	Instructions string `json:"instructions"`
+}
+
+// speech answers like OpenAI's speech endpoint, synchronously returning the audio
+func (s *Server) speech(w http.ResponseWriter, r *http.Request) {
+	var req SpeechRequest
+	if err := decodeJSON(r, &req); err != nil {
+		status := http.StatusBadRequest
+		if errors.As(err, new(*http.MaxBytesError)) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeOpenAIError(w, status, "", err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Input) == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "input", "input is required")
+		return
+	}
+	if req.Voice == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "voice", "voice is required")
+		return
+	}
+	opts := SynthesisOptions{VoiceID: s.aliases.voice(req.Voice)}
+	if req.Model != "" {
+		model, ok := s.aliases.model(req.Model)
+		if !ok {
+			writeOpenAIError(w, http.StatusBadRequest, "model", fmt.Sprintf("unknown model %q", req.Model))
+			return
+		}
+		opts.ModelID = model
+	}
+	if req.ResponseFormat == "" {
+		req.ResponseFormat = "mp3"
+	}
+	format, ok := speechFormats[req.ResponseFormat]
+	if !ok {
+		writeOpenAIError(w, http.StatusBadRequest, "response_format", fmt.Sprintf("unsupported response_format %q, expected mp3, wav or pcm", req.ResponseFormat))
+		return
+	}
+	opts.OutputFormat = format
+
+	cfg, err := s.jobConfig(opts)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	if req.Speed != 0 {
+		cfg.VoiceSettings.Speed = min(max(req.Speed, minSpeed), maxSpeed)
+	}
+	cfg.TextInput = req.Input
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-r.Context().Done():
+		return
+	}
+	defer func() { <-s.slots }()
+
+	synth, err := client.NewSynthesizer(cfg, s.httpClient)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	p := client.NewPipeline(cfg, s.httpClient, synth).WithContext(r.Context())
+	p.Ledger = s.opts.Ledger
+	rendering, err := p.Render()
+	p.RecordUsage("")
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, "", err.Error())
+		return
+	}
+
+	data, contentType := rendering.Audio, "audio/mpeg"
+	switch req.ResponseFormat {
+	case "wav":
+		contentType = "audio/wav"
+	case "pcm":
+		contentType = "audio/pcm"
+		if rendering.Format == audio.WAV {
+			if _, data, err = audio.ParseWAV(data); err != nil {
+				writeOpenAIError(w, http.StatusBadGateway, "", err.Error())
+				return
+			}
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	if _, err = w.Write(data); err != nil {
+		slog.Warn("error writing speech", "err", err)
+	}
+}
+
+// writeOpenAIError answers with an error body in OpenAI's format, which its client libraries parse
+func writeOpenAIError(w http.ResponseWriter, status int, param, message string) {
+	body := map[string]any{"message": message, "type": "invalid_request_error", "param": nil, "code": nil}
+	if param != "" {
+		body["param"] = param
+	}
+	if status >= http.StatusInternalServerError {
+		body["type"] = "server_error"
+	}
+	writeJSON(w, status, map[string]any{"error": body})
+}
@@ -0,0 +1,112 @@
+package server_test
+
+import (
+	"encoding/json"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/sgerhardt/chatter/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServer_Speech(t *testing.T) {
+	t.Parallel()
+
+	speak := func(t *testing.T, s http.Handler, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/audio/speech", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("maps model, voice and speed", func(t *testing.T) {
+		t.Parallel()
+		s, eleven := newServer(t, server.Options{})
+		rec := speak(t, s, `{"model": "tts-1-hd", "voice": "alloy", "input": "Hello world", "speed": 2}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "audio/mpeg", rec.Header().Get("Content-Type"))
+		assert.NotEmpty(t, rec.Body.Bytes())
+
+		requests := eleven.Requests()
+		require.Len(t, requests, 2)
+		assert.Equal(t, "/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM", requests[0].Path)
+		var payload struct {
+			ModelID       string               `json:"model_id"`
+			VoiceSettings config.VoiceSettings `json:"voice_settings"`
+		}
+		require.NoError(t, json.Unmarshal(requests[0].Body, &payload))
+		assert.Equal(t, "eleven_multilingual_v2", payload.ModelID)
+		assert.InDelta(t, 1.2, payload.VoiceSettings.Speed, 0.001, "speed is clamped to the Eleven Labs range")
+	})
+
+	t.Run("returns wav and raw pcm", func(t *testing.T) {
+		t.Parallel()
+		s, _ := newServer(t, server.Options{})
+		wav := speak(t, s, `{"model": "tts-1", "voice": "AZnzlk1XvdvUeBnXmlld", "input": "Hi", "response_format": "wav"}`)
+		require.Equal(t, http.StatusOK, wav.Code, wav.Body.String())
+		assert.Equal(t, "audio/wav", wav.Header().Get("Content-Type"))
+		_, samples, err := audio.ParseWAV(wav.Body.Bytes())
+		require.NoError(t, err)
+
+		pcm := speak(t, s, `{"model": "tts-1", "voice": "AZnzlk1XvdvUeBnXmlld", "input": "Hi", "response_format": "pcm"}`)
+		require.Equal(t, http.StatusOK, pcm.Code, pcm.Body.String())
+		assert.Equal(t, "audio/pcm", pcm.Header().Get("Content-Type"))
+		assert.Equal(t, samples, pcm.Body.Bytes())
+	})
+
+	t.Run("uses configured aliases", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "aliases.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("models:\n  tts-1: eleven_turbo_v2_5\nvoices:\n  narrator: AZnzlk1XvdvUeBnXmlld\n"), 0600))
+		aliases, err := server.LoadAliases(path)
+		require.NoError(t, err)
+		assert.Equal(t, "21m00Tcm4TlvDq8ikWAM", aliases.Voices["alloy"], "defaults are kept")
+
+		s, eleven := newServer(t, server.Options{Aliases: &aliases})
+		rec := speak(t, s, `{"model": "tts-1", "voice": "narrator", "input": "Hi"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		requests := eleven.Requests()
+		require.Len(t, requests, 2)
+		assert.Equal(t, "/v1/text-to-speech/AZnzlk1XvdvUeBnXmlld", requests[0].Path)
+		assert.Contains(t, string(requests[0].Body), `"model_id":"eleven_turbo_v2_5"`)
+	})
+
+	t.Run("answers errors in the OpenAI format", func(t *testing.T) {
+		t.Parallel()
+		s, eleven := newServer(t, server.Options{})
+		for body, param := range map[string]string{
+			`{"model": "tts-1", "voice": "alloy", "input": ""}`:                              "input",
+			`{"model": "tts-1", "input": "Hi"}`:                                              "voice",
+			`{"model": "whisper-1", "voice": "alloy", "input": "Hi"}`:                        "model",
+			`{"model": "tts-1", "voice": "alloy", "input": "Hi", "response_format": "opus"}`: "response_format",
+		} {
+			rec := speak(t, s, body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+			var res struct {
+				Error struct {
+					Message string `json:"message"`
+					Type    string `json:"type"`
+					Param   string `json:"param"`
+				} `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res), body)
+			assert.Equal(t, "invalid_request_error", res.Error.Type, body)
+			assert.Equal(t, param, res.Error.Param, body)
+		}
+		assert.Empty(t, eleven.Requests())
+
+		eleven.Inject(fakeeleven.Fault{Status: http.StatusUnauthorized})
+		rec := speak(t, s, `{"model": "tts-1", "voice": "alloy", "input": "Hi"}`)
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"type":"server_error"`)
+	})
+}
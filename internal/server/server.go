@@ -0,0 +1,319 @@
+// Package server exposes the chatter pipeline over HTTP, so a team can share one API key. Callers authenticate
+// with a bearer token, submit text or a URL as a job, poll its status and download the audio. An
+// OpenAI-compatible speech endpoint lets existing OpenAI clients use chatter unchanged.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/jobs"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultMaxBodyBytes is the largest request body accepted when Options leaves it unset
+const DefaultMaxBodyBytes = 1 << 20
+
+// DefaultRetention is how long finished jobs are kept when Options leaves it unset
+const DefaultRetention = 7 * 24 * time.Hour
+
+// Options tune a Server
+type Options struct {
+	// Tokens are the bearer tokens callers may use. At least one is required.
+	Tokens []string
+	// MaxBodyBytes limits request bodies
+	MaxBodyBytes int64
+	// Store persists jobs. It is required.
+	Store *jobs.Store
+	// Jobs tune the job workers and webhooks. Jobs.Workers also limits concurrent speech requests.
+	Jobs jobs.Options
+	// Retention is how long finished jobs and their artifacts are kept
+	Retention time.Duration
+	// ShutdownTimeout is how long Run waits for requests and running chunks to finish
+	ShutdownTimeout time.Duration
+	// Aliases map OpenAI model and voice names for /v1/audio/speech, DefaultAliases when nil
+	Aliases *Aliases
+	// Metrics are served in the Prometheus format at /metrics when set
+	Metrics prometheus.Gatherer
+	// Ledger, when set, records the usage of every job and speech request
+	Ledger client.UsageLedger
+	// AllowPrivateURLs lets URL jobs fetch pages from, and webhooks be delivered to, loopback, private and
+	// link-local addresses
+	AllowPrivateURLs bool
+}
+
+// Server is the HTTP service. Each job runs the pipeline with the base configuration, overridden by the request.
+type Server struct {
+	base       *config.AppConfig
+	httpClient client.HTTP
+	opts       Options
+	aliases    Aliases
+	handler    http.Handler
+	store      *jobs.Store
+	manager    *jobs.Manager
+	slots      chan struct{}
+
+	// fetchClient fetches the pages of URL jobs
+	fetchClient client.HTTP
+}
+
+// New returns a server that synthesizes with base's provider through httpClient
+func New(base *config.AppConfig, httpClient client.HTTP, opts Options) (*Server, error) {
+	if len(opts.Tokens) == 0 {
+		return nil, errors.New("at least one API token is required")
+	}
+	if opts.Store == nil {
+		return nil, errors.New("a job store is required")
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if opts.Jobs.Workers <= 0 {
+		opts.Jobs.Workers = jobs.DefaultWorkers
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	aliases := DefaultAliases()
+	if opts.Aliases != nil {
+		aliases = *opts.Aliases
+	}
+	s := &Server{
+		base:       base,
+		aliases:    aliases,
+		httpClient: httpClient,
+		opts:       opts,
+		store:      opts.Store,
+		slots:      make(chan struct{}, opts.Jobs.Workers),
+	}
+	s.fetchClient = httpClient
+	if !opts.AllowPrivateURLs {
+		s.fetchClient = NewPublicClient(fetchTimeout)
+		if s.opts.Jobs.WebhookClient == nil {
+			s.opts.Jobs.WebhookClient = NewPublicClient(10 * time.Second)
+		}
+	}
+	s.manager = jobs.NewManager(opts.Store, s.pipelineFor, s.opts.Jobs)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.health)
+	mux.Handle("POST /v1/synthesize", s.authorized(s.submitText))
+	mux.Handle("POST /v1/convert", s.authorized(s.submitURL))
+	mux.Handle("POST /v1/documents", s.authorized(s.submitDocument))
+	mux.Handle("GET /v1/voices", s.authorized(s.listVoices))
+	mux.Handle("POST /v1/audio/speech", s.authorized(s.speech))
+	mux.Handle("GET /v1/jobs/{id}", s.authorized(s.getJob))
+	mux.Handle("POST /v1/jobs/{id}/cancel", s.authorized(s.cancelJob))
+	mux.Handle("GET /v1/jobs/{id}/audio", s.authorized(s.getAudio))
+	mux.Handle("GET /v1/jobs/{id}/artifacts", s.authorized(s.listArtifacts))
+	mux.Handle("GET /v1/jobs/{id}/artifacts/{name}", s.authorized(s.getArtifact))
+	if opts.Metrics != nil {
+		mux.Handle("GET /metrics", s.authorized(promhttp.HandlerFor(opts.Metrics, promhttp.HandlerOpts{}).ServeHTTP))
+	}
+	s.handler = mux
+	return s, nil
+}
+
+// ServeHTTP handles the request in a server span, continuing the caller's trace when it sends a traceparent header
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := telemetry.Tracer().Start(ctx, r.Method+" "+telemetry.Endpoint(r.URL), trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("http.request.method", r.Method), attribute.String("url.path", r.URL.Path)))
+	defer span.End()
+	s.handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// Run serves on the listener and works through the job queue until ctx is cancelled. It then stops accepting
+// requests and waits up to ShutdownTimeout for open requests and for running jobs to finish their current
+// chunk; those jobs resume from the next chunk when the server starts again.
+func (s *Server) Run(ctx context.Context, ln net.Listener) error {
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobsDone := make(chan error, 1)
+	go func() {
+		jobsDone <- s.manager.Run(jobsCtx)
+	}()
+
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errs:
+		stopJobs()
+		<-jobsDone
+		return err
+	case err := <-jobsDone:
+		_ = srv.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx := context.Background()
+	if s.opts.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, s.opts.ShutdownTimeout)
+		defer cancel()
+	}
+	stopJobs()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	select {
+	case err := <-jobsDone:
+		return err
+	case <-shutdownCtx.Done():
+		return errors.New("shutdown: jobs still running")
+	}
+}
+
+// authorized rejects requests without one of the configured bearer tokens and limits the body size
+func (s *Server) authorized(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !s.validToken(token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chatter"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes)
+		next(w, r)
+	})
+}
+
+func (s *Server) validToken(token string) bool {
+	valid := false
+	for _, t := range s.opts.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func (s *Server) health(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// SynthesisOptions override the server's defaults for one job
+type SynthesisOptions struct {
+	VoiceID       string                `json:"voice_id"`
+	ModelID       string                `json:"model_id"`
+	VoiceSettings *config.VoiceSettings `json:"voice_settings"`
+	OutputFormat  string                `json:"output_format"`
+	// Tag attributes the job's usage to a project in the usage ledger
+	Tag string `json:"tag"`
+}
+
+// TextRequest is the body of POST /v1/synthesize
+type TextRequest struct {
+	Text       string `json:"text"`
+	WebhookURL string `json:"webhook_url"`
+	SynthesisOptions
+}
+
+// URLRequest is the body of POST /v1/convert
+type URLRequest struct {
+	URL        string `json:"url"`
+	WebhookURL string `json:"webhook_url"`
+	SynthesisOptions
+}
+
+// jobConfig copies the base configuration with the request's overrides
+func (s *Server) jobConfig(o SynthesisOptions) (*config.AppConfig, error) {
+	cfg := *s.base
+	if o.VoiceID != "" {
+		cfg.VoiceID = o.VoiceID
+	}
+	if cfg.VoiceID == "" {
+		return nil, errors.New("voice_id is required")
+	}
+	if o.ModelID != "" {
+		cfg.ModelID = o.ModelID
+	}
+	if o.VoiceSettings != nil {
+		cfg.VoiceSettings = *o.VoiceSettings
+	}
+	if o.Tag != "" {
+		cfg.Tag = o.Tag
+	}
+	if o.OutputFormat != "" {
+		if err := client.CheckOutputFormat(o.OutputFormat); err != nil {
+			return nil, err
+		}
+		cfg.OutputFormat = o.OutputFormat
+	}
+	cfg.Subtitles = false
+	return &cfg, nil
+}
+
+func (s *Server) listVoices(w http.ResponseWriter, _ *http.Request) {
+	if s.base.Provider != "" && s.base.Provider != client.ProviderElevenLabs {
+		writeError(w, http.StatusNotImplemented, "voices can only be listed with the elevenlabs provider")
+		return
+	}
+	voices, err := client.New(s.base, s.httpClient).Voices()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"voices": voices})
+}
+
+// decode reads a JSON body, answering 413 when it is too large and 400 when it is malformed
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := decodeJSON(r, v)
+	switch {
+	case errors.As(err, new(*http.MaxBytesError)):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return false
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+// decodeJSON reads a JSON body, rejecting unknown fields
+func decodeJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("request body is larger than %d bytes: %w", tooLarge.Limit, err)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Warn("error writing response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
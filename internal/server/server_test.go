@@ -0,0 +1,362 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/sgerhardt/chatter/internal/jobs"
+	"github.com/sgerhardt/chatter/internal/server"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const token = "secret-token"
+
+func newServer(t *testing.T, opts server.Options) (*server.Server, *fakeeleven.Server) {
+	t.Helper()
+	eleven := fakeeleven.New(t)
+	cfg := &config.AppConfig{
+		CharacterRequestLimit: 10000,
+		APIKey:                "123",
+		BaseURL:               eleven.URL,
+		VoiceID:               fakeeleven.DefaultVoices[0].VoiceID,
+	}
+	opts.Tokens = []string{token}
+	if opts.Store == nil {
+		opts.Store = openStore(t, filepath.Join(t.TempDir(), "jobs.db"))
+	}
+	s, err := server.New(cfg, http.DefaultClient, opts)
+	require.NoError(t, err)
+	go func() {
+		_ = s.Run(ctx(t), listen(t))
+	}()
+	return s, eleven
+}
+
+func openStore(t *testing.T, path string) *jobs.Store {
+	t.Helper()
+	store, err := jobs.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// ctx is cancelled when the test finishes
+func ctx(t *testing.T) context.Context {
+	c, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return c
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return ln
+}
+
+// call sends a request with the test token and returns the status and body
+func call(t *testing.T, h http.Handler, method, path, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+// submit posts a job and returns its ID
+func submit(t *testing.T, h http.Handler, path, body string) string {
+	t.Helper()
+	status, res := call(t, h, http.MethodPost, path, body)
+	require.Equal(t, http.StatusAccepted, status, res)
+	var job jobs.Job
+	require.NoError(t, json.Unmarshal([]byte(res), &job))
+	assert.Equal(t, jobs.StatusQueued, job.Status)
+	return job.ID
+}
+
+// wait polls a job until it finishes
+func wait(t *testing.T, h http.Handler, id string) jobs.Job {
+	t.Helper()
+	var job jobs.Job
+	require.Eventually(t, func() bool {
+		_, res := call(t, h, http.MethodGet, "/v1/jobs/"+id, "")
+		require.NoError(t, json.Unmarshal([]byte(res), &job))
+		return job.FinishedAt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("requires a token", func(t *testing.T) {
+		t.Parallel()
+		_, err := server.New(&config.AppConfig{}, http.DefaultClient, server.Options{})
+		require.EqualError(t, err, "at least one API token is required")
+		_, err = server.New(&config.AppConfig{}, http.DefaultClient, server.Options{Tokens: []string{token}})
+		require.EqualError(t, err, "a job store is required")
+
+		s, _ := newServer(t, server.Options{})
+		for _, header := range []string{"", "Bearer wrong", token} {
+			req := httptest.NewRequest(http.MethodGet, "/v1/voices", nil)
+			req.Header.Set("Authorization", header)
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, header)
+		}
+
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("synthesizes text", func(t *testing.T) {
+		t.Parallel()
+		s, eleven := newServer(t, server.Options{})
+		id := submit(t, s, "/v1/synthesize", `{"text": "Hello world", "voice_id": "AZnzlk1XvdvUeBnXmlld", "model_id": "eleven_turbo_v2"}`)
+		job := wait(t, s, id)
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+		assert.Equal(t, "Hello world", job.Title)
+		assert.Equal(t, 1, job.ChunksTotal)
+		assert.Equal(t, 1, job.ChunksDone)
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/jobs/"+id+"/audio", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "audio/mpeg", rec.Header().Get("Content-Type"))
+		assert.NotZero(t, rec.Body.Len())
+
+		status, res := call(t, s, http.MethodGet, "/v1/jobs/"+id+"/artifacts", "")
+		require.Equal(t, http.StatusOK, status)
+		assert.Contains(t, res, `"name":"audio.mp3"`)
+		assert.Contains(t, res, `"name":"transcript.txt"`)
+		status, res = call(t, s, http.MethodGet, "/v1/jobs/"+id+"/artifacts/transcript.txt", "")
+		require.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "Hello world\n", res)
+		status, _ = call(t, s, http.MethodGet, "/v1/jobs/"+id+"/artifacts/missing.txt", "")
+		assert.Equal(t, http.StatusNotFound, status)
+
+		requests := eleven.Requests()
+		require.Len(t, requests, 2)
+		assert.Equal(t, "/v1/text-to-speech/AZnzlk1XvdvUeBnXmlld", requests[0].Path)
+		assert.Contains(t, string(requests[0].Body), `"model_id":"eleven_turbo_v2"`)
+	})
+
+	t.Run("converts a website", func(t *testing.T) {
+		t.Parallel()
+		site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = fmt.Fprint(w, "<html><head><title>News</title></head><body><p>Read all about it.</p></body></html>")
+		}))
+		t.Cleanup(site.Close)
+		s, _ := newServer(t, server.Options{AllowPrivateURLs: true})
+		job := wait(t, s, submit(t, s, "/v1/convert", `{"url": "`+site.URL+`"}`))
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+		assert.Equal(t, "News", job.Title)
+	})
+
+	t.Run("refuses private urls", func(t *testing.T) {
+		t.Parallel()
+		site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = fmt.Fprint(w, "<html><head><title>Internal</title></head><body><p>Secrets.</p></body></html>")
+		}))
+		t.Cleanup(site.Close)
+		s, eleven := newServer(t, server.Options{})
+		for _, u := range []string{site.URL, "http://localhost/", "http://169.254.169.254/latest/meta-data/", "http://[::1]/"} {
+			status, res := call(t, s, http.MethodPost, "/v1/convert", `{"url": "`+u+`"}`)
+			assert.Equal(t, http.StatusBadRequest, status, u)
+			assert.Contains(t, res, "url must not be a private address")
+		}
+
+		// names that resolve to a private address are refused when they are dialed
+		_, err := server.NewPublicClient(time.Second).Get(site.URL)
+		assert.ErrorIs(t, err, server.ErrPrivateAddress)
+		assert.Empty(t, eleven.Requests())
+	})
+
+	t.Run("converts an uploaded document", func(t *testing.T) {
+		t.Parallel()
+		s, eleven := newServer(t, server.Options{})
+		req := httptest.NewRequest(http.MethodPost, "/v1/documents?voice_id=AZnzlk1XvdvUeBnXmlld",
+			strings.NewReader("<html><head><title>Notes</title></head><body><h1>One</h1><p>First part.</p></body></html>"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "text/html; charset=utf-8")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+		var submitted jobs.Job
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &submitted))
+		assert.Equal(t, "/v1/jobs/"+submitted.ID, rec.Header().Get("Location"))
+
+		job := wait(t, s, submitted.ID)
+		require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+		assert.Equal(t, "Notes", job.Title)
+		assert.Equal(t, "/v1/text-to-speech/AZnzlk1XvdvUeBnXmlld", eleven.Requests()[0].Path)
+
+		req = httptest.NewRequest(http.MethodPost, "/v1/documents", strings.NewReader("%PDF-1.7"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/pdf")
+		rec = httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+
+	t.Run("cancels jobs", func(t *testing.T) {
+		t.Parallel()
+		s, eleven := newServer(t, server.Options{Jobs: jobs.Options{Workers: 1}})
+		eleven.Inject(fakeeleven.Fault{Path: "/v1/text-to-speech", Delay: 200 * time.Millisecond})
+		running := submit(t, s, "/v1/synthesize", `{"text": "first"}`)
+		queued := submit(t, s, "/v1/synthesize", `{"text": "second"}`)
+
+		status, res := call(t, s, http.MethodPost, "/v1/jobs/"+queued+"/cancel", "")
+		require.Equal(t, http.StatusOK, status, res)
+		assert.Contains(t, res, `"status":"cancelled"`)
+		status, _ = call(t, s, http.MethodPost, "/v1/jobs/"+queued+"/cancel", "")
+		assert.Equal(t, http.StatusConflict, status, "already finished")
+		status, _ = call(t, s, http.MethodPost, "/v1/jobs/missing/cancel", "")
+		assert.Equal(t, http.StatusNotFound, status)
+
+		assert.Equal(t, jobs.StatusSucceeded, wait(t, s, running).Status)
+		assert.Equal(t, jobs.StatusCancelled, wait(t, s, queued).Status)
+		assert.Len(t, eleven.Requests(), 2, "the cancelled job is never synthesized")
+	})
+
+	t.Run("needs a secret for webhooks", func(t *testing.T) {
+		t.Parallel()
+		s, _ := newServer(t, server.Options{})
+		status, res := call(t, s, http.MethodPost, "/v1/synthesize", `{"text": "hi", "webhook_url": "https://example.com/hook"}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Contains(t, res, "no webhook secret")
+
+		s, _ = newServer(t, server.Options{Jobs: jobs.Options{WebhookSecret: "shh"}})
+		status, _ = call(t, s, http.MethodPost, "/v1/synthesize", `{"text": "hi", "webhook_url": "ftp://example.com/hook"}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		status, res = call(t, s, http.MethodPost, "/v1/synthesize", `{"text": "hi", "webhook_url": "http://10.0.0.1/hook"}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Contains(t, res, "webhook_url must not be a private address")
+	})
+
+	t.Run("reports failed jobs", func(t *testing.T) {
+		t.Parallel()
+		s, eleven := newServer(t, server.Options{})
+		eleven.Inject(fakeeleven.Fault{Path: "/v1/text-to-speech", Status: http.StatusInternalServerError})
+		id := submit(t, s, "/v1/synthesize", `{"text": "Hello"}`)
+		job := wait(t, s, id)
+		assert.Equal(t, jobs.StatusFailed, job.Status)
+		assert.Contains(t, job.Error, "500")
+
+		status, _ := call(t, s, http.MethodGet, "/v1/jobs/"+id+"/audio", "")
+		assert.Equal(t, http.StatusConflict, status)
+	})
+
+	t.Run("rejects bad requests", func(t *testing.T) {
+		t.Parallel()
+		s, _ := newServer(t, server.Options{MaxBodyBytes: 64})
+		for body, want := range map[string]int{
+			`{"text": ""}`:                                 http.StatusBadRequest,
+			`{"text": "hi", "speed": 2}`:                   http.StatusBadRequest,
+			`{"text": "hi", "output_format": "ogg"}`:       http.StatusBadRequest,
+			`{"text": "` + strings.Repeat("a", 100) + `"}`: http.StatusRequestEntityTooLarge,
+		} {
+			status, res := call(t, s, http.MethodPost, "/v1/synthesize", body)
+			assert.Equal(t, want, status, res)
+		}
+		status, _ := call(t, s, http.MethodPost, "/v1/convert", `{"url": "file:///etc/passwd"}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		status, _ = call(t, s, http.MethodGet, "/v1/jobs/missing", "")
+		assert.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("serves metrics", func(t *testing.T) {
+		t.Parallel()
+		s, _ := newServer(t, server.Options{})
+		status, _ := call(t, s, http.MethodGet, "/metrics", "")
+		assert.Equal(t, http.StatusNotFound, status, "only when a registry is given")
+
+		reg := prometheus.NewRegistry()
+		telemetry.NewMetrics(reg)
+		s, _ = newServer(t, server.Options{Metrics: reg})
+		status, res := call(t, s, http.MethodGet, "/metrics", "")
+		require.Equal(t, http.StatusOK, status)
+		assert.Contains(t, res, "chatter_characters_billed_total 0")
+	})
+
+	t.Run("lists voices", func(t *testing.T) {
+		t.Parallel()
+		s, _ := newServer(t, server.Options{})
+		status, res := call(t, s, http.MethodGet, "/v1/voices", "")
+		require.Equal(t, http.StatusOK, status)
+		assert.Contains(t, res, `"name":"Rachel"`)
+	})
+}
+
+func TestServer_Run(t *testing.T) {
+	t.Parallel()
+
+	eleven := fakeeleven.New(t)
+	eleven.Inject(fakeeleven.Fault{Path: "/v1/text-to-speech", Delay: 200 * time.Millisecond})
+	cfg := &config.AppConfig{CharacterRequestLimit: 10000, APIKey: "123", BaseURL: eleven.URL, VoiceID: fakeeleven.DefaultVoices[0].VoiceID}
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	start := func() (*server.Server, net.Listener, context.CancelFunc, chan error) {
+		s, err := server.New(cfg, http.DefaultClient, server.Options{
+			Tokens:          []string{token},
+			Store:           openStore(t, path),
+			Jobs:            jobs.Options{Workers: 1},
+			ShutdownTimeout: 5 * time.Second,
+		})
+		require.NoError(t, err)
+		ln := listen(t)
+		c, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- s.Run(c, ln) }()
+		return s, ln, cancel, done
+	}
+	s, ln, cancel, done := start()
+
+	post := func(text string) string {
+		req, rErr := http.NewRequest(http.MethodPost, "http://"+ln.Addr().String()+"/v1/synthesize", strings.NewReader(`{"text": "`+text+`"}`))
+		require.NoError(t, rErr)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, rErr := http.DefaultClient.Do(req)
+		require.NoError(t, rErr)
+		defer func() { _ = res.Body.Close() }()
+		body, rErr := io.ReadAll(res.Body)
+		require.NoError(t, rErr)
+		require.Equal(t, http.StatusAccepted, res.StatusCode, string(body))
+		var job jobs.Job
+		require.NoError(t, json.Unmarshal(body, &job))
+		return job.ID
+	}
+	running := post("first")
+	require.Eventually(t, func() bool {
+		_, res := call(t, s, http.MethodGet, "/v1/jobs/"+running, "")
+		return strings.Contains(res, jobs.StatusRunning)
+	}, 5*time.Second, 5*time.Millisecond)
+	queued := post("second")
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, jobs.StatusSucceeded, wait(t, s, running).Status, "the running chunk finishes")
+	_, res := call(t, s, http.MethodGet, "/v1/jobs/"+queued, "")
+	assert.Contains(t, res, `"status":"queued"`, "queued jobs wait for the next start")
+
+	s, _, cancel, done = start()
+	assert.Equal(t, jobs.StatusSucceeded, wait(t, s, queued).Status, "queued jobs resume")
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,195 @@
+package setup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/profile"
+	"github.com/sgerhardt/chatter/internal/secret"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keyStoreEnv set to "file" skips the system keyring and keeps the API key in the encrypted file
+const keyStoreEnv = "CHATTER_KEYSTORE"
+
+// openKeyStore returns where auth login keeps the API key: the system keyring, or an encrypted file in the config dir
+func openKeyStore() secret.Store {
+	dir, err := profile.Dir()
+	if err != nil {
+		dir = "."
+	}
+	path := filepath.Join(dir, "credentials")
+	if os.Getenv(keyStoreEnv) == "file" {
+		return secret.NewFile(path, passphrase)
+	}
+	return secret.Open(path, passphrase)
+}
+
+// passphrase unlocks the encrypted key file, from CHATTER_PASSPHRASE or a terminal prompt
+func passphrase() (string, error) {
+	if p := os.Getenv("CHATTER_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("no keyring available: set CHATTER_PASSPHRASE to use the encrypted API key file")
+	}
+	fmt.Fprint(os.Stderr, "Passphrase for the API key file: ")
+	p, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(p), err
+}
+
+// readSecret prompts for a value without echoing it on a terminal, or reads a line from in otherwise
+func readSecret(in io.Reader, out io.Writer, prompt string) (string, error) {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(out, prompt)
+		b, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(out)
+		return strings.TrimSpace(string(b)), err
+	}
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// checkKey fetches the account the key belongs to, failing when Eleven Labs rejects it
+func checkKey(cfg *config.AppConfig, key string) (*client.User, error) {
+	keyed := *cfg
+	keyed.APIKey = key
+	u, err := client.New(&keyed, newHTTPClient()).User()
+	if err != nil {
+		return nil, fmt.Errorf("API key was rejected: %w", err)
+	}
+	return u, nil
+}
+
+func describeUser(u *client.User) string {
+	name := u.FirstName
+	if name == "" {
+		name = u.UserID
+	}
+	return fmt.Sprintf("%s (%s plan, %d of %d characters used)", name, u.Subscription.Tier, u.Subscription.CharacterCount, u.Subscription.CharacterLimit)
+}
+
+func newAuthCmd(g *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Store the Eleven Labs API key in the system keyring",
+		Long: `Auth keeps the Eleven Labs API key out of plaintext files.
+
+login stores the key in the system keyring (the Secret Service on Linux). Where no keyring is reachable the key
+is written to an encrypted file in $XDG_CONFIG_HOME/chatter, unlocked with CHATTER_PASSPHRASE or a prompt.
+Set CHATTER_KEYSTORE=file to use the file even when a keyring is reachable.
+The stored key is used when neither XI_API_KEY nor the config profile provides one.`,
+	}
+	cmd.AddCommand(newAuthLoginCmd(g), newAuthLogoutCmd(), newAuthStatusCmd(g))
+	return cmd
+}
+
+func newAuthLoginCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "login",
+		Short: "Check an API key against Eleven Labs and store it",
+		Long:  "Login reads the API key from a prompt, or from stdin when it isn't a terminal, e.g. pass show elevenlabs | chatter auth login",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := resolveConfig(g.envFile, client.ProviderElevenLabs, g)
+			if err != nil {
+				return err
+			}
+			key, err := readSecret(cmd.InOrStdin(), cmd.ErrOrStderr(), "Eleven Labs API key: ")
+			if err != nil {
+				return err
+			}
+			if key == "" {
+				return errors.New("no API key given")
+			}
+			u, err := checkKey(cfg, key)
+			if err != nil {
+				return err
+			}
+			store := openKeyStore()
+			if err = store.Set(key); err != nil {
+				return fmt.Errorf("failed to store API key: %w", err)
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Logged in as %s\nAPI key stored in %s\n", describeUser(u), store)
+			return err
+		},
+	}
+}
+
+func newAuthLogoutCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "logout",
+		Short: "Remove the stored API key",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			store := openKeyStore()
+			err := store.Delete()
+			if errors.Is(err, secret.ErrNotFound) {
+				_, err = fmt.Fprintln(cmd.OutOrStdout(), "No API key stored")
+				return err
+			}
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "API key removed from %s\n", store)
+			return err
+		},
+	}
+}
+
+func newAuthStatusCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show which API key is used and check it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := resolveConfig(g.envFile, client.ProviderElevenLabs, g)
+			if err != nil {
+				return err
+			}
+			_, p, err := g.selectProfile()
+			if err != nil {
+				return err
+			}
+			var source string
+			switch {
+			case os.Getenv("XI_API_KEY") != "":
+				source = "XI_API_KEY"
+			case p.APIKey != "":
+				source = "the profile's api_key"
+			case p.APIKeyCommand != "":
+				source = "the profile's api_key_command"
+			default:
+				store := openKeyStore()
+				if cfg.APIKey, err = store.Get(); errors.Is(err, secret.ErrNotFound) {
+					_, err = fmt.Fprintln(cmd.OutOrStdout(), "Not logged in: no API key in XI_API_KEY, the config profile or the key store")
+					return err
+				} else if err != nil {
+					return err
+				}
+				source = store.String()
+			}
+			if _, err = fmt.Fprintf(cmd.OutOrStdout(), "API key %s from %s\n", maskKey(cfg.APIKey), source); err != nil {
+				return err
+			}
+			u, err := checkKey(cfg, cfg.APIKey)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Logged in as %s\n", describeUser(u))
+			return err
+		},
+	}
+}
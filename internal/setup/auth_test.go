@@ -0,0 +1,75 @@
+package setup
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain keeps the tests away from the developer's real keyring
+func TestMain(m *testing.M) {
+	keyring.MockInit()
+	os.Exit(m.Run())
+}
+
+func TestAuthCmd(t *testing.T) { // nolint:paralleltest
+	s := fakeeleven.New(t, fakeeleven.WithAPIKey("good-key-5678"), fakeeleven.WithCharacterLimit(1000))
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(envFile, nil, 0600))
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XI_API_BASE_URL", s.URL)
+	for _, key := range []string{"XI_API_KEY", "CHATTER_CONFIG", "CHATTER_PROFILE"} {
+		t.Setenv(key, "")
+	}
+
+	run := func(stdin string, args ...string) (string, error) {
+		cmd := NewRootCmd()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetIn(strings.NewReader(stdin))
+		cmd.SetArgs(append([]string{"auth", "--env-file", envFile}, args...))
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("", "status")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Not logged in")
+
+	_, err = run("bad-key\n", "login")
+	assert.ErrorContains(t, err, "API key was rejected")
+
+	out, err = run("good-key-5678\n", "login")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Logged in as Fake (free plan, 0 of 1000 characters used)")
+	assert.Contains(t, out, "stored in the system keyring")
+
+	cfg, err := loadConfig(envFile, "", &globalFlags{})
+	require.NoError(t, err)
+	assert.Equal(t, "good-key-5678", cfg.APIKey, "stored key is used when nothing else sets one")
+
+	out, err = run("", "status")
+	require.NoError(t, err)
+	assert.Contains(t, out, "API key *********5678 from the system keyring")
+
+	t.Setenv("XI_API_KEY", "env-key")
+	cfg, err = loadConfig(envFile, "", &globalFlags{})
+	require.NoError(t, err)
+	assert.Equal(t, "env-key", cfg.APIKey, "environment over stored key")
+	t.Setenv("XI_API_KEY", "")
+
+	out, err = run("", "logout")
+	require.NoError(t, err)
+	assert.Contains(t, out, "API key removed from the system keyring")
+	out, err = run("", "logout")
+	require.NoError(t, err)
+	assert.Contains(t, out, "No API key stored")
+}
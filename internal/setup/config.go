@@ -0,0 +1,382 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/normalize"
+	"github.com/sgerhardt/chatter/internal/profile"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultCharacterLimit is the most text sent in one request when no profile sets a limit
+const defaultCharacterLimit = 10000
+
+// globalFlags select the config file and profile for every command
+type globalFlags struct {
+	configFile string
+	profile    string
+	envFile    string
+	logFormat  string
+	verbose    bool
+	quiet      bool
+	otlp       string
+	usageDB    string
+	// stopTracing flushes and stops the span exporter once tracing is set up
+	stopTracing func(context.Context) error
+}
+
+func (g *globalFlags) register(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&g.configFile, "config", "", "Config file (default $XDG_CONFIG_HOME/chatter/config.yaml, or CHATTER_CONFIG)")
+	cmd.PersistentFlags().StringVar(&g.envFile, "env-file", "", "Env file to load (default the first .env in the current directory, the executable's directory or $XDG_CONFIG_HOME/chatter, or CHATTER_ENV_FILE)")
+	cmd.PersistentFlags().StringVar(&g.profile, "profile", "", "Config file profile to use (default the file's default_profile, or CHATTER_PROFILE)")
+	cmd.PersistentFlags().StringVar(&g.logFormat, "log-format", telemetry.FormatText, "Log format: text or json")
+	cmd.PersistentFlags().BoolVar(&g.verbose, "verbose", false, "Log every API request with its request ID, latency, characters billed and retries")
+	cmd.PersistentFlags().BoolVar(&g.quiet, "quiet", false, "Only log errors")
+	cmd.PersistentFlags().StringVar(&g.usageDB, "usage-db", "", "SQLite usage ledger recording every synthesis (default $XDG_CONFIG_HOME/chatter/usage.db, or CHATTER_USAGE_DB)")
+	cmd.PersistentFlags().StringVar(&g.otlp, "otlp-endpoint", "", "Export OpenTelemetry traces to this OTLP/HTTP collector, e.g. http://localhost:4318 (default OTEL_EXPORTER_OTLP_ENDPOINT)")
+}
+
+// setupLogging makes the logger chosen by the flags the default, writing to w
+func (g *globalFlags) setupLogging(w io.Writer) error {
+	level, err := telemetry.Level(g.verbose, g.quiet)
+	if err != nil {
+		return err
+	}
+	logger, err := telemetry.NewLogger(w, g.logFormat, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// setupTracing exports spans when a collector is given by flag or environment
+func (g *globalFlags) setupTracing(ctx context.Context) error {
+	if !telemetry.TracingEnabled(g.otlp) {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	stop, err := telemetry.SetupTracing(ctx, g.otlp)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	g.stopTracing = stop
+	return nil
+}
+
+// shutdownTracing exports the spans still buffered, giving the collector a few seconds
+func (g *globalFlags) shutdownTracing() error {
+	if g.stopTracing == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := g.stopTracing(ctx); err != nil {
+		return fmt.Errorf("failed to export traces: %w", err)
+	}
+	return nil
+}
+
+// configPath returns the config file to read, "" when there is none. A file named by flag or environment must exist.
+func (g *globalFlags) configPath() (string, error) {
+	path := g.configFile
+	if path == "" {
+		path = os.Getenv("CHATTER_CONFIG")
+	}
+	if path == "" {
+		return profile.DefaultPath(), nil
+	}
+	path = profile.ExpandHome(path)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("config file: %w", err)
+	}
+	return path, nil
+}
+
+// selectProfile loads the config file and returns the chosen profile's name and settings, an empty profile
+// when there is no config file
+func (g *globalFlags) selectProfile() (string, profile.Profile, error) {
+	if g == nil {
+		return "", profile.Profile{}, nil
+	}
+	path, err := g.configPath()
+	if err != nil || path == "" {
+		return "", profile.Profile{}, err
+	}
+	f, err := profile.Load(path)
+	if err != nil {
+		return "", profile.Profile{}, err
+	}
+	name := g.profile
+	if name == "" {
+		name = os.Getenv("CHATTER_PROFILE")
+	}
+	return f.Select(name)
+}
+
+// resolveConfig builds the configuration from, in increasing precedence, defaults, the selected profile and the
+// environment. provider overrides the profile's provider when set. Flags are applied on top by each command.
+func resolveConfig(filename, provider string, g *globalFlags) (*config.AppConfig, error) {
+	name, p, err := g.selectProfile()
+	if err != nil {
+		return nil, err
+	}
+	key, dir, err := readEnvFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading env file: %w", err)
+	}
+	if provider == "" {
+		provider = p.Provider
+	}
+	switch provider {
+	case "", client.ProviderElevenLabs:
+	case client.ProviderOpenAI:
+		key = os.Getenv("OPENAI_API_KEY")
+	default:
+		key = ""
+	}
+	if key == "" && (p.APIKey != "" || p.APIKeyCommand != "") && provider != client.ProviderCommand {
+		if key, err = p.ResolveAPIKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	app := &config.AppConfig{
+		APIKey:                key,
+		OutputDir:             dir,
+		BaseURL:               os.Getenv("XI_API_BASE_URL"),
+		CharacterRequestLimit: defaultCharacterLimit,
+		Provider:              p.Provider,
+		ProviderURL:           p.ProviderURL,
+		Command:               p.Command,
+		VoiceID:               p.Voice,
+		ModelID:               p.Model,
+		OutputFormat:          p.Format,
+		Language:              p.Language,
+		Profile:               name,
+	}
+	if app.OutputDir == "" {
+		app.OutputDir = profile.ExpandHome(p.OutputDir)
+	}
+	if app.BaseURL == "" {
+		app.BaseURL = p.BaseURL
+	}
+	if p.CharacterLimit > 0 {
+		app.CharacterRequestLimit = p.CharacterLimit
+	}
+	if p.VoiceSettings != nil {
+		app.VoiceSettings = *p.VoiceSettings
+	}
+	if app.PronunciationDictionaries, err = parseDictionaries([]string{os.Getenv("PRONUNCIATION_DICTIONARIES")}); err != nil {
+		return nil, fmt.Errorf("error reading PRONUNCIATION_DICTIONARIES: %w", err)
+	}
+	return app, nil
+}
+
+// validateProfile reports every problem with a profile
+func validateProfile(p profile.Profile) []error {
+	var errs []error
+	if p.Provider != "" && !slices.Contains(client.Providers(), p.Provider) {
+		errs = append(errs, fmt.Errorf("unknown provider %q, expected one of %s", p.Provider, strings.Join(client.Providers(), ", ")))
+	}
+	if err := client.CheckOutputFormat(p.Format); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := normalize.New(normalize.Options{Language: p.Language}); err != nil {
+		errs = append(errs, err)
+	}
+	if p.CharacterLimit < 0 {
+		errs = append(errs, fmt.Errorf("character_limit must be positive, got %d", p.CharacterLimit))
+	}
+	if s := p.VoiceSettings; s != nil {
+		for _, v := range []struct {
+			name  string
+			value float64
+		}{{"stability", s.Stability}, {"similarity_boost", s.SimilarityBoost}, {"style", s.Style}} {
+			if v.value < 0 || v.value > 1 {
+				errs = append(errs, fmt.Errorf("voice_settings.%s must be between 0 and 1, got %g", v.name, v.value))
+			}
+		}
+		if s.Speed != 0 && (s.Speed < 0.7 || s.Speed > 1.2) {
+			errs = append(errs, fmt.Errorf("voice_settings.speed must be between 0.7 and 1.2, got %g", s.Speed))
+		}
+	}
+	if p.APIKey != "" && p.APIKeyCommand != "" {
+		errs = append(errs, errors.New("set only one of api_key and api_key_command"))
+	}
+	if strings.HasPrefix(p.APIKey, "file:") {
+		if _, err := p.ResolveAPIKey(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func newConfigCmd(g *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show and check the configuration",
+		Long: `Config shows and checks chatter's configuration file.
+
+The file is read from $XDG_CONFIG_HOME/chatter/config.yaml (or config.yml or config.toml), --config or CHATTER_CONFIG.
+It holds named profiles; --profile or CHATTER_PROFILE picks one, otherwise default_profile is used:
+  default_profile: work
+  profiles:
+    work:
+      api_key: env:WORK_XI_API_KEY      # or file:~/.secrets/xi, or the key itself
+      # api_key_command: pass show elevenlabs
+      voice: 21m00Tcm4TlvDq8ikWAM
+      model: eleven_multilingual_v2
+      voice_settings: {stability: 0.5, similarity_boost: 0.75}
+      output_dir: ~/podcasts
+      format: mp3_44100_128
+      character_limit: 5000
+      lang: en
+
+Flags take precedence over environment variables, which take precedence over the profile.`,
+	}
+	cmd.AddCommand(newConfigShowCmd(g), newConfigValidateCmd(g))
+	return cmd
+}
+
+// shownConfig is the effective configuration as printed by config show
+type shownConfig struct {
+	ConfigFile     string               `yaml:"config_file"`
+	EnvFile        string               `yaml:"env_file"`
+	Profile        string               `yaml:"profile"`
+	Provider       string               `yaml:"provider"`
+	APIKey         string               `yaml:"api_key"`
+	BaseURL        string               `yaml:"base_url"`
+	Voice          string               `yaml:"voice"`
+	Model          string               `yaml:"model"`
+	VoiceSettings  config.VoiceSettings `yaml:"voice_settings"`
+	OutputDir      string               `yaml:"output_dir"`
+	Format         string               `yaml:"format"`
+	CharacterLimit int                  `yaml:"character_limit"`
+	Language       string               `yaml:"lang"`
+}
+
+func newConfigShowCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration, with the API key masked",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := resolveConfig(g.envFile, "", g)
+			if err != nil {
+				return err
+			}
+			path, err := g.configPath()
+			if err != nil {
+				return err
+			}
+			name, _, err := g.selectProfile()
+			if err != nil {
+				return err
+			}
+			envFile, err := findEnvFile(g.envFile)
+			if err != nil {
+				return err
+			}
+			shown := shownConfig{
+				ConfigFile:     orNone(path),
+				EnvFile:        orNone(envFile),
+				Profile:        orNone(name),
+				Provider:       cfg.Provider,
+				APIKey:         maskKey(cfg.APIKey),
+				BaseURL:        cfg.BaseURL,
+				Voice:          cfg.VoiceID,
+				Model:          cfg.ModelID,
+				VoiceSettings:  cfg.VoiceSettings,
+				OutputDir:      cfg.OutputDir,
+				Format:         cfg.OutputFormat,
+				CharacterLimit: cfg.CharacterRequestLimit,
+				Language:       cfg.Language,
+			}
+			if shown.Provider == "" {
+				shown.Provider = client.ProviderElevenLabs
+			}
+			if shown.BaseURL == "" {
+				shown.BaseURL = client.DefaultBaseURL
+			}
+			if shown.Language == "" {
+				shown.Language = "none"
+			}
+			enc := yaml.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent(2)
+			if err = enc.Encode(shown); err != nil {
+				return err
+			}
+			return enc.Close()
+		},
+	}
+}
+
+func newConfigValidateCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check every profile in the config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			path, err := g.configPath()
+			if err != nil {
+				return err
+			}
+			if path == "" {
+				dir, _ := profile.Dir()
+				return fmt.Errorf("no config file found in %s", dir)
+			}
+			f, err := profile.Load(path)
+			if err != nil {
+				return err
+			}
+			var problems []string
+			if f.DefaultProfile != "" {
+				if _, ok := f.Profiles[f.DefaultProfile]; !ok {
+					problems = append(problems, fmt.Sprintf("default_profile %q is not defined", f.DefaultProfile))
+				}
+			}
+			for _, name := range f.Names() {
+				for _, err := range validateProfile(f.Profiles[name]) {
+					problems = append(problems, fmt.Sprintf("profile %s: %v", name, err))
+				}
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%s is invalid:\n  %s", path, strings.Join(problems, "\n  "))
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s is valid (%d profiles)\n", path, len(f.Profiles))
+			return err
+		},
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// maskKey hides all but the last four characters of an API key
+func maskKey(key string) string {
+	if key == "" {
+		return "(not set)"
+	}
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return strings.Repeat("*", len(key)-4) + key[len(key)-4:]
+}
@@ -0,0 +1,112 @@
+package setup
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `default_profile: work
+profiles:
+  work:
+    api_key: profile-key-1234
+    voice: profile-voice
+    model: eleven_multilingual_v2
+    voice_settings: {stability: 0.3, similarity_boost: 0.6}
+    output_dir: /tmp/profile
+    format: mp3_44100_128
+    character_limit: 5000
+  broken:
+    provider: polly
+    format: wav
+    character_limit: -1
+    voice_settings: {stability: 2, similarity_boost: 0.5, speed: 2}
+`
+
+// configEnv writes an empty .env and the test config, and clears the environment variables they compete with
+func configEnv(t *testing.T) (envFile string, g *globalFlags) {
+	t.Helper()
+	dir := t.TempDir()
+	envFile = filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(envFile, nil, 0600))
+	configFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(testConfig), 0600))
+	for _, key := range []string{"XI_API_KEY", "OUTPUT", "XI_API_BASE_URL", "PRONUNCIATION_DICTIONARIES", "CHATTER_PROFILE"} {
+		t.Setenv(key, "")
+	}
+	return envFile, &globalFlags{configFile: configFile}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) { // nolint:paralleltest
+	envFile, g := configEnv(t)
+
+	cfg, err := loadConfig(envFile, "", g)
+	require.NoError(t, err)
+	assert.Equal(t, &config.AppConfig{
+		CharacterRequestLimit: 5000,
+		OutputDir:             "/tmp/profile",
+		APIKey:                "profile-key-1234",
+		VoiceID:               "profile-voice",
+		ModelID:               "eleven_multilingual_v2",
+		VoiceSettings:         config.VoiceSettings{Stability: 0.3, SimilarityBoost: 0.6},
+		OutputFormat:          "mp3_44100_128",
+		Profile:               "work",
+	}, cfg, "profile over defaults")
+
+	t.Setenv("XI_API_KEY", "env-key")
+	t.Setenv("OUTPUT", "/tmp/env")
+	cfg, err = loadConfig(envFile, "", g)
+	require.NoError(t, err)
+	assert.Equal(t, "env-key", cfg.APIKey, "env over profile")
+	assert.Equal(t, "/tmp/env", cfg.OutputDir, "env over profile")
+
+	cmd := &cobra.Command{}
+	var flags synthesisFlags
+	flags.register(cmd)
+	require.NoError(t, cmd.ParseFlags([]string{"--model", "eleven_turbo_v2"}))
+	require.NoError(t, flags.apply(cmd, cfg))
+	assert.Equal(t, "eleven_turbo_v2", cfg.ModelID, "flag over profile")
+	assert.Equal(t, "mp3_44100_128", cfg.OutputFormat, "unset flag keeps profile")
+	assert.Equal(t, "none", cfg.Language, "unset flag fills default")
+
+	g.profile = "missing"
+	_, err = loadConfig(envFile, "", g)
+	assert.ErrorContains(t, err, `profile "missing" not found`)
+}
+
+func TestConfigCmd(t *testing.T) { // nolint:paralleltest
+	_, g := configEnv(t)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(filepath.Dir(g.configFile)))
+	t.Cleanup(func() { require.NoError(t, os.Chdir(wd)) })
+
+	run := func(args ...string) (string, error) {
+		cmd := NewRootCmd()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(append([]string{"config", "--config", g.configFile}, args...))
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("show")
+	require.NoError(t, err)
+	assert.Contains(t, out, "profile: work\n")
+	assert.Contains(t, out, "api_key: '************1234'\n")
+	assert.Contains(t, out, "voice: profile-voice\n")
+
+	_, err = run("validate")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `profile broken: unknown provider "polly"`)
+	assert.Contains(t, err.Error(), `profile broken: unsupported output format "wav"`)
+	assert.Contains(t, err.Error(), "profile broken: character_limit must be positive, got -1")
+	assert.Contains(t, err.Error(), "profile broken: voice_settings.stability must be between 0 and 1, got 2")
+	assert.Contains(t, err.Error(), "profile broken: voice_settings.speed must be between 0.7 and 1.2, got 2")
+	assert.NotContains(t, err.Error(), "profile work")
+}
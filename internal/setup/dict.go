@@ -0,0 +1,214 @@
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/lexicon"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// maxDictionaries is the number of pronunciation dictionaries Eleven Labs applies to a single request
+const maxDictionaries = 3
+
+// parseDictionaries reads dictionary locators in ID[:versionID] form
+func parseDictionaries(values []string) ([]config.DictionaryLocator, error) {
+	var locators []config.DictionaryLocator
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			id, version, _ := strings.Cut(item, ":")
+			if id == "" {
+				return nil, fmt.Errorf("invalid dictionary %q, expected ID[:versionID]", item)
+			}
+			locators = append(locators, config.DictionaryLocator{ID: id, VersionID: version})
+		}
+	}
+	if len(locators) > maxDictionaries {
+		return nil, fmt.Errorf("at most %d pronunciation dictionaries can be used, got %d", maxDictionaries, len(locators))
+	}
+	return locators, nil
+}
+
+// applyDictionaries overrides the configured dictionaries when --dict was given
+func applyDictionaries(cmd *cobra.Command, cfg *config.AppConfig, values []string) error {
+	if !cmd.Flags().Changed("dict") {
+		return nil
+	}
+	locators, err := parseDictionaries(values)
+	if err != nil {
+		return err
+	}
+	cfg.PronunciationDictionaries = locators
+	return nil
+}
+
+func newDictCmd(g *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dict",
+		Short: "Manage pronunciation dictionaries",
+		Long: `Dict manages Eleven Labs pronunciation dictionaries.
+
+Dictionaries are read from a PLS lexicon (.pls or .xml) or a text file with one word=phoneme pair per line.
+Apply them to synthesis with --dict ID[:versionID], or set PRONUNCIATION_DICTIONARIES in the .env file.`,
+	}
+	cmd.AddCommand(newDictCreateCmd(g), newDictUploadCmd(g), newDictListCmd(g), newDictShowCmd(g), newDictDownloadCmd(g))
+	return cmd
+}
+
+// newAPIClient returns an Eleven Labs client for the account management commands
+func newAPIClient(g *globalFlags) (*client.ElevenLabs, error) {
+	cfg, err := loadConfig(g.envFile, client.ProviderElevenLabs, g)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(cfg, newHTTPClient()), nil
+}
+
+func newDictCreateCmd(g *globalFlags) *cobra.Command {
+	var description string
+	var alphabet string
+	cmd := &cobra.Command{
+		Use:   "create <name> <file>",
+		Short: "Create a dictionary from a PLS or word=phoneme file",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rules, err := lexicon.Load(args[1], alphabet)
+			if err != nil {
+				return err
+			}
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			version, err := c.CreateDictionary(args[0], description, rules)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "created %s with %d rules, version %s\n", version.ID, len(rules), version.VersionID)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&description, "description", "", "Dictionary description")
+	cmd.Flags().StringVar(&alphabet, "alphabet", lexicon.DefaultAlphabet, "Phonetic alphabet of word=phoneme files (ipa or cmu-arpabet)")
+	return cmd
+}
+
+func newDictUploadCmd(g *globalFlags) *cobra.Command {
+	var alphabet string
+	cmd := &cobra.Command{
+		Use:   "upload <id> <file>",
+		Short: "Add the rules in a file to a dictionary, creating a new version",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rules, err := lexicon.Load(args[1], alphabet)
+			if err != nil {
+				return err
+			}
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			version, err := c.AddDictionaryRules(args[0], rules)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "uploaded %d rules to %s, version %s\n", len(rules), version.ID, version.VersionID)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&alphabet, "alphabet", lexicon.DefaultAlphabet, "Phonetic alphabet of word=phoneme files (ipa or cmu-arpabet)")
+	return cmd
+}
+
+func newDictListCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List pronunciation dictionaries",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			dicts, err := c.ListDictionaries()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "ID\tNAME\tLATEST VERSION\tCREATED")
+			for _, d := range dicts {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.ID, d.Name, d.LatestVersionID, time.Unix(d.CreationTimeUnix, 0).Format(time.DateOnly))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newDictShowCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show a dictionary and its latest version",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			d, err := c.GetDictionary(args[0])
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "id: %s\nname: %s\ndescription: %s\nlatest version: %s\nlocator: %s:%s\n",
+				d.ID, d.Name, d.Description, d.LatestVersionID, d.ID, d.LatestVersionID)
+			return err
+		},
+	}
+}
+
+func newDictDownloadCmd(g *globalFlags) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "download <id> [versionID]",
+		Short: "Download a version of a dictionary as PLS, defaulting to the latest",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			version := ""
+			if len(args) == 2 {
+				version = args[1]
+			} else {
+				d, gErr := c.GetDictionary(args[0])
+				if gErr != nil {
+					return gErr
+				}
+				version = d.LatestVersionID
+			}
+			if version == "" {
+				return errors.New("dictionary has no versions")
+			}
+			data, err := c.DownloadDictionary(args[0], version)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				_, err = cmd.OutOrStdout().Write(data)
+				return err
+			}
+			return os.WriteFile(output, data, 0644)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write to instead of stdout")
+	return cmd
+}
@@ -0,0 +1,142 @@
+package setup
+
+import (
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/playback"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"slices"
+	"time"
+)
+
+// synthesisFlags are the options shared by every command that produces audio
+type synthesisFlags struct {
+	jobName        string
+	coverArt       string
+	subtitles      bool
+	modelID        string
+	dictionaries   []string
+	language       string
+	replacements   string
+	spellAcronyms  bool
+	showNormalized bool
+	provider       string
+	providerURL    string
+	command        string
+	apiURL         string
+	format         string
+	tag            string
+	post           config.PostProcessing
+}
+
+func (f *synthesisFlags) register(cmd *cobra.Command) {
+	f.addFlags(cmd.Flags())
+}
+
+// registerOnly registers the named flags, for commands that honour only some of them. The rest keep their
+// defaults, so they are rejected as unknown rather than accepted and ignored.
+func (f *synthesisFlags) registerOnly(cmd *cobra.Command, names ...string) {
+	all := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	f.addFlags(all)
+	for _, name := range names {
+		cmd.Flags().AddFlag(all.Lookup(name))
+	}
+}
+
+// registerExcept registers every flag but the named ones, which keep their defaults
+func (f *synthesisFlags) registerExcept(cmd *cobra.Command, names ...string) {
+	all := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	f.addFlags(all)
+	all.VisitAll(func(flag *pflag.Flag) {
+		if !slices.Contains(names, flag.Name) {
+			cmd.Flags().AddFlag(flag)
+		}
+	})
+}
+
+func (f *synthesisFlags) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&f.jobName, "name", "", "Job name written as the album tag (defaults to the site domain)")
+	flags.StringVar(&f.coverArt, "cover", "", "Image file to embed as cover art")
+	flags.BoolVar(&f.subtitles, "subtitles", false, "Write .srt and .vtt subtitles next to the audio")
+	flags.StringVar(&f.provider, "provider", client.ProviderElevenLabs, "Speech provider: elevenlabs, openai (any OpenAI-compatible /v1/audio/speech server) or command (a local program such as piper or espeak-ng)")
+	flags.StringVar(&f.providerURL, "provider-url", "", "Base URL of the OpenAI-compatible server (default https://api.openai.com)")
+	flags.StringVar(&f.command, "command", client.DefaultCommand, "Program run by the command provider, reading text on stdin and writing mp3 or WAV to stdout; {voice} is replaced with the voice")
+	flags.StringVar(&f.apiURL, "api-url", "", "Eleven Labs API base URL (default "+client.DefaultBaseURL+", or XI_API_BASE_URL)")
+	flags.StringVar(&f.modelID, "model", "", "Model ID (default "+client.DefaultModelID+" for elevenlabs, "+client.DefaultOpenAIModel+" for openai)")
+	flags.StringVar(&f.format, "format", "", "Eleven Labs output format, e.g. mp3_44100_128 or pcm_24000 (saved as WAV)")
+	flags.StringArrayVar(&f.dictionaries, "dict", nil, "Pronunciation dictionary as ID[:versionID] (repeatable, up to 3)")
+	flags.StringVar(&f.language, "lang", "none", "Text normalization rules for numbers, dates, units and links: en, de, or none to send the text as written")
+	flags.StringVar(&f.replacements, "replacements", "", "File of `pattern => replacement` regular expressions applied before synthesis")
+	flags.BoolVar(&f.spellAcronyms, "spell-acronyms", false, "Spell out words written in capitals, e.g. API as A P I")
+	flags.BoolVar(&f.showNormalized, "show-normalized", false, "Print the text that would be spoken, without synthesizing it")
+	flags.StringVar(&f.tag, "tag", "", "Project the usage is charged to in the usage ledger")
+	flags.BoolVar(&f.post.TrimSilence, "trim-silence", false, "Trim silence from the start and end of the audio")
+	flags.Float64Var(&f.post.Loudness, "loudness", 0, "Normalize to this integrated loudness in LUFS, e.g. -16 (mp3 needs ffmpeg)")
+	flags.DurationVar(&f.post.ChunkPause, "chunk-pause", 0, "Silence between requests")
+	flags.DurationVar(&f.post.ParagraphPause, "paragraph-pause", 0, "Silence between paragraphs, voicing each on its own")
+	flags.DurationVar(&f.post.HeadingPause, "heading-pause", 0, "Silence either side of a heading, voicing each section on its own")
+	flags.StringVar(&f.post.Intro, "intro", "", "Audio file played before the speech (mp3 needs ffmpeg)")
+	flags.StringVar(&f.post.Outro, "outro", "", "Audio file played after the speech")
+	flags.StringVar(&f.post.Bed, "bed", "", "Music looped under the speech, ducked while it speaks")
+	flags.Float64Var(&f.post.BedVolume, "bed-volume", -18, "Level of the bed in dB")
+	flags.Float64Var(&f.post.Duck, "duck", -12, "How much further the bed dips under speech, in dB")
+	flags.DurationVar(&f.post.Fade, "fade", 2*time.Second, "How long the bed fades in before the speech and out after it")
+}
+
+// chosenProvider returns the provider given with --provider, or "" to use the profile's
+func (f *synthesisFlags) chosenProvider(cmd *cobra.Command) string {
+	if cmd.Flags().Changed("provider") {
+		return f.provider
+	}
+	return ""
+}
+
+// apply sets the flags on cfg. Flags that a profile can also set only replace the profile's value when given.
+func (f *synthesisFlags) apply(cmd *cobra.Command, cfg *config.AppConfig) error {
+	override := func(name string, dst *string, value string) {
+		if cmd.Flags().Changed(name) || *dst == "" {
+			*dst = value
+		}
+	}
+	cfg.JobName = f.jobName
+	cfg.CoverArt = f.coverArt
+	cfg.Subtitles = f.subtitles
+	override("model", &cfg.ModelID, f.modelID)
+	override("provider", &cfg.Provider, f.provider)
+	override("provider-url", &cfg.ProviderURL, f.providerURL)
+	override("command", &cfg.Command, f.command)
+	override("api-url", &cfg.BaseURL, f.apiURL)
+	override("format", &cfg.OutputFormat, f.format)
+	override("lang", &cfg.Language, f.language)
+	cfg.Replacements = f.replacements
+	cfg.SpellAcronyms = f.spellAcronyms
+	cfg.Tag = f.tag
+	cfg.Post = f.post
+	if err := client.CheckOutputFormat(cfg.OutputFormat); err != nil {
+		return err
+	}
+	if err := client.CheckPostProcessing(cfg.Post); err != nil {
+		return err
+	}
+	return applyDictionaries(cmd, cfg, f.dictionaries)
+}
+
+// playFlags play the finished audio, instead of or as well as saving it
+type playFlags struct {
+	play   bool
+	noSave bool
+}
+
+func (f *playFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.play, "play", false, "Play the audio when it is done, with ffplay, mpv, paplay, pw-play, aplay or afplay")
+	cmd.Flags().BoolVar(&f.noSave, "no-save", false, "Only play the audio, without writing a file (implies --play)")
+}
+
+// apply sets up the pipeline to play its audio, and not to save it with --no-save
+func (f *playFlags) apply(p *client.Pipeline) {
+	if f.play || f.noSave {
+		p.Play = playback.Play
+	}
+	p.NoSave = f.noSave
+}
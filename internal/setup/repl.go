@@ -0,0 +1,72 @@
+package setup
+
+import (
+	"errors"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/playback"
+	"github.com/sgerhardt/chatter/internal/repl"
+	"github.com/spf13/cobra"
+)
+
+func newReplCmd(g *globalFlags) *cobra.Command {
+	var voiceID string
+	var noPlay bool
+	var flags synthesisFlags
+
+	cmd := &cobra.Command{
+		Use:   "repl",
+		Short: "Voice lines interactively, tuning the voice and settings between takes",
+		Long: `Repl keeps the configuration loaded and voices each line typed, playing it straight away with ffplay, mpv,
+paplay, pw-play, aplay or afplay. Change the voice, model and settings between takes with :commands, replay, redo or
+save earlier takes and list the session's history. Type :help for the commands.
+
+  > Welcome back to the show.
+  take 1
+  > :set stability 0.3
+  > :redo
+  take 2
+  > :save 2 intro.mp3`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := loadConfig(g.envFile, flags.chosenProvider(cmd), g)
+			if err != nil {
+				return err
+			}
+			if voiceID != "" {
+				cfg.VoiceID = voiceID
+			}
+			if cfg.VoiceID == "" {
+				return errors.New("voice is required")
+			}
+			if err = flags.apply(cmd, cfg); err != nil {
+				return err
+			}
+			httpClient := newHTTPClient()
+			s := &repl.Session{
+				Config: cfg,
+				Render: func(cfg *config.AppConfig) (*client.Rendering, error) {
+					p, pErr := newPipeline(g, cfg, httpClient)
+					if pErr != nil {
+						return nil, pErr
+					}
+					r, rErr := p.WithContext(cmd.Context()).Render()
+					p.RecordUsage("")
+					return r, rErr
+				},
+				Out: cmd.OutOrStdout(),
+			}
+			if !noPlay {
+				s.Play = func(data []byte) error {
+					return playback.Play(cmd.Context(), data)
+				}
+			}
+			_, _ = cmd.OutOrStdout().Write([]byte("Type a line to voice it, or :help\n"))
+			return s.Run(cmd.InOrStdin())
+		},
+	}
+	cmd.Flags().StringVarP(&voiceID, "voice", "v", "", "Voice ID to start with (default the profile's voice)")
+	cmd.Flags().BoolVar(&noPlay, "no-play", false, "Don't play takes, only keep them to save")
+	flags.registerExcept(cmd, "subtitles", "show-normalized")
+	return cmd
+}
@@ -0,0 +1,57 @@
+package setup
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplCmd(t *testing.T) { // nolint:paralleltest
+	s := fakeeleven.New(t, fakeeleven.WithAPIKey("key-1234"))
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(envFile, nil, 0600))
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XI_API_BASE_URL", s.URL)
+	t.Setenv("XI_API_KEY", "key-1234")
+	t.Setenv("OUTPUT", dir)
+	for _, key := range []string{"CHATTER_CONFIG", "CHATTER_PROFILE", "CHATTER_USAGE_DB"} {
+		t.Setenv(key, "")
+	}
+
+	cmd := NewRootCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetIn(strings.NewReader("Hello there\n:set stability 0.2\n:redo\n:save 2\n"))
+	cmd.SetArgs([]string{"repl", "--env-file", envFile, "-v", fakeeleven.DefaultVoices[0].VoiceID, "--no-play", "--tag", "pilot"})
+	require.NoError(t, cmd.Execute())
+
+	assert.Contains(t, out.String(), "take 2\n")
+	assert.Contains(t, out.String(), "saved "+filepath.Join(dir, "take-2.mp3"))
+	requests := s.Requests()
+	// each take also looks up the voice name for the artist tag
+	require.Len(t, requests, 4)
+	assert.Contains(t, string(requests[2].Body), `"stability":0.2`)
+	_, err := os.Stat(filepath.Join(dir, "take-2.mp3"))
+	require.NoError(t, err)
+
+	out.Reset()
+	cmd = NewRootCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"usage", "--tag", "pilot"})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "pilot  2         22")
+
+	// takes are only kept in memory, so there are no subtitles to write or text to show
+	for _, flag := range []string{"--subtitles", "--show-normalized"} {
+		cmd = NewRootCmd()
+		cmd.SetIn(strings.NewReader(""))
+		cmd.SetArgs([]string{"repl", "--env-file", envFile, "-v", fakeeleven.DefaultVoices[0].VoiceID, "--no-play", flag})
+		assert.ErrorContains(t, cmd.Execute(), "unknown flag", flag)
+	}
+}
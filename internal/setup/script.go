@@ -0,0 +1,93 @@
+package setup
+
+import (
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/script"
+	"github.com/spf13/cobra"
+	"strings"
+	"time"
+)
+
+func newScriptCmd(g *globalFlags) *cobra.Command {
+	var castFile string
+	var cast []string
+	var gap time.Duration
+	var flags synthesisFlags
+	var play playFlags
+
+	cmd := &cobra.Command{
+		Use:   "script <file>",
+		Short: "Voice a dialogue or screenplay with a different voice per speaker",
+		Long: `Script reads a dialogue and voices each line with its speaker's voice, joining the lines into one file.
+
+Scripts are either plain text with one "SPEAKER: line" per line, or a YAML/JSON file:
+  title: Episode 1
+  gap: 500ms
+  cast:
+    alice: {voice_id: <voiceID>, voice_settings: {stability: 0.5, similarity_boost: 0.75}}
+  lines:
+    - {speaker: alice, text: Hello there, gap: 1s}
+
+Speakers can also be cast from a separate YAML/JSON file with --cast-file, or with --cast NAME=<voiceID>.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := script.Load(args[0])
+			if err != nil {
+				return err
+			}
+			if castFile != "" {
+				if err = s.LoadCast(castFile); err != nil {
+					return err
+				}
+			}
+			for _, c := range cast {
+				name, voiceID, ok := strings.Cut(c, "=")
+				if !ok || name == "" || voiceID == "" {
+					return fmt.Errorf("invalid cast %q, expected NAME=<voiceID>", c)
+				}
+				speaker, _ := s.Speaker(name)
+				speaker.VoiceID = voiceID
+				s.AddSpeaker(name, speaker)
+			}
+			if cmd.Flags().Changed("gap") {
+				s.Gap = gap
+			}
+			if err = s.Validate(); err != nil {
+				return err
+			}
+
+			cfg, err := loadConfig(g.envFile, flags.chosenProvider(cmd), g)
+			if err != nil {
+				return err
+			}
+			if err = flags.apply(cmd, cfg); err != nil {
+				return err
+			}
+			c, err := newPipeline(g, cfg, newHTTPClient())
+			if err != nil {
+				return err
+			}
+			if flags.showNormalized {
+				normalized, nErr := c.NormalizedScript(s)
+				if nErr != nil {
+					return nErr
+				}
+				for _, line := range normalized.Lines {
+					if _, err = fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", line.Speaker, line.Text); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			play.apply(c)
+			return c.WithContext(cmd.Context()).ProcessScript(s)
+		},
+	}
+
+	cmd.Flags().StringVar(&castFile, "cast-file", "", "YAML/JSON file mapping speakers to voices")
+	cmd.Flags().StringArrayVar(&cast, "cast", nil, "Cast a speaker as NAME=<voiceID> (repeatable)")
+	cmd.Flags().DurationVar(&gap, "gap", script.DefaultGap, "Silence between lines")
+	flags.register(cmd)
+	play.register(cmd)
+	return cmd
+}
@@ -0,0 +1,172 @@
+package setup
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/sgerhardt/chatter/internal/jobs"
+	"github.com/sgerhardt/chatter/internal/profile"
+	"github.com/sgerhardt/chatter/internal/server"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"github.com/sgerhardt/chatter/internal/usage"
+	"github.com/spf13/cobra"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func newServeCmd(g *globalFlags) *cobra.Command {
+	var addr string
+	var voiceID string
+	var tokens []string
+	var opts server.Options
+	var aliasFile string
+	var database string
+	var flags synthesisFlags
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run chatter as an HTTP service shared by a team",
+		Long: `Serve runs the chatter pipeline as an HTTP service, so callers don't each need an Eleven Labs API key.
+
+Callers send "Authorization: Bearer <token>" with one of the tokens given by --token or CHATTER_SERVE_TOKENS
+(comma separated). Conversions run as background jobs, kept in a SQLite database (--db):
+  POST /v1/synthesize   {"text": "...", "voice_id": "...", "model_id": "...", "voice_settings": {...},
+                         "output_format": "...", "tag": "...", "webhook_url": "https://..."}
+  POST /v1/convert      {"url": "https://...", ...the same options}
+  POST /v1/documents    a text/plain, text/markdown or text/html body, with the options as query parameters
+  GET  /v1/jobs/{id}                    status and progress, in chunks synthesized
+  POST /v1/jobs/{id}/cancel             stop a job
+  GET  /v1/jobs/{id}/artifacts          the files a finished job produced
+  GET  /v1/jobs/{id}/artifacts/{name}   download one, e.g. audio.mp3 or transcript.txt
+  GET  /v1/jobs/{id}/audio              the finished audio
+  GET  /v1/voices            voices available to the account
+  POST /v1/audio/speech      OpenAI-compatible speech, answered synchronously
+  GET  /metrics              Prometheus metrics: API requests, latency, errors by class, retries, characters
+                             billed and audio bytes
+  GET  /healthz              unauthenticated health check
+
+The speech endpoint maps OpenAI models (tts-1, tts-1-hd) and voices (alloy, echo, fable, onyx, nova, shimmer) onto
+Eleven Labs models and premade voices. Change or add mappings with --aliases, a YAML file:
+  models:
+    tts-1: eleven_turbo_v2_5
+  voices:
+    alloy: <voiceID>
+Other voice names are used as voice IDs. response_format may be mp3, wav or pcm, and speed is clamped to 0.7-1.2.
+
+URL jobs can't fetch pages from, and webhooks aren't delivered to, loopback, private or link-local addresses, such
+as cloud metadata services, unless --allow-private-urls is set.
+
+Every job and speech request is recorded in the usage ledger (--usage-db) with its tag, or --tag when it has none;
+report on it with chatter usage.
+
+When a job with a webhook_url finishes, its status is POSTed there as {"type": "job.succeeded" or "job.failed",
+"job": {...}}, signed with the --webhook-secret: X-Chatter-Signature is "sha256=" and the hex HMAC-SHA256 of the
+X-Chatter-Timestamp header, ".", and the body.
+
+Flags set the defaults for every job. SIGINT or SIGTERM stops accepting requests and lets running jobs finish
+their current chunk; they resume from the next chunk when the server starts again.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			cfg, err := loadConfig(g.envFile, flags.chosenProvider(cmd), g)
+			if err != nil {
+				return err
+			}
+			if voiceID != "" {
+				cfg.VoiceID = voiceID
+			}
+			if err = flags.apply(cmd, cfg); err != nil {
+				return err
+			}
+			opts.Tokens = append(tokens, splitTokens(os.Getenv("CHATTER_SERVE_TOKENS"))...)
+			if len(opts.Tokens) == 0 {
+				return errors.New("an API token is required: set --token or CHATTER_SERVE_TOKENS")
+			}
+			if aliasFile != "" {
+				aliases, aErr := server.LoadAliases(aliasFile)
+				if aErr != nil {
+					return aErr
+				}
+				opts.Aliases = &aliases
+			}
+			if opts.Jobs.WebhookSecret == "" {
+				opts.Jobs.WebhookSecret = os.Getenv("CHATTER_WEBHOOK_SECRET")
+			}
+			if database == "" {
+				dir, dErr := profile.Dir()
+				if dErr != nil {
+					return dErr
+				}
+				database = filepath.Join(dir, "jobs.db")
+			}
+			if opts.Store, err = jobs.Open(profile.ExpandHome(database)); err != nil {
+				return err
+			}
+			defer func() {
+				if cErr := opts.Store.Close(); cErr != nil && err == nil {
+					err = cErr
+				}
+			}()
+			usagePath, err := g.usagePath()
+			if err != nil {
+				return err
+			}
+			ledger, err := usage.Open(usagePath)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if cErr := ledger.Close(); cErr != nil && err == nil {
+					err = cErr
+				}
+			}()
+			opts.Ledger = ledger
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+			opts.Metrics = registry
+			s, err := server.New(cfg, newMeteredHTTPClient(telemetry.NewMetrics(registry)), opts)
+			if err != nil {
+				return err
+			}
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			slog.Info("listening", "addr", ln.Addr().String(), "workers", opts.Jobs.Workers)
+			return s.Run(ctx, ln)
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", ":8080", "Address to listen on")
+	cmd.Flags().StringVarP(&voiceID, "voice", "v", "", "Voice ID used when a request names none (default the profile's voice)")
+	cmd.Flags().StringArrayVar(&tokens, "token", nil, "API token callers must present (repeatable; prefer CHATTER_SERVE_TOKENS, which isn't visible in ps)")
+	cmd.Flags().StringVar(&aliasFile, "aliases", "", "YAML file mapping OpenAI model and voice names to Eleven Labs models and voice IDs")
+	cmd.Flags().Int64Var(&opts.MaxBodyBytes, "max-body", server.DefaultMaxBodyBytes, "Largest request body accepted, in bytes")
+	cmd.Flags().IntVar(&opts.Jobs.Workers, "workers", jobs.DefaultWorkers, "Jobs synthesized at once")
+	cmd.Flags().StringVar(&database, "db", "", "SQLite database holding jobs and their artifacts (default $XDG_CONFIG_HOME/chatter/jobs.db)")
+	cmd.Flags().StringVar(&opts.Jobs.WebhookSecret, "webhook-secret", "", "Secret that signs job webhooks; jobs can only name a webhook_url when set (prefer CHATTER_WEBHOOK_SECRET)")
+	cmd.Flags().DurationVar(&opts.Retention, "retention", server.DefaultRetention, "How long finished jobs and their artifacts are kept")
+	cmd.Flags().BoolVar(&opts.AllowPrivateURLs, "allow-private-urls", false, "Let URL jobs and webhooks reach loopback, private and link-local addresses")
+	cmd.Flags().DurationVar(&opts.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "How long to wait for requests and running chunks when stopping")
+	// jobs keep their audio and transcript as artifacts, so there are no subtitle files or normalized text to print
+	flags.registerExcept(cmd, "subtitles", "show-normalized")
+	return cmd
+}
+
+// splitTokens splits a comma separated token list, dropping empty entries
+func splitTokens(s string) []string {
+	var tokens []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,32 @@
+package setup
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeCmdRequiresToken(t *testing.T) { // nolint:paralleltest
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XI_API_KEY", "123")
+	t.Setenv("CHATTER_SERVE_TOKENS", " , ")
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"serve", "--env-file", envFile, "--addr", "127.0.0.1:0"})
+	assert.EqualError(t, cmd.Execute(), "an API token is required: set --token or CHATTER_SERVE_TOKENS")
+
+	for _, flag := range []string{"--subtitles", "--show-normalized"} {
+		cmd = NewRootCmd()
+		cmd.SetArgs([]string{"serve", "--env-file", envFile, "--token", "t", flag})
+		assert.ErrorContains(t, cmd.Execute(), "unknown flag", flag)
+	}
+}
+
+func TestSplitTokens(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []string{"a", "b"}, splitTokens(" a,,b ,"))
+	assert.Nil(t, splitTokens(""))
+}
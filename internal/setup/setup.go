@@ -6,20 +6,36 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/sgerhardt/chatter/internal/client"
 	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/profile"
+	"github.com/sgerhardt/chatter/internal/secret"
+	"github.com/sgerhardt/chatter/internal/telemetry"
 	"github.com/spf13/cobra"
-	"log"
 	"net"
 	"net/http"
 	"os"
-	"strings"
+	"path/filepath"
 	"time"
 )
 
+// Execute runs the chatter command line, then flushes any spans still waiting to be exported
+func Execute() error {
+	var global globalFlags
+	err := newRootCmd(&global).Execute()
+	return errors.Join(err, global.shutdownTracing())
+}
+
 func NewRootCmd() *cobra.Command {
+	return newRootCmd(&globalFlags{})
+}
+
+func newRootCmd(global *globalFlags) *cobra.Command {
 
 	var voiceID string
 	var textInput string
 	var siteInput string
+	var voiceName string
+	var flags synthesisFlags
+	var play playFlags
 
 	cmd := &cobra.Command{
 		Use:   "chatter -v <voiceID> {-t <text> | -s <url>}",
@@ -30,10 +46,34 @@ Usage:
   chatter -v <voiceID> -t <text>   (Provide text to convert to voice)
   chatter -v <voiceID> -s <url>    (Provide a URL to read text from)
 
-Either --text or --site is required, but not both.`,
+Either --text or --site is required, but not both.
+
+Settings are read from the environment and from a .env file in the current directory, the executable's directory
+or $XDG_CONFIG_HOME/chatter (or --env-file). Variables already set in the environment win over the file.
+
+Text may contain markup to control delivery:
+  <break time="1.5s"/>                          pause
+  <emphasis level="strong">word</emphasis>      stress a word
+  <phoneme ph="ˈtɒmɑːtəʊ">tomato</phoneme>      pronounce with IPA
+  <sub alias="World Wide Web">WWW</sub>         say something else
+  <say-as interpret-as="characters">API</say-as>   characters, cardinal, ordinal, digits, telephone, date
+  <voice name="<voiceID>">text</voice>          switch voice`,
+		Args: cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if err := global.setupLogging(cmd.ErrOrStderr()); err != nil {
+				return err
+			}
+			return global.setupTracing(cmd.Context())
+		},
 		PreRunE: func(_ *cobra.Command, _ []string) error {
 			if voiceID == "" {
-				return errors.New("voice is required")
+				_, p, err := global.selectProfile()
+				if err != nil {
+					return err
+				}
+				if p.Voice == "" {
+					return errors.New("voice is required")
+				}
 			}
 			if textInput == "" && siteInput == "" {
 				return errors.New("text or site is required")
@@ -43,15 +83,33 @@ Either --text or --site is required, but not both.`,
 			}
 			return nil
 		},
-		RunE: func(_ *cobra.Command, _ []string) error {
-			cfg, c, err := New(".env", voiceID, textInput, siteInput)
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, c, err := newWithProfile(global.envFile, global, flags.chosenProvider(cmd), voiceID, textInput, siteInput)
+			if err != nil {
+				return err
+			}
+			cfg.VoiceName = voiceName
+			if err = flags.apply(cmd, cfg); err != nil {
+				return err
+			}
+			p, err := newPipeline(global, cfg, c)
 			if err != nil {
 				return err
 			}
+			play.apply(p)
+			p = p.WithContext(cmd.Context())
+			if flags.showNormalized {
+				text, nErr := p.NormalizedText()
+				if nErr != nil {
+					return nErr
+				}
+				_, err = fmt.Fprintln(cmd.OutOrStdout(), text)
+				return err
+			}
 			if textInput != "" {
-				return client.New(cfg, c).ProcessText()
+				return p.ProcessText()
 			} else if siteInput != "" {
-				return client.New(cfg, c).ProcessSite()
+				return p.ProcessSite()
 			}
 			return errors.New("text or site is required")
 		},
@@ -59,43 +117,83 @@ Either --text or --site is required, but not both.`,
 
 	cmd.Flags().StringVarP(&textInput, "text", "t", "", "Text to convert to voice")
 	cmd.Flags().StringVarP(&siteInput, "site", "s", "", "Website to read text from")
-	cmd.Flags().StringVarP(&voiceID, "voice", "v", "", "Voice ID to use")
-	if err := cmd.MarkFlagRequired("voice"); err != nil {
-		log.Fatal(err)
-	}
+	cmd.Flags().StringVarP(&voiceID, "voice", "v", "", "Voice ID to use (default the profile's voice)")
+	cmd.Flags().StringVar(&voiceName, "voice-name", "", "Voice name written as the artist tag (default the name Eleven Labs has for the voice, or its ID)")
+	flags.register(cmd)
+	play.register(cmd)
+	global.register(cmd)
+
+	cmd.AddCommand(newScriptCmd(global), newDictCmd(global), newConfigCmd(global), newAuthCmd(global), newServeCmd(global), newUsageCmd(global),
+		newReplCmd(global), newSTSCmd(global), newSFXCmd(global), newVoicesCmd(global))
 
 	return cmd
 }
 
+// envFileLocations are searched in order for a .env file when none is named
+func envFileLocations() []string {
+	locations := []string{".env"}
+	if exe, err := os.Executable(); err == nil {
+		locations = append(locations, filepath.Join(filepath.Dir(exe), ".env"))
+	}
+	if dir, err := profile.Dir(); err == nil {
+		locations = append(locations, filepath.Join(dir, ".env"))
+	}
+	return locations
+}
+
+// findEnvFile returns the env file to load: filename or CHATTER_ENV_FILE, which must exist, or else the first
+// .env in envFileLocations. It returns "" when there is no env file, leaving settings to the environment.
+func findEnvFile(filename string) (string, error) {
+	if filename == "" {
+		filename = os.Getenv("CHATTER_ENV_FILE")
+	}
+	if filename != "" {
+		if _, err := os.Stat(filename); err != nil {
+			return "", fmt.Errorf("error loading env file: %w", err)
+		}
+		return filename, nil
+	}
+	for _, path := range envFileLocations() {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// readEnvFile loads the env file, if there is one, without replacing variables already set in the environment,
+// and returns the API key and output directory
 func readEnvFile(filename string) (string, string, error) {
-	err := godotenv.Load(filename)
+	path, err := findEnvFile(filename)
 	if err != nil {
-		return "", "", fmt.Errorf("error loading .env file: %v", err)
+		return "", "", err
+	}
+	if path != "" {
+		if err = godotenv.Load(path); err != nil {
+			return "", "", fmt.Errorf("error loading env file %s: %w", path, err)
+		}
 	}
 	return os.Getenv("XI_API_KEY"), os.Getenv("OUTPUT"), nil
 }
 
+// New builds the configuration from the env file, or the environment when filename is empty and no .env is found
 func New(filename string, voiceID string, textInput string, siteInput string) (*config.AppConfig, client.HTTP, error) {
-	if filename == "" || !strings.HasSuffix(filename, ".env") {
-		return nil, nil, errors.New(".env file not found")
-	}
-	key, dir, err := readEnvFile(filename)
+	return newWithProfile(filename, nil, client.ProviderElevenLabs, voiceID, textInput, siteInput)
+}
+
+// newWithProfile is New for any provider, starting from the selected config file profile
+func newWithProfile(filename string, g *globalFlags, provider, voiceID, textInput, siteInput string) (*config.AppConfig, client.HTTP, error) {
+	app, err := loadConfig(filename, provider, g)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error reading env file: %w", err)
+		return nil, nil, err
 	}
-	if key == "" {
-		return nil, nil, fmt.Errorf("API Key not found")
-	}
-
-	app := &config.AppConfig{}
-	app.APIKey = key
-	app.OutputDir = dir
-	app.CharacterRequestLimit = 10000
 
-	if voiceID == "" {
+	if voiceID != "" {
+		app.VoiceID = voiceID
+	}
+	if app.VoiceID == "" {
 		return nil, nil, errors.New("voice ID is required")
 	}
-	app.VoiceID = voiceID
 
 	if textInput == "" && siteInput == "" {
 		return nil, nil, errors.New("text or site is required")
@@ -106,14 +204,69 @@ func New(filename string, voiceID string, textInput string, siteInput string) (*
 	app.TextInput = textInput
 	app.WebsiteURL = siteInput
 
-	httpClient := &http.Client{
+	return app, newHTTPClient(), nil
+}
+
+// loadConfig resolves the settings shared by every command. Eleven Labs requires an API key, from XI_API_KEY,
+// the profile or the key stored by auth login; the OpenAI provider reads OPENAI_API_KEY if set, and local
+// commands need no key.
+func loadConfig(filename, provider string, g *globalFlags) (*config.AppConfig, error) {
+	app, err := resolveConfig(filename, provider, g)
+	if err != nil {
+		return nil, err
+	}
+	if provider == "" {
+		provider = app.Provider
+	}
+	if provider != "" && provider != client.ProviderElevenLabs {
+		return app, nil
+	}
+	if app.APIKey == "" {
+		key, sErr := openKeyStore().Get()
+		if sErr != nil && !errors.Is(sErr, secret.ErrNotFound) {
+			return nil, fmt.Errorf("error reading stored API key: %w", sErr)
+		}
+		app.APIKey = key
+	}
+	if app.APIKey == "" {
+		return nil, fmt.Errorf("API Key not found")
+	}
+	return app, nil
+}
+
+// newPipeline returns a pipeline that synthesizes with the configured provider, recording its usage in the ledger
+func newPipeline(g *globalFlags, cfg *config.AppConfig, httpClient client.HTTP) (*client.Pipeline, error) {
+	synth, err := client.NewSynthesizer(cfg, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	p := client.NewPipeline(cfg, httpClient, synth)
+	path, err := g.usagePath()
+	if err != nil {
+		return nil, err
+	}
+	p.Ledger = ledgerFile(path)
+	return p, nil
+}
+
+func newHTTPClient() *http.Client {
+	return newMeteredHTTPClient(nil)
+}
+
+// newMeteredHTTPClient returns a client that logs its requests, retries rate limited ones and, when metrics is set,
+// records them for Prometheus
+func newMeteredHTTPClient(metrics *telemetry.Metrics) *http.Client {
+	return &http.Client{
 		Timeout: time.Second * 310,
-		Transport: &http.Transport{
-			DialContext:           (&net.Dialer{Timeout: time.Second * 3}).DialContext,
-			TLSHandshakeTimeout:   time.Second * 3,
-			ResponseHeaderTimeout: time.Second * 300, // eleven labs doesn't appear to respond with the header until the request completes
+		Transport: &telemetry.Transport{
+			Base: &http.Transport{
+				DialContext:           (&net.Dialer{Timeout: time.Second * 3}).DialContext,
+				TLSHandshakeTimeout:   time.Second * 3,
+				ResponseHeaderTimeout: time.Second * 300, // eleven labs doesn't appear to respond with the header until the request completes
+			},
+			Metrics: metrics,
+			Retries: 2,
+			Backoff: time.Second,
 		},
 	}
-
-	return app, httpClient, nil
 }
@@ -1,10 +1,13 @@
 package setup
 
 import (
+	"github.com/sgerhardt/chatter/internal/audio"
 	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -19,24 +22,49 @@ func TestRootCmdErrors(t *testing.T) { // nolint:paralleltest
 	}{
 		{
 			name:     "missing voice flag",
-			args:     []string{"chatter", "--text", "Hello World"},
+			args:     []string{"--text", "Hello World"},
 			errorMsg: "voice is required",
 		},
 		{
 			name:     "both text and site provided",
-			args:     []string{"chatter", "--voice", "123", "--text", "Hello World", "--site", "https://example.com"},
+			args:     []string{"--voice", "123", "--text", "Hello World", "--site", "https://example.com"},
 			errorMsg: "only one of text or site can be provided",
 		},
 		{
-			name:     "text flag set and .env not found",
-			args:     []string{"chatter", "--voice", "123", "--text", "Hello World"},
-			errorMsg: ".env: no such file or directory",
+			name:     "text flag set and no API key in the environment or a .env file",
+			args:     []string{"--voice", "123", "--text", "Hello World"},
+			errorMsg: "API Key not found",
+		},
+		{
+			name:     "env file flag names a missing file",
+			args:     []string{"--voice", "123", "--text", "Hello World", "--env-file", "missing.env"},
+			errorMsg: "missing.env: no such file or directory",
+		},
+		{
+			name:     "verbose and quiet",
+			args:     []string{"--voice", "123", "--text", "Hello World", "--verbose", "--quiet"},
+			errorMsg: "only one of verbose or quiet can be set",
+		},
+		{
+			name:     "mistyped subcommand",
+			args:     []string{"voise", "list"},
+			errorMsg: `unknown command "voise" for "chatter"`,
+		},
+		{
+			name:     "unknown log format",
+			args:     []string{"--voice", "123", "--text", "Hello World", "--log-format", "xml"},
+			errorMsg: `unknown log format "xml"`,
 		},
 	}
 
 	for _, tt := range tests { // nolint:paralleltest
 		t.Run(tt.name, func(t *testing.T) {
 			t.Cleanup(func() { os.Args = originalArgs })
+			// Keep config and env files on the machine running the tests from supplying a voice or key
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv("XI_API_KEY", "")
+			// and the real keyring from supplying a stored key
+			t.Setenv("CHATTER_KEYSTORE", "file")
 
 			// Set the command-line arguments for the test
 			os.Args = append([]string{"chatter"}, tt.args...)
@@ -51,6 +79,49 @@ func TestRootCmdErrors(t *testing.T) { // nolint:paralleltest
 	}
 }
 
+func TestRootCmdPlay(t *testing.T) { // nolint:paralleltest
+	s := fakeeleven.New(t, fakeeleven.WithAPIKey("key-1234"))
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(envFile, nil, 0600))
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XI_API_BASE_URL", s.URL)
+	t.Setenv("XI_API_KEY", "key-1234")
+	for _, key := range []string{"CHATTER_CONFIG", "CHATTER_PROFILE", "CHATTER_USAGE_DB"} {
+		t.Setenv(key, "")
+	}
+	// an mpv on PATH that copies what it plays
+	bin := t.TempDir()
+	played := filepath.Join(bin, "played")
+	script := "#!/bin/sh\nPATH=/usr/bin:/bin cat > " + played + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "mpv"), []byte(script), 0755))
+	t.Setenv("PATH", bin)
+
+	for _, noSave := range []bool{false, true} {
+		output := t.TempDir()
+		t.Setenv("OUTPUT", output)
+		require.NoError(t, os.RemoveAll(played))
+		args := []string{"--env-file", envFile, "-v", fakeeleven.DefaultVoices[0].VoiceID, "-t", "Hello there", "--play"}
+		if noSave {
+			args = append(args[:len(args)-1], "--no-save")
+		}
+		cmd := NewRootCmd()
+		cmd.SetArgs(args)
+		require.NoError(t, cmd.Execute())
+
+		data, err := os.ReadFile(played)
+		require.NoError(t, err)
+		assert.Equal(t, audio.MP3, audio.Detect(data))
+		files, err := filepath.Glob(filepath.Join(output, "*.mp3"))
+		require.NoError(t, err)
+		if noSave {
+			assert.Empty(t, files)
+		} else {
+			assert.Len(t, files, 1)
+		}
+	}
+}
+
 func TestNew(t *testing.T) {
 	// nolint:paralleltest
 	// This test deals with setting os-level env vars, which is not supported in parallel tests
@@ -67,9 +138,9 @@ func TestNew(t *testing.T) {
 		expected expected
 	}{
 		{
-			name: "errors when .env file not present",
+			name: "falls back to the environment when no .env file is present",
 			expected: expected{
-				errString: ".env file not found",
+				errString: "API Key not found",
 				cfg:       &config.AppConfig{},
 			},
 			envFile: "",
@@ -138,3 +209,37 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestNewWithoutEnvFile(t *testing.T) { // nolint:paralleltest
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XI_API_KEY", "from-environment")
+	t.Setenv("OUTPUT", "/tmp/out")
+
+	cfg, _, err := New("", "voice", "hello", "")
+	require.NoError(t, err)
+	assert.Equal(t, "from-environment", cfg.APIKey)
+	assert.Equal(t, "/tmp/out", cfg.OutputDir)
+
+	envFile := filepath.Join(t.TempDir(), "prod.env")
+	require.NoError(t, os.WriteFile(envFile, []byte("XI_API_KEY=from-file\nXI_API_BASE_URL=http://localhost:8080\n"), 0600))
+	t.Setenv("XI_API_BASE_URL", "") // restored after the test
+	require.NoError(t, os.Unsetenv("XI_API_BASE_URL"))
+	cfg, _, err = New(envFile, "voice", "hello", "")
+	require.NoError(t, err)
+	assert.Equal(t, "from-environment", cfg.APIKey, "the environment wins over the file")
+	assert.Equal(t, "http://localhost:8080", cfg.BaseURL, "the file fills in what the environment lacks")
+}
+
+func TestParseDictionaries(t *testing.T) {
+	t.Parallel()
+
+	locators, err := parseDictionaries([]string{"dict1:v1, dict2", "dict3"})
+	require.NoError(t, err)
+	assert.Equal(t, []config.DictionaryLocator{{ID: "dict1", VersionID: "v1"}, {ID: "dict2"}, {ID: "dict3"}}, locators)
+
+	_, err = parseDictionaries([]string{"a,b,c,d"})
+	assert.EqualError(t, err, "at most 3 pronunciation dictionaries can be used, got 4")
+
+	_, err = parseDictionaries([]string{":v1"})
+	assert.EqualError(t, err, `invalid dictionary ":v1", expected ID[:versionID]`)
+}
@@ -0,0 +1,115 @@
+package setup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"time"
+)
+
+func newSFXCmd(g *globalFlags) *cobra.Command {
+	var batch string
+	var seconds float64
+	var influence float64
+	var flags synthesisFlags
+	var play playFlags
+
+	cmd := &cobra.Command{
+		Use:   `sfx ["prompt"]`,
+		Short: "Generate sound effects from descriptions",
+		Long: `Sfx generates a sound effect from a description with Eleven Labs' sound generation model, writing it to the
+output directory named after the prompt, e.g. door-creaks-open.mp3, numbered when the name is taken.
+
+--duration sets the length in seconds, from 0.5 to 22, otherwise the model decides. --prompt-influence, from 0 to 1,
+is how closely the sound follows the prompt rather than varying on it. --batch generates a sound for each line of a
+file, skipping blank lines and lines starting with #.
+
+  chatter sfx "door creaks open" --duration 3 --prompt-influence 0.5
+  chatter sfx --batch ui-sounds.txt --duration 1`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prompts, err := sfxPrompts(args, batch)
+			if err != nil {
+				return err
+			}
+			req := client.SoundRequest{Duration: time.Duration(seconds * float64(time.Second)), PromptInfluence: influence}
+			for _, prompt := range prompts {
+				req.Prompt = prompt
+				if err = req.Check(); err != nil {
+					return err
+				}
+			}
+			cfg, err := loadConfig(g.envFile, client.ProviderElevenLabs, g)
+			if err != nil {
+				return err
+			}
+			if err = flags.apply(cmd, cfg); err != nil {
+				return err
+			}
+			// only Eleven Labs generates sound effects, whatever the profile's provider
+			cfg.Provider = client.ProviderElevenLabs
+			p, err := newPipeline(g, cfg, newHTTPClient())
+			if err != nil {
+				return err
+			}
+			play.apply(p)
+			p = p.WithContext(cmd.Context())
+			for i, prompt := range prompts {
+				req.Prompt = prompt
+				name, pErr := p.ProcessSound(req)
+				if pErr != nil {
+					if len(prompts) > 1 {
+						return fmt.Errorf("prompt %d of %d %q: %w", i+1, len(prompts), prompt, pErr)
+					}
+					return pErr
+				}
+				if name != "" {
+					_, _ = fmt.Fprintln(cmd.OutOrStdout(), name)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&batch, "batch", "", "File of prompts, one per line, each generated in turn")
+	cmd.Flags().Float64Var(&seconds, "duration", 0, "Length of the sound in seconds, 0.5 to 22 (default chosen by the model)")
+	cmd.Flags().Float64Var(&influence, "prompt-influence", client.DefaultPromptInfluence, "How closely the sound follows the prompt, 0 to 1")
+	flags.registerOnly(cmd, "api-url", "format", "tag")
+	play.register(cmd)
+	return cmd
+}
+
+// sfxPrompts returns the prompt argument, or the prompts listed in the batch file
+func sfxPrompts(args []string, batch string) ([]string, error) {
+	switch {
+	case len(args) > 0 && batch != "":
+		return nil, errors.New("give a prompt or --batch, not both")
+	case len(args) > 0:
+		return args, nil
+	case batch == "":
+		return nil, errors.New("a prompt or --batch is required")
+	}
+	f, err := os.Open(batch)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var prompts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			prompts = append(prompts, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("%s has no prompts", batch)
+	}
+	return prompts, nil
+}
@@ -0,0 +1,71 @@
+package setup
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSFXCmd(t *testing.T) { // nolint:paralleltest
+	s := fakeeleven.New(t)
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(envFile, nil, 0600))
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XI_API_BASE_URL", s.URL)
+	t.Setenv("XI_API_KEY", "key-1234")
+	t.Setenv("OUTPUT", dir)
+	for _, key := range []string{"CHATTER_CONFIG", "CHATTER_PROFILE", "CHATTER_USAGE_DB"} {
+		t.Setenv(key, "")
+	}
+
+	var out bytes.Buffer
+	cmd := NewRootCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"sfx", "--env-file", envFile, "door creaks open", "--duration", "3", "--prompt-influence", "0.5"})
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, filepath.Join(dir, "door-creaks-open.mp3")+"\n", out.String())
+	requests := s.Requests()
+	require.Len(t, requests, 1)
+	assert.JSONEq(t, `{"text":"door creaks open","duration_seconds":3,"prompt_influence":0.5}`, string(requests[0].Body))
+
+	batch := filepath.Join(dir, "prompts.txt")
+	require.NoError(t, os.WriteFile(batch, []byte("# menu\nbutton click\n\nmenu whoosh\n"), 0600))
+	out.Reset()
+	cmd = NewRootCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"sfx", "--env-file", envFile, "--batch", batch, "--duration", "1", "--tag", "ui"})
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, filepath.Join(dir, "button-click.mp3")+"\n"+filepath.Join(dir, "menu-whoosh.mp3")+"\n", out.String())
+	assert.Len(t, s.Requests(), 3)
+
+	out.Reset()
+	cmd = NewRootCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"usage", "--tag", "ui"})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "ui     2         80")
+
+	for _, args := range [][]string{
+		{},
+		{"rain", "--batch", batch},
+		{"rain", "--duration", "30"},
+		{"rain", "--prompt-influence", "2"},
+		{"rain", "--format", "ogg"},
+	} {
+		cmd = NewRootCmd()
+		cmd.SetArgs(append([]string{"sfx", "--env-file", envFile}, args...))
+		assert.Error(t, cmd.Execute(), args)
+	}
+	// speech options don't apply to sound effects
+	for _, flag := range []string{"--subtitles", "--model=eleven_multilingual_v2", "--trim-silence", "--provider=openai"} {
+		cmd = NewRootCmd()
+		cmd.SetArgs([]string{"sfx", "--env-file", envFile, "rain", flag})
+		assert.ErrorContains(t, cmd.Execute(), "unknown flag", flag)
+	}
+	assert.Len(t, s.Requests(), 3)
+}
@@ -0,0 +1,84 @@
+package setup
+
+import (
+	"errors"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+func newSTSCmd(g *globalFlags) *cobra.Command {
+	var input string
+	var voiceID string
+	var stability, similarity, style float64
+	var segment time.Duration
+	var flags synthesisFlags
+	var play playFlags
+
+	cmd := &cobra.Command{
+		Use:   "sts --input <recording> -v <voiceID>",
+		Short: "Voice a recording with another voice, keeping its delivery",
+		Long: `Sts sends a recording, mp3 or WAV, to Eleven Labs' speech-to-speech model and writes it spoken with another
+voice, keeping the timing and intonation of the original. Recordings longer than --max-segment are cut at pauses,
+voiced a piece at a time and joined again; cutting an mp3 recording needs ffmpeg.
+
+The voice settings are the profile's unless given with --stability, --similarity and --style.
+
+  chatter sts --input interview.wav -v <voiceID> --stability 0.6`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			recording, err := os.ReadFile(input)
+			if err != nil {
+				return err
+			}
+			cfg, err := loadConfig(g.envFile, client.ProviderElevenLabs, g)
+			if err != nil {
+				return err
+			}
+			if voiceID != "" {
+				cfg.VoiceID = voiceID
+			}
+			if cfg.VoiceID == "" {
+				return errors.New("voice is required")
+			}
+			if err = flags.apply(cmd, cfg); err != nil {
+				return err
+			}
+			// only Eleven Labs voices recordings, whatever the profile's provider
+			cfg.Provider = client.ProviderElevenLabs
+			// a profile's model is a text-to-speech one
+			if !cmd.Flags().Changed("model") {
+				cfg.ModelID = ""
+			}
+			if cmd.Flags().Changed("stability") {
+				cfg.VoiceSettings.Stability = stability
+			}
+			if cmd.Flags().Changed("similarity") {
+				cfg.VoiceSettings.SimilarityBoost = similarity
+			}
+			if cmd.Flags().Changed("style") {
+				cfg.VoiceSettings.Style = style
+			}
+			p, err := newPipeline(g, cfg, newHTTPClient())
+			if err != nil {
+				return err
+			}
+			p.SegmentLength = segment
+			play.apply(p)
+			return p.WithContext(cmd.Context()).ProcessRecording(input, recording)
+		},
+	}
+	cmd.Flags().StringVarP(&input, "input", "i", "", "Recording to voice, mp3 or WAV")
+	cmd.Flags().StringVarP(&voiceID, "voice", "v", "", "Voice ID to speak with (default the profile's voice)")
+	cmd.Flags().Float64Var(&stability, "stability", 0, "Voice stability, 0 to 1")
+	cmd.Flags().Float64Var(&similarity, "similarity", 0, "Voice similarity boost, 0 to 1")
+	cmd.Flags().Float64Var(&style, "style", 0, "Voice style exaggeration, 0 to 1")
+	cmd.Flags().DurationVar(&segment, "max-segment", client.DefaultSegmentLength, "Longest piece of the recording sent in one request")
+	_ = cmd.MarkFlagRequired("input")
+	flags.registerOnly(cmd, "name", "cover", "api-url", "model", "format", "tag", "trim-silence", "loudness", "intro",
+		"outro", "bed", "bed-volume", "duck", "fade")
+	play.register(cmd)
+	cmd.Flags().Lookup("model").Usage = "Model ID (default " + client.DefaultSTSModelID + ")"
+	return cmd
+}
@@ -0,0 +1,70 @@
+package setup
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSTSCmd(t *testing.T) { // nolint:paralleltest
+	s := fakeeleven.New(t, fakeeleven.WithAPIKey("key-1234"))
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(envFile, nil, 0600))
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XI_API_BASE_URL", s.URL)
+	t.Setenv("XI_API_KEY", "key-1234")
+	t.Setenv("OUTPUT", dir)
+	for _, key := range []string{"CHATTER_CONFIG", "CHATTER_PROFILE", "CHATTER_USAGE_DB"} {
+		t.Setenv(key, "")
+	}
+	input := filepath.Join(dir, "memo.mp3")
+	require.NoError(t, os.WriteFile(input, audio.Silence(2*time.Second, nil), 0600))
+
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"sts", "--env-file", envFile, "--input", input, "-v", fakeeleven.DefaultVoices[1].VoiceID,
+		"--stability", "0.7", "--tag", "dub"})
+	require.NoError(t, cmd.Execute())
+
+	requests := s.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/v1/speech-to-speech/"+fakeeleven.DefaultVoices[1].VoiceID, requests[0].Path)
+	_, params, err := mime.ParseMediaType(requests[0].Header.Get("Content-Type"))
+	require.NoError(t, err)
+	form, err := multipart.NewReader(bytes.NewReader(requests[0].Body), params["boundary"]).ReadForm(1 << 20)
+	require.NoError(t, err)
+	assert.Equal(t, []string{client.DefaultSTSModelID}, form.Value["model_id"])
+	assert.Contains(t, form.Value["voice_settings"][0], `"stability":0.7`)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_*.mp3"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	var out bytes.Buffer
+	cmd = NewRootCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"usage", "--tag", "dub"})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "dub    1         33")
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"sts", "--env-file", envFile, "-v", fakeeleven.DefaultVoices[1].VoiceID})
+	assert.EqualError(t, cmd.Execute(), `required flag(s) "input" not set`)
+
+	// text options don't apply to recordings
+	for _, flag := range []string{"--subtitles", "--dict=abc", "--lang=en", "--show-normalized", "--provider=openai", "--chunk-pause=1s"} {
+		cmd = NewRootCmd()
+		cmd.SetArgs([]string{"sts", "--env-file", envFile, "--input", input, flag})
+		assert.ErrorContains(t, cmd.Execute(), "unknown flag", flag)
+	}
+	assert.Len(t, s.Requests(), 2)
+}
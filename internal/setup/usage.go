@@ -0,0 +1,171 @@
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/profile"
+	"github.com/sgerhardt/chatter/internal/usage"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// usagePath returns the usage ledger to use: --usage-db, CHATTER_USAGE_DB or usage.db in the config dir
+func (g *globalFlags) usagePath() (string, error) {
+	path := ""
+	if g != nil {
+		path = g.usageDB
+	}
+	if path == "" {
+		path = os.Getenv("CHATTER_USAGE_DB")
+	}
+	if path == "" {
+		dir, err := profile.Dir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(dir, "usage.db")
+	}
+	return profile.ExpandHome(path), nil
+}
+
+// ledgerFile is a usage ledger opened only while recording, so a command holds no database open while it synthesizes
+type ledgerFile string
+
+func (f ledgerFile) Record(entries []client.Usage) (err error) {
+	l, err := usage.Open(string(f))
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, l.Close()) }()
+	return l.Record(entries)
+}
+
+// parseDay reads a date as YYYY-MM-DD or an RFC 3339 time. A date is midnight UTC, or the following midnight when
+// it ends a range, so the day is included.
+func parseDay(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
+}
+
+func newUsageCmd(g *globalFlags) *cobra.Command {
+	var from, to string
+	var filter usage.Filter
+	var by string
+	var format string
+	var output string
+	var price float64
+
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Report the characters billed, from the usage ledger",
+		Long: `Usage reports on the usage ledger, which records every synthesis: when, the profile, voice and model, the
+characters billed, the source, the output file, the provider's request ID and the --tag it was run with.
+
+By default it totals the selected entries by tag, or by voice, profile, model or day with --by. Give --price, the
+cost of 1,000 characters, to add a cost column. --format csv or json exports the entries themselves instead.
+
+  chatter usage --from 2024-06-01 --to 2024-06-30 --by tag --price 0.30
+  chatter usage --tag podcast --format csv -o podcast.csv`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			if filter.From, err = parseDay(from, false); err != nil {
+				return err
+			}
+			if filter.To, err = parseDay(to, true); err != nil {
+				return err
+			}
+			path, err := g.usagePath()
+			if err != nil {
+				return err
+			}
+			ledger, err := usage.Open(path)
+			if err != nil {
+				return err
+			}
+			defer func() { err = errors.Join(err, ledger.Close()) }()
+			entries, err := ledger.Query(filter)
+			if err != nil {
+				return err
+			}
+
+			w := cmd.OutOrStdout()
+			if output != "" {
+				f, cErr := os.Create(output)
+				if cErr != nil {
+					return cErr
+				}
+				defer func() { err = errors.Join(err, f.Close()) }()
+				w = f
+			}
+			switch format {
+			case "csv":
+				return usage.WriteCSV(w, entries)
+			case "json":
+				return usage.WriteJSON(w, entries)
+			case "table":
+				return writeUsageTable(w, entries, by, price)
+			}
+			return fmt.Errorf("unknown format %q, expected table, csv or json", format)
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "First day included, YYYY-MM-DD or an RFC 3339 time")
+	cmd.Flags().StringVar(&to, "to", "", "Last day included as YYYY-MM-DD, or an RFC 3339 time to stop before")
+	cmd.Flags().StringVar(&filter.VoiceID, "voice", "", "Only this voice ID")
+	cmd.Flags().StringVar(&filter.Tag, "tag", "", "Only this tag")
+	cmd.Flags().StringVar(&by, "by", usage.ByTag, "Total by "+strings.Join(usage.Groupings(), ", "))
+	cmd.Flags().StringVar(&format, "format", "table", "Output format: table, or csv or json to export the entries")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write (default stdout)")
+	cmd.Flags().Float64Var(&price, "price", 0, "Cost of 1,000 characters, adding a cost column")
+	return cmd
+}
+
+// writeUsageTable writes the totals of each group and of all entries
+func writeUsageTable(out io.Writer, entries []client.Usage, by string, price float64) error {
+	totals, err := usage.Summarize(entries, by)
+	if err != nil {
+		return err
+	}
+	all := usage.Total{Key: "total"}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	header := strings.ToUpper(by) + "\tREQUESTS\tCHARACTERS"
+	if price > 0 {
+		header += "\tCOST"
+	}
+	_, _ = fmt.Fprintln(w, header)
+	row := func(t usage.Total) {
+		key := t.Key
+		if key == "" {
+			key = "-"
+		}
+		line := fmt.Sprintf("%s\t%d\t%d", key, t.Requests, t.Characters)
+		if price > 0 {
+			line += fmt.Sprintf("\t%.2f", t.Cost(price))
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+	for _, t := range totals {
+		row(t)
+		all.Requests += t.Requests
+		all.Characters += t.Characters
+	}
+	row(all)
+	return w.Flush()
+}
@@ -0,0 +1,63 @@
+package setup
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUsageCmd(t *testing.T) {
+	t.Parallel()
+
+	db := filepath.Join(t.TempDir(), "usage.db")
+	day := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, ledgerFile(db).Record([]client.Usage{
+		{Time: day, VoiceID: "alice", Characters: 1000, Source: "text", Tag: "podcast"},
+		{Time: day, VoiceID: "bob", Characters: 3000, Source: "text"},
+		{Time: day.AddDate(0, 0, 1), VoiceID: "alice", Characters: 500, Source: "text", Tag: "podcast", RequestID: "r3"},
+	}))
+
+	run := func(args ...string) (string, error) {
+		cmd := NewRootCmd()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(append([]string{"usage", "--usage-db", db}, args...))
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("--price", "0.30")
+	require.NoError(t, err)
+	assert.Equal(t, `TAG      REQUESTS  CHARACTERS  COST
+-        1         3000        0.90
+podcast  2         1500        0.45
+total    3         4500        1.35
+`, out)
+
+	out, err = run("--by", "voice", "--to", "2024-06-01")
+	require.NoError(t, err)
+	assert.Equal(t, `VOICE  REQUESTS  CHARACTERS
+alice  1         1000
+bob    1         3000
+total  2         4000
+`, out)
+
+	export := filepath.Join(t.TempDir(), "podcast.csv")
+	_, err = run("--tag", "podcast", "--from", "2024-06-02", "--format", "csv", "-o", export)
+	require.NoError(t, err)
+	data, err := os.ReadFile(export)
+	require.NoError(t, err)
+	assert.Equal(t, `time,profile,voice_id,model,characters,source,output,request_id,tag
+2024-06-02T12:00:00Z,,alice,,500,text,,r3,podcast
+`, string(data))
+
+	_, err = run("--from", "June")
+	assert.EqualError(t, err, `invalid date "June", expected YYYY-MM-DD or RFC 3339`)
+	_, err = run("--format", "xml")
+	assert.EqualError(t, err, `unknown format "xml", expected table, csv or json`)
+}
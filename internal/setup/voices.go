@@ -0,0 +1,315 @@
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/config"
+	"github.com/spf13/cobra"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+func newVoicesCmd(g *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "voices",
+		Short: "Manage the account's voices",
+		Long: `Voices lists, clones, edits and deletes Eleven Labs voices and changes the settings they speak with by default.
+
+Samples are mp3, WAV, FLAC, OGG or M4A recordings of up to 10 MB each, at most 25 to a voice. They are checked
+before anything is uploaded.
+
+  chatter voices add --name Narrator --sample a.mp3 --sample b.mp3 --label accent=british
+  chatter voices settings set <voiceID> --stability 0.4 --speed 1.1`,
+	}
+	cmd.AddCommand(newVoicesListCmd(g), newVoicesShowCmd(g), newVoicesAddCmd(g), newVoicesEditCmd(g), newVoicesDeleteCmd(g),
+		newVoicesSettingsCmd(g))
+	return cmd
+}
+
+// parseLabels reads labels given as key=value
+func parseLabels(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	labels := map[string]string{}
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", v)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// anyChanged reports whether any of the flags was given
+func anyChanged(cmd *cobra.Command, names ...string) bool {
+	return slices.ContainsFunc(names, cmd.Flags().Changed)
+}
+
+// voiceFlags are the fields of a voice set by add and edit
+type voiceFlags struct {
+	name        string
+	description string
+	labels      []string
+	samples     []string
+	denoise     bool
+}
+
+func (f *voiceFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.name, "name", "", "Name of the voice")
+	cmd.Flags().StringVar(&f.description, "description", "", "Description of the voice")
+	cmd.Flags().StringArrayVar(&f.labels, "label", nil, "Label as key=value, e.g. accent=british (repeatable)")
+	cmd.Flags().StringArrayVar(&f.samples, "sample", nil, fmt.Sprintf("Recording to clone the voice from (repeatable, up to %d)", client.MaxVoiceSamples))
+	cmd.Flags().BoolVar(&f.denoise, "remove-background-noise", false, "Clean up background noise in the samples")
+}
+
+// edit checks the flags and loads the samples, before anything is uploaded
+func (f *voiceFlags) edit() (client.VoiceEdit, error) {
+	labels, err := parseLabels(f.labels)
+	if err != nil {
+		return client.VoiceEdit{}, err
+	}
+	samples, err := client.LoadSamples(f.samples)
+	if err != nil {
+		return client.VoiceEdit{}, err
+	}
+	return client.VoiceEdit{Name: f.name, Description: f.description, Labels: labels, Samples: samples,
+		RemoveBackgroundNoise: f.denoise}, nil
+}
+
+func newVoicesListCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the voices the account can use",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			voices, err := c.Voices()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "ID\tNAME\tCATEGORY")
+			for _, v := range voices {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", v.VoiceID, v.Name, v.Category)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newVoicesShowCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <voiceID>",
+		Short: "Show a voice with its labels, settings and samples",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			v, err := c.GetVoice(args[0])
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			_, _ = fmt.Fprintf(out, "id: %s\nname: %s\ncategory: %s\n", v.VoiceID, v.Name, v.Category)
+			if v.Description != "" {
+				_, _ = fmt.Fprintf(out, "description: %s\n", v.Description)
+			}
+			keys := make([]string, 0, len(v.Labels))
+			for key := range v.Labels {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				_, _ = fmt.Fprintf(out, "label %s: %s\n", key, v.Labels[key])
+			}
+			if v.Settings != nil {
+				writeVoiceSettings(out, *v.Settings)
+			}
+			for _, s := range v.Samples {
+				_, _ = fmt.Fprintf(out, "sample %s: %s (%s, %d bytes)\n", s.SampleID, s.FileName, s.MimeType, s.SizeBytes)
+			}
+			return nil
+		},
+	}
+}
+
+func newVoicesAddCmd(g *globalFlags) *cobra.Command {
+	var flags voiceFlags
+	cmd := &cobra.Command{
+		Use:   "add --name <name> --sample <file>...",
+		Short: "Clone a voice from recordings",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			v, err := flags.edit()
+			if err != nil {
+				return err
+			}
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			id, err := c.AddVoice(v)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "added %s from %d samples\n", id, len(v.Samples))
+			return err
+		},
+	}
+	flags.register(cmd)
+	_ = cmd.MarkFlagRequired("name")
+	_ = cmd.MarkFlagRequired("sample")
+	return cmd
+}
+
+func newVoicesEditCmd(g *globalFlags) *cobra.Command {
+	var flags voiceFlags
+	cmd := &cobra.Command{
+		Use:   "edit <voiceID>",
+		Short: "Rename a voice, change its description or labels, or add samples to it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !anyChanged(cmd, "name", "description", "label", "sample", "remove-background-noise") {
+				return errors.New("nothing to change, give --name, --description, --label, --sample or --remove-background-noise")
+			}
+			v, err := flags.edit()
+			if err != nil {
+				return err
+			}
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			if err = c.EditVoice(args[0], v); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "edited %s\n", args[0])
+			return err
+		},
+	}
+	flags.register(cmd)
+	return cmd
+}
+
+func newVoicesDeleteCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <voiceID>",
+		Short: "Delete a voice from the account",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			if err = c.DeleteVoice(args[0]); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "deleted %s\n", args[0])
+			return err
+		},
+	}
+}
+
+func newVoicesSettingsCmd(g *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "settings",
+		Short: "Show or change the settings a voice speaks with by default",
+	}
+	cmd.AddCommand(newVoicesSettingsGetCmd(g), newVoicesSettingsSetCmd(g))
+	return cmd
+}
+
+func newVoicesSettingsGetCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <voiceID>",
+		Short: "Show a voice's default settings",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			s, err := c.VoiceSettings(args[0])
+			if err != nil {
+				return err
+			}
+			writeVoiceSettings(cmd.OutOrStdout(), *s)
+			return nil
+		},
+	}
+}
+
+func newVoicesSettingsSetCmd(g *globalFlags) *cobra.Command {
+	var set config.VoiceSettings
+	cmd := &cobra.Command{
+		Use:   "set <voiceID>",
+		Short: "Change a voice's default settings, keeping those not given",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !anyChanged(cmd, "stability", "similarity", "style", "speaker-boost", "speed") {
+				return errors.New("nothing to change, give --stability, --similarity, --style, --speaker-boost or --speed")
+			}
+			for _, v := range []struct {
+				name  string
+				value float64
+			}{{"stability", set.Stability}, {"similarity", set.SimilarityBoost}, {"style", set.Style}} {
+				if cmd.Flags().Changed(v.name) && (v.value < 0 || v.value > 1) {
+					return fmt.Errorf("%s must be between 0 and 1, got %g", v.name, v.value)
+				}
+			}
+			if cmd.Flags().Changed("speed") && (set.Speed < 0.7 || set.Speed > 1.2) {
+				return fmt.Errorf("speed must be between 0.7 and 1.2, got %g", set.Speed)
+			}
+			c, err := newAPIClient(g)
+			if err != nil {
+				return err
+			}
+			s, err := c.VoiceSettings(args[0])
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("stability") {
+				s.Stability = set.Stability
+			}
+			if cmd.Flags().Changed("similarity") {
+				s.SimilarityBoost = set.SimilarityBoost
+			}
+			if cmd.Flags().Changed("style") {
+				s.Style = set.Style
+			}
+			if cmd.Flags().Changed("speaker-boost") {
+				s.UseSpeakerBoost = set.UseSpeakerBoost
+			}
+			if cmd.Flags().Changed("speed") {
+				s.Speed = set.Speed
+			}
+			if err = c.EditVoiceSettings(args[0], *s); err != nil {
+				return err
+			}
+			writeVoiceSettings(cmd.OutOrStdout(), *s)
+			return nil
+		},
+	}
+	cmd.Flags().Float64Var(&set.Stability, "stability", 0, "Voice stability, 0 to 1")
+	cmd.Flags().Float64Var(&set.SimilarityBoost, "similarity", 0, "Voice similarity boost, 0 to 1")
+	cmd.Flags().Float64Var(&set.Style, "style", 0, "Voice style exaggeration, 0 to 1")
+	cmd.Flags().BoolVar(&set.UseSpeakerBoost, "speaker-boost", false, "Boost similarity to the original speaker")
+	cmd.Flags().Float64Var(&set.Speed, "speed", 0, "Speaking speed, 0.7 to 1.2")
+	return cmd
+}
+
+// writeVoiceSettings writes settings one per line, in the form voices settings get and show print them
+func writeVoiceSettings(out io.Writer, s config.VoiceSettings) {
+	_, _ = fmt.Fprintf(out, "stability: %g\nsimilarity: %g\nstyle: %g\nspeaker boost: %t\nspeed: %g\n", s.Stability,
+		s.SimilarityBoost, s.Style, s.UseSpeakerBoost, s.Speed)
+}
@@ -0,0 +1,95 @@
+package setup
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/audio"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVoicesCmd(t *testing.T) { // nolint:paralleltest
+	s := fakeeleven.New(t, fakeeleven.WithAPIKey("key-1234"))
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(envFile, nil, 0600))
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XI_API_BASE_URL", s.URL)
+	t.Setenv("XI_API_KEY", "key-1234")
+	for _, key := range []string{"CHATTER_CONFIG", "CHATTER_PROFILE", "CHATTER_USAGE_DB"} {
+		t.Setenv(key, "")
+	}
+	a := filepath.Join(dir, "a.mp3")
+	require.NoError(t, os.WriteFile(a, audio.Silence(time.Second, nil), 0600))
+	b := filepath.Join(dir, "b.mp3")
+	require.NoError(t, os.WriteFile(b, audio.Silence(2*time.Second, nil), 0600))
+	notes := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(notes, []byte("not a recording"), 0600))
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		cmd := NewRootCmd()
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs(append([]string{"voices", "--env-file", envFile}, args...))
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("add", "--name", "Narrator", "--sample", a, "--sample", b, "--label", "accent=british")
+	require.NoError(t, err)
+	assert.Equal(t, "added cloned0001 from 2 samples\n", out)
+
+	out, err = run("edit", "cloned0001", "--name", "Storyteller", "--sample", a)
+	require.NoError(t, err)
+	assert.Equal(t, "edited cloned0001\n", out)
+
+	out, err = run("edit", "cloned0001", "--remove-background-noise")
+	require.NoError(t, err)
+	assert.Equal(t, "edited cloned0001\n", out)
+
+	out, err = run("settings", "set", "cloned0001", "--stability", "0.3", "--speed", "1.1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "stability: 0.3\nsimilarity: 0.75\n")
+
+	out, err = run("show", "cloned0001")
+	require.NoError(t, err)
+	assert.Contains(t, out, "name: Storyteller\n")
+	assert.Contains(t, out, "label accent: british\n")
+	assert.Contains(t, out, "speed: 1.1\n")
+	assert.Equal(t, 3, strings.Count(out, "\nsample "))
+
+	out, err = run("settings", "get", "cloned0001")
+	require.NoError(t, err)
+	assert.Equal(t, "stability: 0.3\nsimilarity: 0.75\nstyle: 0\nspeaker boost: true\nspeed: 1.1\n", out)
+
+	out, err = run("list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "cloned0001")
+
+	out, err = run("delete", "cloned0001")
+	require.NoError(t, err)
+	assert.Equal(t, "deleted cloned0001\n", out)
+	out, err = run("list")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "cloned0001")
+
+	requests := len(s.Requests())
+	for _, args := range [][]string{
+		{"add", "--name", "Narrator"},
+		{"add", "--name", "Narrator", "--sample", a, "--sample", notes},
+		{"add", "--name", "Narrator", "--sample", a, "--label", "british"},
+		{"edit", fakeeleven.DefaultVoices[0].VoiceID},
+		{"settings", "set", fakeeleven.DefaultVoices[0].VoiceID},
+		{"settings", "set", fakeeleven.DefaultVoices[0].VoiceID, "--stability", "2"},
+	} {
+		_, err = run(args...)
+		assert.Error(t, err, args)
+	}
+	assert.Len(t, s.Requests(), requests)
+}
@@ -0,0 +1,165 @@
+package subtitle
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxLineLength is the longest caption line, in characters
+	MaxLineLength = 42
+	// MaxLines is the number of lines shown on screen at once
+	MaxLines = 2
+	// MaxCueDuration keeps a single caption from lingering on screen
+	MaxCueDuration = 6 * time.Second
+)
+
+// Char is a single spoken character and when it is heard
+type Char struct {
+	Text  string
+	Start time.Duration
+	End   time.Duration
+}
+
+// Cue is one caption shown between Start and End
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Lines []string
+}
+
+type word struct {
+	text  string
+	start time.Duration
+	end   time.Duration
+}
+
+// Offset shifts every character by d, used to place a chunk on the merged timeline. Characters shifted before the
+// start are placed at it.
+func Offset(chars []Char, d time.Duration) []Char {
+	out := make([]Char, len(chars))
+	for i, c := range chars {
+		out[i] = Char{Text: c.Text, Start: max(0, c.Start+d), End: max(0, c.End+d)}
+	}
+	return out
+}
+
+func words(chars []Char) []word {
+	var out []word
+	var sb strings.Builder
+	var current word
+	flush := func() {
+		if sb.Len() > 0 {
+			current.text = sb.String()
+			out = append(out, current)
+			sb.Reset()
+		}
+	}
+	for _, c := range chars {
+		if strings.TrimSpace(c.Text) == "" {
+			flush()
+			continue
+		}
+		if sb.Len() == 0 {
+			current = word{start: c.Start}
+		}
+		sb.WriteString(c.Text)
+		current.end = c.End
+	}
+	flush()
+	return out
+}
+
+func endsSentence(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+// Cues groups timed characters into readable captions of at most MaxLines lines.
+// A caption is closed when it is full, runs longer than MaxCueDuration or a sentence ends.
+func Cues(chars []Char) []Cue {
+	var cues []Cue
+	var cue *Cue
+	closeCue := func() {
+		if cue != nil {
+			cues = append(cues, *cue)
+			cue = nil
+		}
+	}
+	for _, w := range words(chars) {
+		if cue != nil && w.end-cue.Start > MaxCueDuration {
+			closeCue()
+		}
+		if cue == nil {
+			cue = &Cue{Start: w.start, Lines: []string{""}}
+		}
+		last := len(cue.Lines) - 1
+		switch {
+		case cue.Lines[last] == "":
+			cue.Lines[last] = w.text
+		case utf8.RuneCountInString(cue.Lines[last])+1+utf8.RuneCountInString(w.text) <= MaxLineLength:
+			cue.Lines[last] += " " + w.text
+		case len(cue.Lines) < MaxLines:
+			cue.Lines = append(cue.Lines, w.text)
+		default:
+			closeCue()
+			cue = &Cue{Start: w.start, Lines: []string{w.text}}
+		}
+		cue.End = w.end
+		if endsSentence(w.text) {
+			closeCue()
+		}
+	}
+	closeCue()
+	for i := range cues {
+		// Avoid overlapping captions when the next one starts before the previous ends
+		if i+1 < len(cues) && cues[i].End > cues[i+1].Start {
+			cues[i].End = cues[i+1].Start
+		}
+	}
+	return cues
+}
+
+func timestamp(d time.Duration, sep string) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// WriteSRT writes the cues in SubRip format
+func WriteSRT(w io.Writer, cues []Cue) error {
+	for i, c := range cues {
+		_, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(c.Start, ","), timestamp(c.End, ","), strings.Join(c.Lines, "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteVTT writes the cues in WebVTT format
+func WriteVTT(w io.Writer, cues []Cue) error {
+	if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+	for _, c := range cues {
+		lines := make([]string, len(c.Lines))
+		for i, l := range c.Lines {
+			lines[i] = escapeVTT(l)
+		}
+		_, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n", timestamp(c.Start, "."), timestamp(c.End, "."), strings.Join(lines, "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func escapeVTT(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,75 @@
+package subtitle_test
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/subtitle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// timed spaces the characters of text 100ms apart
+func timed(text string, from time.Duration) []subtitle.Char {
+	var chars []subtitle.Char
+	for i, r := range text {
+		start := from + time.Duration(i)*100*time.Millisecond
+		chars = append(chars, subtitle.Char{Text: string(r), Start: start, End: start + 100*time.Millisecond})
+	}
+	return chars
+}
+
+func TestCues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		chars []subtitle.Char
+		want  []subtitle.Cue
+	}{
+		{
+			name:  "splits captions at the end of a sentence",
+			chars: timed("Hi there. Bye.", 0),
+			want: []subtitle.Cue{
+				{Start: 0, End: 900 * time.Millisecond, Lines: []string{"Hi there."}},
+				{Start: time.Second, End: 1400 * time.Millisecond, Lines: []string{"Bye."}},
+			},
+		},
+		{
+			name:  "wraps long captions onto a second line",
+			chars: timed("aaaaaaaaaa bbbbbbbbbb cccccccccc dddddddddd eeeee", 0),
+			want: []subtitle.Cue{
+				{Start: 0, End: 4900 * time.Millisecond, Lines: []string{"aaaaaaaaaa bbbbbbbbbb cccccccccc", "dddddddddd eeeee"}},
+			},
+		},
+		{
+			name:  "offset places chunks on the merged timeline",
+			chars: subtitle.Offset(timed("Later.", 0), time.Minute),
+			want: []subtitle.Cue{
+				{Start: time.Minute, End: time.Minute + 600*time.Millisecond, Lines: []string{"Later."}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, subtitle.Cues(tt.chars))
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	cues := []subtitle.Cue{
+		{Start: 1500 * time.Millisecond, End: time.Hour + 2*time.Second, Lines: []string{"one <two>", "three"}},
+	}
+
+	var srt bytes.Buffer
+	require.NoError(t, subtitle.WriteSRT(&srt, cues))
+	assert.Equal(t, "1\n00:00:01,500 --> 01:00:02,000\none <two>\nthree\n\n", srt.String())
+
+	var vtt bytes.Buffer
+	require.NoError(t, subtitle.WriteVTT(&vtt, cues))
+	assert.Equal(t, "WEBVTT\n\n00:00:01.500 --> 01:00:02.000\none &lt;two&gt;\nthree\n\n", vtt.String())
+}
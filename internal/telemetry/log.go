@@ -0,0 +1,40 @@
+// Package telemetry reports what chatter does: structured logs, Prometheus metrics and the HTTP transport that
+// records both for every API request
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// NewLogger returns a logger writing records at level and above to w, as text or JSON
+func NewLogger(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+}
+
+// Level returns the level for the --verbose and --quiet flags: debug, error, or info when neither is set
+func Level(verbose, quiet bool) (slog.Level, error) {
+	switch {
+	case verbose && quiet:
+		return 0, fmt.Errorf("only one of verbose or quiet can be set")
+	case verbose:
+		return slog.LevelDebug, nil
+	case quiet:
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, nil
+}
@@ -0,0 +1,50 @@
+package telemetry_test
+
+import (
+	"bytes"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := telemetry.NewLogger(&buf, telemetry.FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+	logger.Debug("hidden")
+	logger.Info("shown", "job", "j1")
+	assert.Contains(t, buf.String(), `"msg":"shown","job":"j1"`)
+	assert.NotContains(t, buf.String(), "hidden")
+
+	buf.Reset()
+	logger, err = telemetry.NewLogger(&buf, telemetry.FormatText, slog.LevelInfo)
+	require.NoError(t, err)
+	logger.Info("shown", "job", "j1")
+	assert.Contains(t, buf.String(), "msg=shown job=j1")
+
+	_, err = telemetry.NewLogger(&buf, "xml", slog.LevelInfo)
+	require.EqualError(t, err, `unknown log format "xml", expected text or json`)
+}
+
+func TestLevel(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		verbose, quiet bool
+		want           slog.Level
+	}{
+		{want: slog.LevelInfo},
+		{verbose: true, want: slog.LevelDebug},
+		{quiet: true, want: slog.LevelError},
+	} {
+		level, err := telemetry.Level(tc.verbose, tc.quiet)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, level)
+	}
+	_, err := telemetry.Level(true, true)
+	require.EqualError(t, err, "only one of verbose or quiet can be set")
+}
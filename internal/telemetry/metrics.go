@@ -0,0 +1,72 @@
+package telemetry
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Error classes, the class label of chatter_api_errors_total
+const (
+	ClassNetwork   = "network"
+	ClassRateLimit = "rate_limit"
+	ClassAuth      = "auth"
+	ClassClient    = "client"
+	ClassServer    = "server"
+)
+
+// Metrics are the Prometheus collectors updated by Transport
+type Metrics struct {
+	requests   *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	errors     *prometheus.CounterVec
+	retries    prometheus.Counter
+	characters prometheus.Counter
+	audioBytes prometheus.Counter
+}
+
+// NewMetrics creates the collectors and registers them with reg
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chatter_api_requests_total",
+			Help: "Requests made to speech APIs, by endpoint and response code.",
+		}, []string{"endpoint", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chatter_api_request_duration_seconds",
+			Help:    "Time taken by speech API requests, from sending to reading the whole response.",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		}, []string{"endpoint"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chatter_api_errors_total",
+			Help: "Failed speech API requests, by class: network, rate_limit, auth, client or server.",
+		}, []string{"class"}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chatter_api_retries_total",
+			Help: "Speech API requests retried after a rate limit or unavailable response.",
+		}),
+		characters: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chatter_characters_billed_total",
+			Help: "Characters billed by Eleven Labs.",
+		}),
+		audioBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chatter_audio_bytes_total",
+			Help: "Bytes of audio received from speech APIs.",
+		}),
+	}
+	reg.MustRegister(m.requests, m.latency, m.errors, m.retries, m.characters, m.audioBytes)
+	return m
+}
+
+// errorClass returns the class of a failed response, "" for a successful one
+func errorClass(status int) string {
+	switch {
+	case status < 400:
+		return ""
+	case status == 429:
+		return ClassRateLimit
+	case status == 401 || status == 403:
+		return ClassAuth
+	case status < 500:
+		return ClassClient
+	}
+	return ClassServer
+}
@@ -0,0 +1,85 @@
+package telemetry_test
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+var recorder = tracetest.NewSpanRecorder()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	os.Exit(m.Run())
+}
+
+// spansOf returns the ended spans of the trace, in the order they ended
+func spansOf(traceID trace.TraceID) []sdktrace.ReadOnlySpan {
+	var out []sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.SpanContext().TraceID() == traceID {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func TestTransport_Tracing(t *testing.T) {
+	t.Parallel()
+
+	var traceparent string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set("request-id", "abc")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(s.Close)
+
+	ctx, parent := telemetry.StartSpan(context.Background(), "parent")
+	c, _ := newClient(t, prometheus.NewRegistry())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/v1/voices", nil)
+	require.NoError(t, err)
+	res, err := c.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	telemetry.EndSpan(parent, nil)
+
+	spans := spansOf(parent.SpanContext().TraceID())
+	require.Len(t, spans, 2)
+	request := spans[0]
+	assert.Equal(t, "GET /v1/voices", request.Name())
+	assert.Equal(t, trace.SpanKindClient, request.SpanKind())
+	assert.Equal(t, parent.SpanContext().SpanID(), request.Parent().SpanID())
+	assert.Equal(t, codes.Error, request.Status().Code)
+	assert.Contains(t, request.Attributes(), attribute.String("elevenlabs.request_id", "abc"))
+	assert.Contains(t, traceparent, request.SpanContext().SpanID().String(), "the server sees the request span")
+}
+
+func TestEndSpan(t *testing.T) {
+	t.Parallel()
+
+	_, span := telemetry.StartSpan(context.Background(), "stage")
+	telemetry.EndSpan(span, errors.New("boom"))
+
+	spans := spansOf(span.SpanContext().TraceID())
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "boom", spans[0].Status().Description)
+	require.Len(t, spans[0].Events(), 1)
+	assert.Equal(t, "exception", spans[0].Events()[0].Name)
+}
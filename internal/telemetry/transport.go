@@ -0,0 +1,230 @@
+package telemetry
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Transport retries rate limited and unavailable requests, logs every request, records it in Metrics and traces it
+// as a client span, propagating the trace context to the server
+type Transport struct {
+	// Base sends the requests, http.DefaultTransport when nil
+	Base http.RoundTripper
+	// Logger receives a record for each request, slog.Default() when nil
+	Logger *slog.Logger
+	// Metrics are updated when set
+	Metrics *Metrics
+	// Retries is how many times a 429 or 503 response is retried
+	Retries int
+	// Backoff is the wait before the first retry, doubling after each, unless the response sends Retry-After
+	Backoff time.Duration
+}
+
+// maxRetryAfter caps the wait a server can ask for
+const maxRetryAfter = time.Minute
+
+// RoundTrip sends the request, retrying it while the server is rate limiting or unavailable
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := Endpoint(req.URL)
+	ctx, span := Tracer().Start(req.Context(), req.Method+" "+endpoint, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.path", req.URL.Path),
+		))
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	r := &record{transport: t, req: req, endpoint: endpoint, start: time.Now(), span: span}
+	backoff := t.Backoff
+	for {
+		attempt := req
+		if r.retries > 0 {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt = req.Clone(req.Context())
+			attempt.Body = body
+		}
+		res, err := t.base().RoundTrip(attempt)
+		if err != nil {
+			r.finish(nil, 0, err)
+			return nil, err
+		}
+		if !t.retry(req, res, r.retries) {
+			res.Body = &observedBody{ReadCloser: res.Body, record: r, res: res}
+			return res, nil
+		}
+
+		wait := backoff
+		if seconds, aErr := strconv.Atoi(res.Header.Get("Retry-After")); aErr == nil && seconds >= 0 {
+			wait = min(time.Duration(seconds)*time.Second, maxRetryAfter)
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+		r.retries++
+		t.logger().Warn("retrying API request", "method", req.Method, "endpoint", r.endpoint, "status", res.StatusCode,
+			"retry", r.retries, "wait", wait)
+		if t.Metrics != nil {
+			t.Metrics.retries.Inc()
+		}
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			r.finish(nil, 0, req.Context().Err())
+			return nil, req.Context().Err()
+		}
+		backoff *= 2
+	}
+}
+
+// retry reports whether a response should be retried
+func (t *Transport) retry(req *http.Request, res *http.Response, retries int) bool {
+	if retries >= t.Retries || (req.Body != nil && req.GetBody == nil) {
+		return false
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) logger() *slog.Logger {
+	if t.Logger != nil {
+		return t.Logger
+	}
+	return slog.Default()
+}
+
+// record is one request, across its retries
+type record struct {
+	transport *Transport
+	req       *http.Request
+	endpoint  string
+	start     time.Time
+	retries   int
+	span      trace.Span
+	once      sync.Once
+}
+
+// finish logs the request and updates the metrics once its response has been read, or it has failed
+func (r *record) finish(res *http.Response, n int64, err error) {
+	r.once.Do(func() {
+		latency := time.Since(r.start)
+		attrs := []any{"method", r.req.Method, "endpoint", r.endpoint, "latency", latency, "retries", r.retries}
+		code, class := "error", ClassNetwork
+		var characters int
+		if res != nil {
+			code, class = strconv.Itoa(res.StatusCode), errorClass(res.StatusCode)
+			characters, _ = strconv.Atoi(res.Header.Get("x-character-count"))
+			attrs = append(attrs, "status", res.StatusCode, "request_id", res.Header.Get("request-id"),
+				"characters", characters, "bytes", n)
+		}
+		if err != nil {
+			attrs = append(attrs, "err", err)
+		}
+
+		r.span.SetAttributes(attribute.Int("http.resend_count", r.retries))
+		if res != nil {
+			r.span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode),
+				attribute.String("elevenlabs.request_id", res.Header.Get("request-id")),
+				attribute.Int("elevenlabs.characters", characters))
+		}
+		if class != "" {
+			r.span.SetAttributes(attribute.String("error.type", class))
+			r.span.SetStatus(codes.Error, code)
+		}
+		if err != nil {
+			r.span.RecordError(err)
+			r.span.SetStatus(codes.Error, err.Error())
+		}
+		r.span.End()
+
+		logger := r.transport.logger()
+		if class == "" && err == nil {
+			logger.Debug("API request", attrs...)
+		} else {
+			logger.Warn("API request failed", append(attrs, "class", class)...)
+		}
+
+		m := r.transport.Metrics
+		if m == nil {
+			return
+		}
+		m.requests.WithLabelValues(r.endpoint, code).Inc()
+		m.latency.WithLabelValues(r.endpoint).Observe(latency.Seconds())
+		if class != "" {
+			m.errors.WithLabelValues(class).Inc()
+		}
+		m.characters.Add(float64(characters))
+		if res != nil && strings.HasPrefix(res.Header.Get("Content-Type"), "audio/") {
+			m.audioBytes.Add(float64(n))
+		}
+	})
+}
+
+// observedBody counts the bytes of a response and finishes its record when closed
+type observedBody struct {
+	io.ReadCloser
+	record *record
+	res    *http.Response
+	n      int64
+	err    error
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.record.finish(b.res, b.n, b.err)
+	return err
+}
+
+// Endpoint names a request's path for logs and metric labels, keeping its first three segments and replacing
+// IDs, alphanumeric segments with a digit or of 20 or more characters, with {id}: /v1/text-to-speech/{id}
+func Endpoint(u *url.URL) string {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) > 3 {
+		segments = segments[:3]
+	}
+	for i, s := range segments {
+		if i > 0 && isID(s) {
+			segments[i] = "{id}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+func isID(segment string) bool {
+	digits := false
+	for _, r := range segment {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+		digits = digits || unicode.IsDigit(r)
+	}
+	return digits || len(segment) >= 20
+}
@@ -0,0 +1,156 @@
+package telemetry_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sgerhardt/chatter/internal/fakeeleven"
+	"github.com/sgerhardt/chatter/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newClient returns a client whose transport logs JSON records to the returned buffer and records metrics in reg
+func newClient(t *testing.T, reg *prometheus.Registry) (*http.Client, *bytes.Buffer) {
+	t.Helper()
+	var logs bytes.Buffer
+	logger, err := telemetry.NewLogger(&logs, telemetry.FormatJSON, slog.LevelDebug)
+	require.NoError(t, err)
+	return &http.Client{Transport: &telemetry.Transport{
+		Logger:  logger,
+		Metrics: telemetry.NewMetrics(reg),
+		Retries: 2,
+		Backoff: time.Millisecond,
+	}}, &logs
+}
+
+func synthesize(t *testing.T, c *http.Client, s *fakeeleven.Server, text string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, s.URL+"/v1/text-to-speech/"+fakeeleven.DefaultVoices[0].VoiceID,
+		strings.NewReader(`{"text": "`+text+`"}`))
+	require.NoError(t, err)
+	res, err := c.Do(req)
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	return res
+}
+
+// records decodes the JSON log lines
+func records(t *testing.T, logs *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var r map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("logs and counts each request", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		reg := prometheus.NewRegistry()
+		c, logs := newClient(t, reg)
+		res := synthesize(t, c, s, "Hello")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		logged := records(t, logs)
+		require.Len(t, logged, 1)
+		assert.Equal(t, "DEBUG", logged[0]["level"])
+		assert.Equal(t, "/v1/text-to-speech/{id}", logged[0]["endpoint"])
+		assert.Equal(t, "request0001", logged[0]["request_id"])
+		assert.EqualValues(t, 5, logged[0]["characters"])
+		assert.EqualValues(t, 0, logged[0]["retries"])
+		assert.Contains(t, logged[0], "latency")
+
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP chatter_api_requests_total Requests made to speech APIs, by endpoint and response code.
+# TYPE chatter_api_requests_total counter
+chatter_api_requests_total{code="200",endpoint="/v1/text-to-speech/{id}"} 1
+# HELP chatter_characters_billed_total Characters billed by Eleven Labs.
+# TYPE chatter_characters_billed_total counter
+chatter_characters_billed_total 5
+`), "chatter_api_requests_total", "chatter_characters_billed_total"))
+		count, err := testutil.GatherAndCount(reg, "chatter_api_request_duration_seconds")
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		audioBytes, err := testutil.GatherAndCount(reg, "chatter_audio_bytes_total")
+		require.NoError(t, err)
+		assert.Equal(t, 1, audioBytes)
+	})
+
+	t.Run("retries rate limited requests", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		s.Inject(fakeeleven.Fault{Path: "/v1/text-to-speech", Status: http.StatusTooManyRequests, Times: 1})
+		reg := prometheus.NewRegistry()
+		c, logs := newClient(t, reg)
+		res := synthesize(t, c, s, "Hello")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, s.Requests(), 2)
+		assert.Equal(t, 5, s.CharacterCount(), "only the retry is billed")
+
+		logged := records(t, logs)
+		require.Len(t, logged, 2)
+		assert.Equal(t, "retrying API request", logged[0]["msg"])
+		assert.EqualValues(t, 1, logged[1]["retries"])
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP chatter_api_retries_total Speech API requests retried after a rate limit or unavailable response.
+# TYPE chatter_api_retries_total counter
+chatter_api_retries_total 1
+`), "chatter_api_retries_total"))
+	})
+
+	t.Run("classifies errors", func(t *testing.T) {
+		t.Parallel()
+		s := fakeeleven.New(t)
+		s.Inject(fakeeleven.Fault{Path: "/v1/text-to-speech", Status: http.StatusTooManyRequests})
+		reg := prometheus.NewRegistry()
+		c, logs := newClient(t, reg)
+		res := synthesize(t, c, s, "Hello")
+		assert.Equal(t, http.StatusTooManyRequests, res.StatusCode, "retries run out")
+
+		logged := records(t, logs)
+		require.Len(t, logged, 3)
+		assert.Equal(t, "WARN", logged[2]["level"])
+		assert.Equal(t, telemetry.ClassRateLimit, logged[2]["class"])
+		assert.EqualValues(t, 2, logged[2]["retries"])
+
+		_, err := c.Get("http://127.0.0.1:1/v1/voices")
+		require.Error(t, err)
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP chatter_api_errors_total Failed speech API requests, by class: network, rate_limit, auth, client or server.
+# TYPE chatter_api_errors_total counter
+chatter_api_errors_total{class="network"} 1
+chatter_api_errors_total{class="rate_limit"} 1
+`), "chatter_api_errors_total"))
+	})
+}
+
+func TestEndpoint(t *testing.T) {
+	t.Parallel()
+
+	for path, want := range map[string]string{
+		"/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM/with-timestamps": "/v1/text-to-speech/{id}",
+		"/v1/voices":       "/v1/voices",
+		"/v1/audio/speech": "/v1/audio/speech",
+		"/v1/pronunciation-dictionaries/abcdefghijklmnopqrst": "/v1/pronunciation-dictionaries/{id}",
+		"/": "/",
+	} {
+		assert.Equal(t, want, telemetry.Endpoint(&url.URL{Path: path}), path)
+	}
+}
@@ -0,0 +1,51 @@
+// Package transcode converts between mp3 and WAV with ffmpeg, so mp3 audio can be processed as PCM
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrNoEncoder is returned when ffmpeg isn't installed
+var ErrNoEncoder = errors.New("processing mp3 needs ffmpeg: install it, or use a pcm_ output format")
+
+// Available reports whether ffmpeg is installed
+func Available() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+}
+
+// ToWAV decodes mp3 to 16 bit PCM WAV
+func ToWAV(ctx context.Context, mp3 []byte) ([]byte, error) {
+	return run(ctx, mp3, "-f", "mp3", "-i", "pipe:0", "-codec:a", "pcm_s16le", "-f", "wav", "pipe:1")
+}
+
+// ToMP3 encodes WAV as mp3 at the bitrate in kbit/s, without a tag
+func ToMP3(ctx context.Context, wav []byte, bitrate int) ([]byte, error) {
+	return run(ctx, wav, "-f", "wav", "-i", "pipe:0", "-codec:a", "libmp3lame", "-b:a", fmt.Sprintf("%dk", bitrate),
+		"-id3v2_version", "0", "-write_xing", "0", "-f", "mp3", "pipe:1")
+}
+
+func run(ctx context.Context, data []byte, args ...string) ([]byte, error) {
+	if !Available() {
+		return nil, ErrNoEncoder
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg", append([]string{"-hide_banner", "-loglevel", "error"}, args...)...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,40 @@
+package transcode_test
+
+import (
+	"context"
+	"github.com/sgerhardt/chatter/internal/transcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeFFmpeg installs an ffmpeg on PATH that echoes its arguments and then its input
+func fakeFFmpeg(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\nPATH=/usr/bin:/bin\necho \"$@\"\ncat\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0755))
+	t.Setenv("PATH", dir)
+}
+
+func TestTranscode(t *testing.T) { // nolint:paralleltest
+	fakeFFmpeg(t)
+	require.True(t, transcode.Available())
+
+	out, err := transcode.ToWAV(context.Background(), []byte("mp3"))
+	require.NoError(t, err)
+	assert.Equal(t, "-hide_banner -loglevel error -f mp3 -i pipe:0 -codec:a pcm_s16le -f wav pipe:1\nmp3", string(out))
+
+	out, err = transcode.ToMP3(context.Background(), []byte("wav"), 192)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "-codec:a libmp3lame -b:a 192k -id3v2_version 0 -write_xing 0 -f mp3 pipe:1\nwav")
+}
+
+func TestTranscode_NoEncoder(t *testing.T) { // nolint:paralleltest
+	t.Setenv("PATH", t.TempDir())
+	assert.False(t, transcode.Available())
+	_, err := transcode.ToWAV(context.Background(), []byte("mp3"))
+	assert.ErrorIs(t, err, transcode.ErrNoEncoder)
+}
@@ -0,0 +1,123 @@
+// Package usage keeps a ledger of every synthesis in SQLite, with the characters it billed, so usage can be
+// charged back to the teams and projects that ran it
+package usage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	_ "modernc.org/sqlite" // registers the pure Go sqlite driver, so builds don't need cgo
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS usage (
+	id         INTEGER PRIMARY KEY,
+	time       INTEGER NOT NULL,
+	profile    TEXT NOT NULL DEFAULT '',
+	voice_id   TEXT NOT NULL,
+	model      TEXT NOT NULL DEFAULT '',
+	characters INTEGER NOT NULL,
+	source     TEXT NOT NULL DEFAULT '',
+	output     TEXT NOT NULL DEFAULT '',
+	request_id TEXT NOT NULL DEFAULT '',
+	tag        TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS usage_time ON usage (time);`
+
+// Ledger is the usage database. It implements client.UsageLedger.
+type Ledger struct {
+	db *sql.DB
+}
+
+// Open opens or creates the ledger at path
+func Open(path string) (*Ledger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create usage table in %s: %w", path, err)
+	}
+	return &Ledger{db: db}, nil
+}
+
+// Close closes the database
+func (l *Ledger) Close() error {
+	return l.db.Close()
+}
+
+// Record adds the entries to the ledger
+func (l *Ledger) Record(entries []client.Usage) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, u := range entries {
+		_, err = tx.Exec(`INSERT INTO usage (time, profile, voice_id, model, characters, source, output, request_id, tag)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			u.Time.UnixMilli(), u.Profile, u.VoiceID, u.Model, u.Characters, u.Source, u.Output, u.RequestID, u.Tag)
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to record usage: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Filter selects ledger entries. Zero fields match everything.
+type Filter struct {
+	// From is the earliest time included
+	From time.Time
+	// To is the first time no longer included
+	To      time.Time
+	VoiceID string
+	Tag     string
+}
+
+// Query returns the entries matching the filter, oldest first
+func (l *Ledger) Query(f Filter) ([]client.Usage, error) {
+	var where []string
+	var args []any
+	if !f.From.IsZero() {
+		where, args = append(where, "time >= ?"), append(args, f.From.UnixMilli())
+	}
+	if !f.To.IsZero() {
+		where, args = append(where, "time < ?"), append(args, f.To.UnixMilli())
+	}
+	if f.VoiceID != "" {
+		where, args = append(where, "voice_id = ?"), append(args, f.VoiceID)
+	}
+	if f.Tag != "" {
+		where, args = append(where, "tag = ?"), append(args, f.Tag)
+	}
+	query := `SELECT time, profile, voice_id, model, characters, source, output, request_id, tag FROM usage`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := l.db.Query(query+" ORDER BY time, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []client.Usage
+	for rows.Next() {
+		var u client.Usage
+		var t int64
+		if err = rows.Scan(&t, &u.Profile, &u.VoiceID, &u.Model, &u.Characters, &u.Source, &u.Output, &u.RequestID, &u.Tag); err != nil {
+			return nil, err
+		}
+		u.Time = time.UnixMilli(t).UTC()
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
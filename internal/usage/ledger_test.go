@@ -0,0 +1,53 @@
+package usage_test
+
+import (
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var day = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// entries are three syntheses over two days, two voices and two tags
+var entries = []client.Usage{
+	{Time: day.Add(9 * time.Hour), Profile: "work", VoiceID: "alice", Model: "eleven_turbo_v2_5", Characters: 100,
+		Source: "text", Output: "/out/a.mp3", RequestID: "r1", Tag: "podcast"},
+	{Time: day.Add(10 * time.Hour), Profile: "work", VoiceID: "bob", Model: "eleven_turbo_v2_5", Characters: 250,
+		Source: "https://example.com", Output: "/out/b.mp3", RequestID: "r2", Tag: "docs"},
+	{Time: day.Add(33 * time.Hour), VoiceID: "alice", Characters: 50, Source: "script", Tag: "podcast"},
+}
+
+func openLedger(t *testing.T) *usage.Ledger {
+	t.Helper()
+	l, err := usage.Open(filepath.Join(t.TempDir(), "usage", "usage.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	require.NoError(t, l.Record(entries))
+	return l
+}
+
+func TestLedger_Query(t *testing.T) {
+	t.Parallel()
+	l := openLedger(t)
+
+	for name, tc := range map[string]struct {
+		filter usage.Filter
+		want   []client.Usage
+	}{
+		"everything":   {usage.Filter{}, entries},
+		"by voice":     {usage.Filter{VoiceID: "alice"}, []client.Usage{entries[0], entries[2]}},
+		"by tag":       {usage.Filter{Tag: "docs"}, entries[1:2]},
+		"from a time":  {usage.Filter{From: day.Add(10 * time.Hour)}, entries[1:]},
+		"before a day": {usage.Filter{To: day.AddDate(0, 0, 1)}, entries[:2]},
+		"combined":     {usage.Filter{From: day.AddDate(0, 0, 1), Tag: "podcast"}, entries[2:]},
+		"nothing":      {usage.Filter{VoiceID: "carol"}, nil},
+	} {
+		got, err := l.Query(tc.filter)
+		require.NoError(t, err, name)
+		assert.Equal(t, tc.want, got, name)
+	}
+}
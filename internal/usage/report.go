@@ -0,0 +1,107 @@
+package usage
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/sgerhardt/chatter/internal/client"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Groupings for Summarize
+const (
+	ByTag     = "tag"
+	ByVoice   = "voice"
+	ByProfile = "profile"
+	ByModel   = "model"
+	ByDay     = "day"
+)
+
+// Groupings lists what Summarize can group by
+func Groupings() []string {
+	return []string{ByTag, ByVoice, ByProfile, ByModel, ByDay}
+}
+
+// Total is the usage of one group
+type Total struct {
+	Key        string
+	Requests   int
+	Characters int
+}
+
+// Cost is what the characters cost at price per 1,000 characters
+func (t Total) Cost(price float64) float64 {
+	return float64(t.Characters) / 1000 * price
+}
+
+// Summarize totals the entries by tag, voice, profile, model or day, sorted by key
+func Summarize(entries []client.Usage, by string) ([]Total, error) {
+	if !slices.Contains(Groupings(), by) {
+		return nil, fmt.Errorf("unknown grouping %q, expected one of %v", by, Groupings())
+	}
+	totals := map[string]*Total{}
+	for _, u := range entries {
+		key := groupKey(u, by)
+		t, ok := totals[key]
+		if !ok {
+			t = &Total{Key: key}
+			totals[key] = t
+		}
+		t.Requests++
+		t.Characters += u.Characters
+	}
+	out := make([]Total, 0, len(totals))
+	for _, t := range totals {
+		out = append(out, *t)
+	}
+	slices.SortFunc(out, func(a, b Total) int { return cmp.Compare(a.Key, b.Key) })
+	return out, nil
+}
+
+func groupKey(u client.Usage, by string) string {
+	switch by {
+	case ByVoice:
+		return u.VoiceID
+	case ByProfile:
+		return u.Profile
+	case ByModel:
+		return u.Model
+	case ByDay:
+		return u.Time.Format(time.DateOnly)
+	}
+	return u.Tag
+}
+
+// csvHeader names the columns written by WriteCSV
+var csvHeader = []string{"time", "profile", "voice_id", "model", "characters", "source", "output", "request_id", "tag"}
+
+// WriteCSV writes the entries with a header row
+func WriteCSV(w io.Writer, entries []client.Usage) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, u := range entries {
+		err := cw.Write([]string{u.Time.Format(time.RFC3339), u.Profile, u.VoiceID, u.Model, strconv.Itoa(u.Characters),
+			u.Source, u.Output, u.RequestID, u.Tag})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the entries as an indented JSON array
+func WriteJSON(w io.Writer, entries []client.Usage) error {
+	if entries == nil {
+		entries = []client.Usage{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
@@ -0,0 +1,58 @@
+package usage_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/sgerhardt/chatter/internal/client"
+	"github.com/sgerhardt/chatter/internal/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	totals, err := usage.Summarize(entries, usage.ByTag)
+	require.NoError(t, err)
+	assert.Equal(t, []usage.Total{
+		{Key: "docs", Requests: 1, Characters: 250},
+		{Key: "podcast", Requests: 2, Characters: 150},
+	}, totals)
+	assert.InDelta(t, 0.045, totals[1].Cost(0.30), 1e-9)
+
+	totals, err = usage.Summarize(entries, usage.ByDay)
+	require.NoError(t, err)
+	assert.Equal(t, []usage.Total{
+		{Key: "2024-06-01", Requests: 2, Characters: 350},
+		{Key: "2024-06-02", Requests: 1, Characters: 50},
+	}, totals)
+
+	_, err = usage.Summarize(entries, "color")
+	assert.EqualError(t, err, `unknown grouping "color", expected one of [tag voice profile model day]`)
+}
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, usage.WriteCSV(&buf, entries[1:]))
+	assert.Equal(t, `time,profile,voice_id,model,characters,source,output,request_id,tag
+2024-06-01T10:00:00Z,work,bob,eleven_turbo_v2_5,250,https://example.com,/out/b.mp3,r2,docs
+2024-06-02T09:00:00Z,,alice,,50,script,,,podcast
+`, buf.String())
+}
+
+func TestWriteJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, usage.WriteJSON(&buf, entries))
+	var got []client.Usage
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, entries, got)
+
+	buf.Reset()
+	require.NoError(t, usage.WriteJSON(&buf, nil))
+	assert.Equal(t, "[]\n", buf.String())
+}